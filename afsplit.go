@@ -0,0 +1,89 @@
+package pbkdf
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// AFSplit splits data into stripes with the anti-forensic information splitter of LUKS
+// It is based on the LUKS1 on-disk format specification(https://gitlab.com/cryptsetup/cryptsetup/-/wikis/Specification) section 2.4
+// hash: the hash function of the diffusion
+// data: the data to split, usually a master key
+// stripes: the number of stripes, LUKS uses 4000
+// returns: stripes * len(data) bytes of material, every byte of it is needed by AFMerge to recover data,
+// so destroying a small part of stored material(for example by overwriting it) destroys data even if copies of the rest remain
+func AFSplit(hash crypto.Hash, data []byte, stripes int) ([]byte, error) {
+	// check the parameters
+	if stripes < 1 {
+		return nil, errors.New("error in AFSplit function: stripes must be positive")
+	}
+
+	if !hash.Available() {
+		return nil, fmt.Errorf("error in AFSplit function: hash function %d is not available", hash)
+	}
+
+	// the first stripes - 1 stripes are random
+	material, err := GenerateRandomSequence(stripes * len(data))
+	if err != nil {
+		return nil, fmt.Errorf("error in AFSplit function while generating stripes: %s", err.Error())
+	}
+
+	// the last stripe is data xor the diffusion of the others
+	d := afDiffuseStripes(hash, material, len(data), stripes)
+	subtle.XORBytes(material[(stripes-1)*len(data):], d, data)
+
+	return material, nil
+}
+
+// AFMerge recovers the data split by AFSplit
+// hash: the hash function of the diffusion
+// material: the output of AFSplit
+// stripes: the number of stripes
+func AFMerge(hash crypto.Hash, material []byte, stripes int) ([]byte, error) {
+	// check the parameters
+	if stripes < 1 || len(material)%stripes != 0 {
+		return nil, errors.New("error in AFMerge function: material must be a positive number of stripes")
+	}
+
+	if !hash.Available() {
+		return nil, fmt.Errorf("error in AFMerge function: hash function %d is not available", hash)
+	}
+
+	length := len(material) / stripes
+
+	// data is the last stripe xor the diffusion of the others
+	d := afDiffuseStripes(hash, material, length, stripes)
+	subtle.XORBytes(d, d, material[(stripes-1)*length:])
+
+	return d, nil
+}
+
+// afDiffuseStripes returns d = diffuse(d xor stripe) over the first stripes - 1 stripes, starting with d = 0
+func afDiffuseStripes(hash crypto.Hash, material []byte, length int, stripes int) []byte {
+	d := make([]byte, length)
+
+	for i := 0; i < stripes-1; i++ {
+		subtle.XORBytes(d, d, material[i*length:(i+1)*length])
+		afDiffuse(hash, d)
+	}
+
+	return d
+}
+
+// afDiffuse replaces every hash sized block of d with H(block index || block), the last block is truncated
+// the block index is a 32 bit big-endian integer
+func afDiffuse(hash crypto.Hash, d []byte) {
+	h := hash.New()
+	size := h.Size()
+
+	for i := 0; i*size < len(d); i++ {
+		block := d[i*size : min((i+1)*size, len(d))]
+
+		h.Reset()
+		h.Write(ConvertUnsignedIntegerToByteSlice(uint64(i), 4, false))
+		h.Write(block)
+		copy(block, h.Sum(nil))
+	}
+}
@@ -0,0 +1,514 @@
+package pbkdf
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// Argon2Variant selects the Argon2 variant
+type Argon2Variant int
+
+// Argon2 variants, the values are the type identifiers of RFC9106
+const (
+	// Argon2d uses data-dependent memory access, it is the fastest but vulnerable to side channels
+	Argon2d Argon2Variant = 0
+	// Argon2i uses data-independent memory access
+	Argon2i Argon2Variant = 1
+	// Argon2id uses data-independent access in the first half of the first pass and data-dependent access after it
+	// it is the variant recommended for password hashing
+	Argon2id Argon2Variant = 2
+)
+
+// String returns the name of the variant as used in PHC strings(argon2d, argon2i or argon2id)
+func (v Argon2Variant) String() string {
+	switch v {
+	case Argon2d:
+		return "argon2d"
+	case Argon2i:
+		return "argon2i"
+	case Argon2id:
+		return "argon2id"
+	}
+
+	return fmt.Sprintf("Argon2Variant(%d)", int(v))
+}
+
+// Argon2Version is the version of Argon2 implemented by this package(1.3)
+const Argon2Version = 0x13
+
+// DefaultArgon2MaxMemory is the memory limit used by Argon2 and VerifyPasswordArgon2(2 GiB)
+// it protects verification from encoded passwords with huge parameters
+const DefaultArgon2MaxMemory int64 = 2 << 30
+
+// Argon2Options holds the optional inputs of Argon2WithOptions
+type Argon2Options struct {
+	// Secret is the optional secret value K(a pepper kept outside the database)
+	Secret []byte
+	// AssociatedData is the optional associated data X
+	AssociatedData []byte
+	// MaxMemory is the maximum number of bytes the derivation may allocate, zero means DefaultArgon2MaxMemory
+	MaxMemory int64
+}
+
+// number of slices per pass
+const argon2SyncPoints = 4
+
+// argon2Block is a 1 KiB Argon2 memory block
+type argon2Block [128]uint64
+
+// Argon2 is a function that implements the Argon2 algorithm
+// It is based on the RFC9106(https://datatracker.ietf.org/doc/html/rfc9106)
+// variant: Argon2d, Argon2i or Argon2id
+// P: the password(as a byte slice)
+// S: the salt(as a byte slice), at least 8 bytes
+// t: the number of passes
+// m: the memory size in KiB, at least 8 * p
+// p: the degree of parallelism(number of lanes), the lanes are computed concurrently
+// dkLen: the byte length of the derived key, at least 4
+// Argon2 is not approved by SP 800-132, it is rejected in compliance mode
+func Argon2(variant Argon2Variant, P []byte, S []byte, t, m, p int64, dkLen int64) ([]byte, error) {
+	return Argon2WithOptions(variant, P, S, t, m, p, dkLen, Argon2Options{})
+}
+
+// Argon2WithOptions is like Argon2 with a secret, associated data or a different memory limit
+func Argon2WithOptions(variant Argon2Variant, P []byte, S []byte, t, m, p int64, dkLen int64, options Argon2Options) ([]byte, error) {
+	// Argon2 is not an approved algorithm
+	if currentCompliancePolicy() != nil {
+		return nil, fmt.Errorf("error in Argon2 function: %w: Argon2 is not approved", ErrNotCompliant)
+	}
+
+	// get the memory limit
+	maxMemory := options.MaxMemory
+	if maxMemory == 0 {
+		maxMemory = DefaultArgon2MaxMemory
+	}
+
+	// check the parameters
+	switch {
+	case variant != Argon2d && variant != Argon2i && variant != Argon2id:
+		return nil, fmt.Errorf("error in Argon2 function: unknown variant %d", variant)
+	case t < 1 || t > 1<<32-1:
+		return nil, errors.New("error in Argon2 function: number of passes must be between 1 and 2^32-1")
+	case p < 1 || p > 1<<24-1:
+		return nil, errors.New("error in Argon2 function: parallelism must be between 1 and 2^24-1")
+	case m < 8*p || m > 1<<32-1:
+		return nil, errors.New("error in Argon2 function: memory size must be between 8 * p and 2^32-1 KiB")
+	case dkLen < 4 || dkLen > 1<<32-1:
+		return nil, errors.New("error in Argon2 function: derived key length must be between 4 and 2^32-1")
+	case len(S) < 8:
+		return nil, errors.New("error in Argon2 function: salt must be at least 8 bytes")
+	case int64(len(P)) > 1<<32-1 || int64(len(S)) > 1<<32-1 || int64(len(options.Secret)) > 1<<32-1 || int64(len(options.AssociatedData)) > 1<<32-1:
+		return nil, errors.New("error in Argon2 function: inputs must be shorter than 2^32 bytes")
+	}
+
+	// m' = 4 * p * floor(m / 4p) blocks
+	blocks := m / (argon2SyncPoints * p) * (argon2SyncPoints * p)
+
+	// check the memory limit
+	if blocks*1024 > maxMemory {
+		return nil, fmt.Errorf("error in Argon2 function: parameters need %d bytes of memory, the limit is %d", blocks*1024, maxMemory)
+	}
+
+	// H0 = H^(64)(p, T, m, t, v, y, P, S, K, X)
+	h0 := argon2InitialHash(variant, P, S, options.Secret, options.AssociatedData, t, m, p, dkLen)
+
+	// fill the memory
+	B := make([]argon2Block, blocks)
+	laneLength := blocks / p
+	argon2InitBlocks(B, h0, laneLength, p)
+	argon2FillMemory(B, variant, t, blocks, laneLength, p)
+
+	// C = B[0][q-1] xor B[1][q-1] xor ... xor B[p-1][q-1]
+	final := B[laneLength-1]
+	for lane := int64(1); lane < p; lane++ {
+		for i, v := range B[lane*laneLength+laneLength-1] {
+			final[i] ^= v
+		}
+	}
+
+	// tag = H'^(T)(C)
+	return argon2VariableHash(int(dkLen), argon2BlockBytes(&final)), nil
+}
+
+// argon2InitialHash computes H0
+func argon2InitialHash(variant Argon2Variant, P, S, K, X []byte, t, m, p, dkLen int64) []byte {
+	h, _ := NewBLAKE2b(64, nil)
+
+	// writeInt writes a little endian uint32
+	writeInt := func(v int64) {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(v))
+		h.Write(b[:])
+	}
+
+	writeInt(p)
+	writeInt(dkLen)
+	writeInt(m)
+	writeInt(t)
+	writeInt(Argon2Version)
+	writeInt(int64(variant))
+
+	// length prefixed inputs
+	for _, input := range [][]byte{P, S, K, X} {
+		writeInt(int64(len(input)))
+		h.Write(input)
+	}
+
+	return h.Sum(nil)
+}
+
+// argon2VariableHash is the variable length hash function H' of RFC9106 section 3.3
+func argon2VariableHash(size int, input ...[]byte) []byte {
+	// prefix the input with the output length
+	var prefix [4]byte
+	binary.LittleEndian.PutUint32(prefix[:], uint32(size))
+	input = append([][]byte{prefix[:]}, input...)
+
+	// short outputs are a single BLAKE2b call
+	if size <= 64 {
+		return blake2bSum(size, input...)
+	}
+
+	// V1 = H^(64)(size || input), Vi = H^(64)(Vi-1), the output is the first 32 bytes of each Vi
+	out := make([]byte, 0, size)
+	V := blake2bSum(64, input...)
+	out = append(out, V[:32]...)
+	for size-len(out) > 64 {
+		V = blake2bSum(64, V)
+		out = append(out, V[:32]...)
+	}
+
+	// the last block is a hash of the remaining length
+	return append(out, blake2bSum(size-len(out), V)...)
+}
+
+// argon2InitBlocks computes the first two blocks of every lane
+func argon2InitBlocks(B []argon2Block, h0 []byte, laneLength, p int64) {
+	var index [8]byte
+
+	for lane := int64(0); lane < p; lane++ {
+		binary.LittleEndian.PutUint32(index[4:], uint32(lane))
+
+		for i := int64(0); i < 2; i++ {
+			// B[i][j] = H'^(1024)(H0 || LE32(j) || LE32(i))
+			binary.LittleEndian.PutUint32(index[:4], uint32(i))
+			block := argon2VariableHash(1024, h0, index[:])
+
+			for k := range B[lane*laneLength+i] {
+				B[lane*laneLength+i][k] = binary.LittleEndian.Uint64(block[8*k:])
+			}
+		}
+	}
+}
+
+// argon2FillMemory runs the passes, the lanes of a slice are computed concurrently
+func argon2FillMemory(B []argon2Block, variant Argon2Variant, t, blocks, laneLength, p int64) {
+	segmentLength := laneLength / argon2SyncPoints
+
+	for pass := int64(0); pass < t; pass++ {
+		for slice := int64(0); slice < argon2SyncPoints; slice++ {
+			var wg sync.WaitGroup
+
+			for lane := int64(0); lane < p; lane++ {
+				wg.Add(1)
+
+				go func(lane int64) {
+					defer wg.Done()
+					argon2FillSegment(B, variant, t, blocks, laneLength, segmentLength, p, pass, slice, lane)
+				}(lane)
+			}
+
+			wg.Wait()
+		}
+	}
+}
+
+// argon2FillSegment computes the blocks of one segment
+func argon2FillSegment(B []argon2Block, variant Argon2Variant, t, blocks, laneLength, segmentLength, p, pass, slice, lane int64) {
+	var addresses, input, zero argon2Block
+
+	// Argon2i and the first half of the first pass of Argon2id use data-independent addressing
+	dataIndependent := variant == Argon2i || (variant == Argon2id && pass == 0 && slice < argon2SyncPoints/2)
+	if dataIndependent {
+		input[0] = uint64(pass)
+		input[1] = uint64(lane)
+		input[2] = uint64(slice)
+		input[3] = uint64(blocks)
+		input[4] = uint64(t)
+		input[5] = uint64(variant)
+	}
+
+	// nextAddresses computes the next block of pseudo-random addresses
+	nextAddresses := func() {
+		input[6]++
+		argon2Compress(&addresses, &zero, &input, false)
+		argon2Compress(&addresses, &zero, &addresses, false)
+	}
+
+	// the first two blocks of a lane are already computed
+	index := int64(0)
+	if pass == 0 && slice == 0 {
+		index = 2
+		if dataIndependent {
+			nextAddresses()
+		}
+	}
+
+	offset := lane*laneLength + slice*segmentLength + index
+	for ; index < segmentLength; index, offset = index+1, offset+1 {
+		// the previous block wraps to the end of the lane
+		previous := offset - 1
+		if index == 0 && slice == 0 {
+			previous += laneLength
+		}
+
+		// get the pseudo-random value J1 || J2
+		var random uint64
+		if dataIndependent {
+			if index%128 == 0 {
+				nextAddresses()
+			}
+			random = addresses[index%128]
+		} else {
+			random = B[previous][0]
+		}
+
+		// B[i][j] = G(B[i][j-1], B[l][z]), xored with the old block after the first pass
+		reference := argon2ReferenceIndex(random, laneLength, segmentLength, p, pass, slice, lane, index)
+		argon2Compress(&B[offset], &B[previous], &B[reference], pass > 0)
+	}
+}
+
+// argon2ReferenceIndex maps J1 || J2 to the index of the reference block(RFC9106 section 3.4.2)
+func argon2ReferenceIndex(random uint64, laneLength, segmentLength, p, pass, slice, lane, index int64) int64 {
+	// l = J2 mod p, the first slice of the first pass only references its own lane
+	referenceLane := int64(random>>32) % p
+	if pass == 0 && slice == 0 {
+		referenceLane = lane
+	}
+
+	// size and start of the reference area
+	var size, start int64
+	if pass == 0 {
+		size = slice * segmentLength
+		if referenceLane == lane {
+			size += index - 1
+		} else if index == 0 {
+			size--
+		}
+	} else {
+		size = laneLength - segmentLength
+		if referenceLane == lane {
+			size += index - 1
+		} else if index == 0 {
+			size--
+		}
+		start = ((slice + 1) % argon2SyncPoints) * segmentLength
+	}
+
+	// x = J1^2 / 2^32, y = (|W| * x) / 2^32, zz = |W| - 1 - y
+	x := (random & 0xffffffff) * (random & 0xffffffff) >> 32
+	y := uint64(size) * x >> 32
+	relative := uint64(size) - 1 - y
+
+	return referenceLane*laneLength + int64((uint64(start)+relative)%uint64(laneLength))
+}
+
+// argon2Compress is the compression function G of RFC9106 section 3.5
+// out = G(X, Y) or out ^= G(X, Y) when xor is true
+func argon2Compress(out, X, Y *argon2Block, xor bool) {
+	// R = X xor Y
+	var R, Q argon2Block
+	for i := range R {
+		R[i] = X[i] ^ Y[i]
+	}
+	Q = R
+
+	// apply P to the rows, then to the columns, of the 8x8 matrix of 16 byte registers
+	var v [16]uint64
+	for row := 0; row < 8; row++ {
+		copy(v[:], Q[16*row:16*row+16])
+		argon2Permute(&v)
+		copy(Q[16*row:16*row+16], v[:])
+	}
+
+	for column := 0; column < 8; column++ {
+		for k := 0; k < 8; k++ {
+			v[2*k], v[2*k+1] = Q[16*k+2*column], Q[16*k+2*column+1]
+		}
+		argon2Permute(&v)
+		for k := 0; k < 8; k++ {
+			Q[16*k+2*column], Q[16*k+2*column+1] = v[2*k], v[2*k+1]
+		}
+	}
+
+	// out = Q xor R
+	if xor {
+		for i := range out {
+			out[i] ^= Q[i] ^ R[i]
+		}
+	} else {
+		for i := range out {
+			out[i] = Q[i] ^ R[i]
+		}
+	}
+}
+
+// argon2Permute is the permutation P, the BLAKE2b round with the multiplication hardened GB function
+func argon2Permute(v *[16]uint64) {
+	argon2GB(v, 0, 4, 8, 12)
+	argon2GB(v, 1, 5, 9, 13)
+	argon2GB(v, 2, 6, 10, 14)
+	argon2GB(v, 3, 7, 11, 15)
+	argon2GB(v, 0, 5, 10, 15)
+	argon2GB(v, 1, 6, 11, 12)
+	argon2GB(v, 2, 7, 8, 13)
+	argon2GB(v, 3, 4, 9, 14)
+}
+
+// argon2GB is the GB function of RFC9106 section 3.6
+func argon2GB(v *[16]uint64, a, b, c, d int) {
+	v[a] = v[a] + v[b] + 2*uint64(uint32(v[a]))*uint64(uint32(v[b]))
+	v[d] = bits.RotateLeft64(v[d]^v[a], -32)
+	v[c] = v[c] + v[d] + 2*uint64(uint32(v[c]))*uint64(uint32(v[d]))
+	v[b] = bits.RotateLeft64(v[b]^v[c], -24)
+	v[a] = v[a] + v[b] + 2*uint64(uint32(v[a]))*uint64(uint32(v[b]))
+	v[d] = bits.RotateLeft64(v[d]^v[a], -16)
+	v[c] = v[c] + v[d] + 2*uint64(uint32(v[c]))*uint64(uint32(v[d]))
+	v[b] = bits.RotateLeft64(v[b]^v[c], -63)
+}
+
+// argon2BlockBytes serializes a block as little endian words
+func argon2BlockBytes(block *argon2Block) []byte {
+	out := make([]byte, 1024)
+	for i, v := range block {
+		binary.LittleEndian.PutUint64(out[8*i:], v)
+	}
+
+	return out
+}
+
+// EncodePasswordArgon2 encodes a password using the Argon2 algorithm
+// The encoded password is returned in the PHC string format: $argon2id$v=19$m=memory,t=passes,p=parallelism$salt$hash
+// (salt and hash are base64 encoded without padding)
+// The variant parameter is the Argon2 variant, Argon2id is recommended
+// The saltLength parameter is the length of the salt in bytes
+// The t, m and p parameters are the number of passes, the memory in KiB and the parallelism
+// The keyLength parameter is the length of the derived key in bytes
+func EncodePasswordArgon2(variant Argon2Variant, password string, saltLength, t, m, p, keyLength int64) (string, error) {
+	// generate a salt
+	saltAsBytes, err := GenerateRandomSequence(int(saltLength))
+
+	// check if an error occurred
+	if err != nil {
+		return "", fmt.Errorf("error in EncodePasswordArgon2 function while generating salt: %s", err.Error())
+	}
+
+	// encode the password
+	encodedPassword, err := Argon2(variant, []byte(password), saltAsBytes, t, m, p, keyLength)
+
+	// check if an error occurred
+	if err != nil {
+		return "", fmt.Errorf("error in EncodePasswordArgon2 function while encoding password: %s", err.Error())
+	}
+
+	// return the encoded password
+	return GenerateArgon2PasswordString(variant, saltAsBytes, t, m, p, encodedPassword), nil
+}
+
+// VerifyPasswordArgon2 checks if a password matches a password encoded by EncodePasswordArgon2
+// The password parameter is the password to be checked
+// The encodedPassword parameter is the encoded password, the variant is read from it
+// the parameters of the encoded password must fit in DefaultArgon2MaxMemory
+func VerifyPasswordArgon2(password, encodedPassword string) (bool, error) {
+	// get the password parameters
+	variant, saltAsBytes, t, m, p, passwordHash, err := GetArgon2ParametersFromString(encodedPassword)
+
+	// check if an error occurred
+	if err != nil {
+		return false, fmt.Errorf("error in VerifyPasswordArgon2 function while getting password parameters: %s", err.Error())
+	}
+
+	// encode the password
+	passwordHash2, err := Argon2(variant, []byte(password), saltAsBytes, t, m, p, int64(len(passwordHash)))
+
+	// check if an error occurred
+	if err != nil {
+		return false, fmt.Errorf("error in VerifyPasswordArgon2 function while encoding password: %s", err.Error())
+	}
+
+	// compare the hashes in constant time
+	return subtle.ConstantTimeCompare(passwordHash, passwordHash2) == 1, nil
+}
+
+// GenerateArgon2PasswordString generates an Argon2 PHC string from the given parameters
+// The variant parameter is the Argon2 variant
+// The salt parameter is the salt as a byte slice
+// The t, m and p parameters are the number of passes, the memory in KiB and the parallelism
+// The encodedPassword parameter is the derived key as a byte slice
+func GenerateArgon2PasswordString(variant Argon2Variant, salt []byte, t, m, p int64, encodedPassword []byte) string {
+	phc := &phcString{
+		id:         variant.String(),
+		hasVersion: true,
+		version:    Argon2Version,
+		params: []phcParam{
+			{"m", fmt.Sprint(m)},
+			{"t", fmt.Sprint(t)},
+			{"p", fmt.Sprint(p)},
+		},
+		salt: append([]byte{}, salt...),
+		hash: append([]byte{}, encodedPassword...),
+	}
+
+	return phc.String()
+}
+
+// GetArgon2ParametersFromString gets the Argon2 parameters from a PHC string
+// the string must be in the format: $argon2id$v=19$m=memory,t=passes,p=parallelism$salt$hash(argon2i and argon2d are accepted too)
+// the variant, salt, t, m, p and derived key are returned in this order
+func GetArgon2ParametersFromString(encodedPassword string) (Argon2Variant, []byte, int64, int64, int64, []byte, error) {
+	// parse the PHC string
+	phc, err := parsePHCString(encodedPassword)
+
+	// check if an error occurred
+	if err != nil {
+		return 0, nil, 0, 0, 0, nil, fmt.Errorf("error in GetArgon2ParametersFromString function: %s", err.Error())
+	}
+
+	// get the variant
+	variant := Argon2Variant(-1)
+	for _, v := range []Argon2Variant{Argon2d, Argon2i, Argon2id} {
+		if phc.id == v.String() {
+			variant = v
+		}
+	}
+
+	// check the format
+	if variant < 0 || phc.salt == nil || phc.hash == nil {
+		return 0, nil, 0, 0, 0, nil, errors.New("error in GetArgon2ParametersFromString function: encodedPassword must be in the format $argon2id$v=19$m=..,t=..,p=..$salt$hash")
+	}
+
+	// only version 1.3 is supported
+	if !phc.hasVersion || phc.version != Argon2Version {
+		return 0, nil, 0, 0, 0, nil, fmt.Errorf("error in GetArgon2ParametersFromString function: only version %d is supported", Argon2Version)
+	}
+
+	if err := phc.checkParams("m", "t", "p"); err != nil {
+		return 0, nil, 0, 0, 0, nil, fmt.Errorf("error in GetArgon2ParametersFromString function: %s", err.Error())
+	}
+
+	// decode the parameters
+	values := make([]int64, 3)
+	for i, name := range []string{"m", "t", "p"} {
+		if values[i], err = phc.intParam(name); err != nil {
+			return 0, nil, 0, 0, 0, nil, fmt.Errorf("error in GetArgon2ParametersFromString function: %s", err.Error())
+		}
+	}
+
+	// return the password parameters
+	return variant, phc.salt, values[1], values[0], values[2], phc.hash, nil
+}
@@ -0,0 +1,168 @@
+package pbkdf
+
+import (
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// tests the BLAKE2b hash with the RFC7693 example and the reference keyed test vectors
+func TestBLAKE2b(t *testing.T) {
+	key := make([]byte, 64)
+	message := make([]byte, 255)
+	for i := range message {
+		message[i] = byte(i)
+	}
+	copy(key, message)
+
+	tests := []struct {
+		size     int
+		key      []byte
+		message  []byte
+		expected string
+	}{
+		{64, nil, []byte("abc"), "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+		{32, nil, []byte("abc"), "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319"},
+		{64, key, nil, "10ebb67700b1868efb4417987acf4690ae9d972fb7a590c2f02871799aaa4786b5e996e8f0f4eb981fc214b005f42d2ff4233499391653df7aefcbc13fc51568"},
+		{64, key, message, "142709d62e28fcccd0af97fad0f8465b971e82201dc51070faa0372aa43e92484be1c1e73ba10906d5d1853db6a4106e0a7bf9800d373d6dee2d46d62ef2a461"},
+	}
+
+	for _, test := range tests {
+		h, err := NewBLAKE2b(test.size, test.key)
+		if err != nil {
+			t.Fatalf("error in TestBLAKE2b function while creating hash: %s", err.Error())
+		}
+
+		// write byte by byte to cross the block boundaries
+		for _, b := range test.message {
+			h.Write([]byte{b})
+		}
+
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != test.expected {
+			t.Errorf("error in TestBLAKE2b function: got %s, want %s", sum, test.expected)
+		}
+	}
+
+	// the registered hash can be used with PBKDF2
+	if _, err := PBKDF2(crypto.BLAKE2b_512, []byte("password"), []byte("salt"), 1, 64); err != nil {
+		t.Errorf("error in TestBLAKE2b function: BLAKE2b-512 is not usable with PBKDF2(%s)", err.Error())
+	}
+}
+
+// tests the EncodePasswordArgon2 and VerifyPasswordArgon2 functions and the parameter checks
+func TestEncodeVerifyPasswordArgon2(t *testing.T) {
+	for _, variant := range []Argon2Variant{Argon2d, Argon2i, Argon2id} {
+		encoded, err := EncodePasswordArgon2(variant, "password", 16, 2, 64, 2, 32)
+		if err != nil {
+			t.Fatalf("error in TestEncodeVerifyPasswordArgon2 function while encoding password: %s", err.Error())
+		}
+
+		// the parameters are stored in the string
+		variant2, salt, passes, memory, parallelism, key, err := GetArgon2ParametersFromString(encoded)
+		if err != nil || variant2 != variant || len(salt) != 16 || passes != 2 || memory != 64 || parallelism != 2 || len(key) != 32 {
+			t.Fatalf("error in TestEncodeVerifyPasswordArgon2 function: unexpected parameters in %q(%v)", encoded, err)
+		}
+
+		if valid, err := VerifyPasswordArgon2("password", encoded); err != nil || !valid {
+			t.Errorf("error in TestEncodeVerifyPasswordArgon2 function: encoded password is not valid(%v)", err)
+		}
+
+		if valid, err := VerifyPasswordArgon2("Password", encoded); err != nil || valid {
+			t.Errorf("error in TestEncodeVerifyPasswordArgon2 function: wrong password is valid(%v)", err)
+		}
+	}
+
+	// invalid and oversized parameters are refused before any memory is allocated
+	for _, params := range [][3]int64{{0, 64, 1}, {1, 7, 1}, {1, 64, 0}, {1, 64, 9}, {1, 1 << 32, 1}, {1, 1<<32 - 1, 1}} {
+		if _, err := Argon2(Argon2id, []byte("password"), []byte("somesalt"), params[0], params[1], params[2], 32); err == nil {
+			t.Errorf("error in TestEncodeVerifyPasswordArgon2 function: parameters %v were accepted", params)
+		}
+	}
+
+	if _, err := Argon2(Argon2id, []byte("password"), []byte("salt"), 1, 64, 1, 32); err == nil {
+		t.Errorf("error in TestEncodeVerifyPasswordArgon2 function: short salt was accepted")
+	}
+
+	// only version 19 is accepted
+	for _, encoded := range []string{
+		"$argon2id$m=64,t=1,p=1$c29tZXNhbHQ$a2V5a2V5",
+		"$argon2id$v=16$m=64,t=1,p=1$c29tZXNhbHQ$a2V5a2V5",
+		"$argon2id$v=19$t=1,m=64,p=1$c29tZXNhbHQ$a2V5a2V5",
+		"$argon2x$v=19$m=64,t=1,p=1$c29tZXNhbHQ$a2V5a2V5",
+	} {
+		if _, err := VerifyPasswordArgon2("password", encoded); err == nil {
+			t.Errorf("error in TestEncodeVerifyPasswordArgon2 function: %q was accepted", encoded)
+		}
+	}
+
+	// Argon2 is refused in compliance mode
+	if err := EnableComplianceMode(DefaultCompliancePolicy()); err != nil {
+		t.Fatalf("error in TestEncodeVerifyPasswordArgon2 function while enabling compliance mode: %s", err.Error())
+	}
+	defer DisableComplianceMode()
+
+	if _, err := Argon2(Argon2id, []byte("password"), make([]byte, 16), 1, 64, 1, 32); !errors.Is(err, ErrNotCompliant) {
+		t.Errorf("error in TestEncodeVerifyPasswordArgon2 function: Argon2 was accepted in compliance mode(%v)", err)
+	}
+}
+
+// tests the migration of a Hasher from PBKDF2 to Argon2id by changing its options
+func TestHasherArgon2Migration(t *testing.T) {
+	oldHasher, err := NewHasher(WithIterationCount(1000))
+	if err != nil {
+		t.Fatalf("error in TestHasherArgon2Migration function while creating hasher: %s", err.Error())
+	}
+
+	newHasher, err := NewHasher(WithIterationCount(1000), WithArgon2id(1, 64, 1))
+	if err != nil {
+		t.Fatalf("error in TestHasherArgon2Migration function while creating hasher: %s", err.Error())
+	}
+
+	oldEncoded, err := oldHasher.Hash("password")
+	if err != nil {
+		t.Fatalf("error in TestHasherArgon2Migration function while hashing password: %s", err.Error())
+	}
+
+	// the new hasher verifies the old password and asks for a rehash
+	if valid, err := newHasher.Verify("password", oldEncoded); err != nil || !valid {
+		t.Errorf("error in TestHasherArgon2Migration function: old password is not valid(%v)", err)
+	}
+
+	if !newHasher.NeedsRehash(oldEncoded) || oldHasher.NeedsRehash(oldEncoded) {
+		t.Errorf("error in TestHasherArgon2Migration function: wrong NeedsRehash result for %q", oldEncoded)
+	}
+
+	// the rehashed password is an Argon2id PHC string
+	newEncoded, err := newHasher.Hash("password")
+	if err != nil {
+		t.Fatalf("error in TestHasherArgon2Migration function while hashing password: %s", err.Error())
+	}
+
+	if variant, _, _, _, _, _, err := GetArgon2ParametersFromString(newEncoded); err != nil || variant != Argon2id {
+		t.Errorf("error in TestHasherArgon2Migration function: %q is not an Argon2id string(%v)", newEncoded, err)
+	}
+
+	if valid, err := newHasher.Verify("password", newEncoded); err != nil || !valid {
+		t.Errorf("error in TestHasherArgon2Migration function: new password is not valid(%v)", err)
+	}
+
+	if newHasher.NeedsRehash(newEncoded) || !oldHasher.NeedsRehash(newEncoded) {
+		t.Errorf("error in TestHasherArgon2Migration function: wrong NeedsRehash result for %q", newEncoded)
+	}
+
+	// changing a parameter asks for a rehash again
+	strongerHasher, err := NewHasher(WithArgon2id(2, 64, 1))
+	if err != nil {
+		t.Fatalf("error in TestHasherArgon2Migration function while creating hasher: %s", err.Error())
+	}
+
+	if !strongerHasher.NeedsRehash(newEncoded) {
+		t.Errorf("error in TestHasherArgon2Migration function: parameter change does not need a rehash")
+	}
+
+	// invalid options are refused
+	if _, err := NewHasher(WithArgon2id(1, 4, 1)); err == nil {
+		t.Errorf("error in TestHasherArgon2Migration function: invalid Argon2 memory was accepted")
+	}
+}
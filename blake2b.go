@@ -0,0 +1,211 @@
+package pbkdf
+
+import (
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"math/bits"
+)
+
+// BLAKE2b constants
+const (
+	// BLAKE2bSize is the maximum BLAKE2b digest size in bytes
+	BLAKE2bSize = 64
+	// BLAKE2bBlockSize is the BLAKE2b block size in bytes
+	BLAKE2bBlockSize = 128
+	// BLAKE2bMaxKeySize is the maximum BLAKE2b key size in bytes
+	BLAKE2bMaxKeySize = 64
+)
+
+// BLAKE2b initialization vector, the same as SHA-512
+var blake2bIV = [8]uint64{
+	0x6a09e667f3bcc908, 0xbb67ae8584caa73b, 0x3c6ef372fe94f82b, 0xa54ff53a5f1d36f1,
+	0x510e527fade682d1, 0x9b05688c2b3e6c1f, 0x1f83d9abfb41bd6b, 0x5be0cd19137e2179,
+}
+
+// BLAKE2b message word permutations, rounds 10 and 11 reuse the first two
+var blake2bSigma = [12][16]byte{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+	{11, 8, 12, 0, 5, 2, 15, 13, 10, 14, 3, 6, 7, 1, 9, 4},
+	{7, 9, 3, 1, 13, 12, 11, 14, 2, 6, 5, 10, 4, 0, 15, 8},
+	{9, 0, 5, 7, 2, 4, 10, 15, 14, 1, 11, 12, 6, 8, 3, 13},
+	{2, 12, 6, 10, 0, 11, 8, 3, 4, 13, 7, 5, 15, 14, 1, 9},
+	{12, 5, 1, 15, 14, 13, 4, 10, 0, 7, 6, 3, 9, 2, 8, 11},
+	{13, 11, 7, 14, 12, 1, 3, 9, 5, 0, 15, 4, 8, 6, 2, 10},
+	{6, 15, 14, 9, 11, 3, 0, 8, 12, 2, 13, 7, 1, 4, 10, 5},
+	{10, 2, 8, 4, 7, 6, 1, 5, 15, 11, 9, 14, 3, 12, 13, 0},
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+}
+
+// register the fixed size BLAKE2b variants so they can be used as a crypto.Hash(for example with PBKDF2)
+func init() {
+	crypto.RegisterHash(crypto.BLAKE2b_256, func() hash.Hash { h, _ := NewBLAKE2b(32, nil); return h })
+	crypto.RegisterHash(crypto.BLAKE2b_384, func() hash.Hash { h, _ := NewBLAKE2b(48, nil); return h })
+	crypto.RegisterHash(crypto.BLAKE2b_512, func() hash.Hash { h, _ := NewBLAKE2b(64, nil); return h })
+}
+
+// blake2b is the BLAKE2b hash of RFC7693(https://datatracker.ietf.org/doc/html/rfc7693)
+type blake2b struct {
+	h      [8]uint64
+	t      [2]uint64
+	buf    [BLAKE2bBlockSize]byte
+	offset int
+	size   int
+	key    []byte
+}
+
+// NewBLAKE2b creates a BLAKE2b hash
+// The size parameter is the digest size in bytes, between 1 and BLAKE2bSize
+// The key parameter is the optional key(nil for unkeyed hashing), at most BLAKE2bMaxKeySize bytes
+func NewBLAKE2b(size int, key []byte) (hash.Hash, error) {
+	// check the size
+	if size < 1 || size > BLAKE2bSize {
+		return nil, errors.New("error in NewBLAKE2b function: size must be between 1 and 64")
+	}
+
+	// check the key
+	if len(key) > BLAKE2bMaxKeySize {
+		return nil, errors.New("error in NewBLAKE2b function: key must be at most 64 bytes")
+	}
+
+	// create the hash
+	b := &blake2b{size: size, key: append([]byte{}, key...)}
+	b.Reset()
+
+	return b, nil
+}
+
+// blake2bSum returns the BLAKE2b digest of data with the given size, size must be valid
+func blake2bSum(size int, data ...[]byte) []byte {
+	h, _ := NewBLAKE2b(size, nil)
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return h.Sum(nil)
+}
+
+// Reset resets the hash to its initial state
+func (b *blake2b) Reset() {
+	// h = IV xor parameter block(digest length, key length, fanout and depth of 1)
+	b.h = blake2bIV
+	b.h[0] ^= uint64(b.size) | uint64(len(b.key))<<8 | 1<<16 | 1<<24
+	b.t = [2]uint64{}
+	b.offset = 0
+
+	// a keyed hash starts with the key padded to a full block
+	if len(b.key) > 0 {
+		b.buf = [BLAKE2bBlockSize]byte{}
+		copy(b.buf[:], b.key)
+		b.offset = BLAKE2bBlockSize
+	}
+}
+
+// Size returns the digest size
+func (b *blake2b) Size() int {
+	return b.size
+}
+
+// BlockSize returns the block size
+func (b *blake2b) BlockSize() int {
+	return BLAKE2bBlockSize
+}
+
+// Write adds data to the hash
+// the last block is kept in the buffer because it must be compressed with the final flag
+func (b *blake2b) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		// the buffer is full and more data follows, compress it
+		if b.offset == BLAKE2bBlockSize {
+			b.compress(BLAKE2bBlockSize, false)
+			b.offset = 0
+		}
+
+		// fill the buffer
+		copied := copy(b.buf[b.offset:], p)
+		b.offset += copied
+		p = p[copied:]
+	}
+
+	return n, nil
+}
+
+// Sum appends the digest to in without changing the state
+func (b *blake2b) Sum(in []byte) []byte {
+	// work on a copy so more data can be written
+	c := *b
+
+	// pad and compress the last block
+	for i := c.offset; i < BLAKE2bBlockSize; i++ {
+		c.buf[i] = 0
+	}
+	c.compress(c.offset, true)
+
+	// serialize the state
+	var digest [BLAKE2bSize]byte
+	for i, v := range c.h {
+		binary.LittleEndian.PutUint64(digest[8*i:], v)
+	}
+
+	return append(in, digest[:c.size]...)
+}
+
+// compress runs the BLAKE2b compression function F on the buffer
+// n is the number of message bytes in the buffer, the rest is already zero padded
+func (b *blake2b) compress(n int, last bool) {
+	// increment the counter by the number of message bytes
+	b.t[0] += uint64(n)
+	if b.t[0] < uint64(n) {
+		b.t[1]++
+	}
+
+	// load the message words from the padded buffer
+	var m [16]uint64
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint64(b.buf[8*i:])
+	}
+
+	// initialize the work vector
+	var v [16]uint64
+	copy(v[:8], b.h[:])
+	copy(v[8:], blake2bIV[:])
+	v[12] ^= b.t[0]
+	v[13] ^= b.t[1]
+	if last {
+		v[14] = ^v[14]
+	}
+
+	// 12 rounds of mixing
+	for _, s := range blake2bSigma {
+		blake2bG(&v, 0, 4, 8, 12, m[s[0]], m[s[1]])
+		blake2bG(&v, 1, 5, 9, 13, m[s[2]], m[s[3]])
+		blake2bG(&v, 2, 6, 10, 14, m[s[4]], m[s[5]])
+		blake2bG(&v, 3, 7, 11, 15, m[s[6]], m[s[7]])
+		blake2bG(&v, 0, 5, 10, 15, m[s[8]], m[s[9]])
+		blake2bG(&v, 1, 6, 11, 12, m[s[10]], m[s[11]])
+		blake2bG(&v, 2, 7, 8, 13, m[s[12]], m[s[13]])
+		blake2bG(&v, 3, 4, 9, 14, m[s[14]], m[s[15]])
+	}
+
+	// update the state
+	for i := range b.h {
+		b.h[i] ^= v[i] ^ v[i+8]
+	}
+}
+
+// blake2bG is the BLAKE2b mixing function G
+func blake2bG(v *[16]uint64, a, b, c, d int, x, y uint64) {
+	v[a] = v[a] + v[b] + x
+	v[d] = bits.RotateLeft64(v[d]^v[a], -32)
+	v[c] = v[c] + v[d]
+	v[b] = bits.RotateLeft64(v[b]^v[c], -24)
+	v[a] = v[a] + v[b] + y
+	v[d] = bits.RotateLeft64(v[d]^v[a], -16)
+	v[c] = v[c] + v[d]
+	v[b] = bits.RotateLeft64(v[b]^v[c], -63)
+}
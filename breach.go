@@ -0,0 +1,339 @@
+package pbkdf
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// ErrBreachedPassword is returned by BreachedPasswordChecker.ValidatePassword
+// when the password appears in the breached password file
+var ErrBreachedPassword = errors.New("password appears in a list of breached passwords")
+
+// compact breached password index layout
+// the file starts with breachedIndexMagic followed by fixed size records sorted by hash
+// each record is the 20 byte SHA-1 digest followed by the count as a big endian uint32
+const (
+	breachedIndexMagic      = "PBKHIBP1"
+	breachedIndexRecordSize = sha1.Size + 4
+)
+
+// BreachedPasswordChecker checks passwords against a local copy of a breached password list
+// It accepts either the Pwned Passwords "SHA1:count" text download(ordered by hash)
+// or the compact index produced by ConvertBreachedPasswordFile, the format is detected automatically
+// The file is memory mapped and searched with binary search, so it is never loaded into the heap
+// A BreachedPasswordChecker is safe for concurrent use until Close is called
+type BreachedPasswordChecker struct {
+	// MinimumCount is the number of times a password must appear in the file to be rejected
+	// a value lower than 1 rejects any password present in the file
+	MinimumCount int64
+
+	data    []byte
+	compact bool
+	unmap   func() error
+}
+
+// OpenBreachedPasswordChecker opens a breached password file
+// The path parameter is the path to a sorted "SHA1:count" text file or a compact index
+func OpenBreachedPasswordChecker(path string) (*BreachedPasswordChecker, error) {
+	// open the file
+	f, err := os.Open(path)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in OpenBreachedPasswordChecker function while opening file: %s", err.Error())
+	}
+
+	// the mapping stays valid after the file is closed
+	defer f.Close()
+
+	// map the file into memory
+	data, unmap, err := mapFile(f)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in OpenBreachedPasswordChecker function while mapping file: %s", err.Error())
+	}
+
+	// create the checker
+	c := &BreachedPasswordChecker{data: data, unmap: unmap}
+
+	// detect the compact index format
+	if bytes.HasPrefix(data, []byte(breachedIndexMagic)) {
+		// check the record alignment
+		if (len(data)-len(breachedIndexMagic))%breachedIndexRecordSize != 0 {
+			unmap()
+			return nil, errors.New("error in OpenBreachedPasswordChecker function: compact index is truncated")
+		}
+
+		c.compact = true
+	}
+
+	// return the checker
+	return c, nil
+}
+
+// Close releases the memory mapping
+// the checker must not be used after Close
+func (c *BreachedPasswordChecker) Close() error {
+	// check if the checker was already closed
+	if c.unmap == nil {
+		return nil
+	}
+
+	// release the mapping
+	err := c.unmap()
+	c.unmap = nil
+	c.data = nil
+
+	// check if an error occurred
+	if err != nil {
+		return fmt.Errorf("error in BreachedPasswordChecker.Close method: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Count returns how many times the password appears in the breached password file
+// The password parameter is the password to look up
+// zero is returned if the password is not present
+func (c *BreachedPasswordChecker) Count(password string) (int64, error) {
+	return c.CountSHA1(sha1.Sum([]byte(password)))
+}
+
+// CountSHA1 returns how many times the password with the given SHA-1 digest appears in the file
+// The digest parameter is the SHA-1 digest of the password
+// zero is returned if the digest is not present
+func (c *BreachedPasswordChecker) CountSHA1(digest [sha1.Size]byte) (int64, error) {
+	// check if the checker is closed
+	if c.unmap == nil {
+		return 0, errors.New("error in BreachedPasswordChecker.CountSHA1 method: checker is closed")
+	}
+
+	// search the compact or text format
+	if c.compact {
+		return c.searchCompact(digest), nil
+	}
+
+	return c.searchText(digest)
+}
+
+// ValidatePassword implements the PasswordValidator interface
+// it returns an error wrapping ErrBreachedPassword if the password appears at least MinimumCount times
+func (c *BreachedPasswordChecker) ValidatePassword(password string) error {
+	// look up the password
+	count, err := c.Count(password)
+
+	// check if an error occurred
+	if err != nil {
+		return fmt.Errorf("error in BreachedPasswordChecker.ValidatePassword method: %s", err.Error())
+	}
+
+	// compare the count with the minimum count
+	if count > 0 && count >= c.MinimumCount {
+		return fmt.Errorf("error in BreachedPasswordChecker.ValidatePassword method: %w (seen %d times)", ErrBreachedPassword, count)
+	}
+
+	return nil
+}
+
+// searchCompact runs a binary search over the fixed size records of a compact index
+func (c *BreachedPasswordChecker) searchCompact(digest [sha1.Size]byte) int64 {
+	// records start right after the magic
+	records := c.data[len(breachedIndexMagic):]
+
+	// binary search over the records
+	lo, hi := 0, len(records)/breachedIndexRecordSize
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		record := records[mid*breachedIndexRecordSize : (mid+1)*breachedIndexRecordSize]
+
+		// compare the record hash with the digest
+		switch cmp := bytes.Compare(record[:sha1.Size], digest[:]); {
+		case cmp == 0:
+			return int64(ConvertSliceToUnsignedInteger(record[sha1.Size:], false))
+		case cmp < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+
+	// digest not found
+	return 0
+}
+
+// searchText runs a binary search over the variable length lines of a "SHA1:count" file
+// lo and hi always point to the start of a line, blank lines are skipped like ConvertBreachedPasswordFile does
+func (c *BreachedPasswordChecker) searchText(digest [sha1.Size]byte) (int64, error) {
+	// encode the digest the same way it is written in the file
+	target := make([]byte, hex.EncodedLen(sha1.Size))
+	hex.Encode(target, digest[:])
+	target = bytes.ToUpper(target)
+
+	// binary search over the lines
+	lo, hi := 0, len(c.data)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		// find the start of the line containing mid
+		start := mid
+		for start > lo && c.data[start-1] != '\n' {
+			start--
+		}
+
+		// find the end of the line containing mid
+		end := mid
+		for end < len(c.data) && c.data[end] != '\n' {
+			end++
+		}
+
+		// skip blank lines forward to the next line of the range
+		lineStart := start
+		for len(bytes.TrimSpace(c.data[lineStart:end])) == 0 && end+1 < hi {
+			lineStart = end + 1
+			for end = lineStart; end < len(c.data) && c.data[end] != '\n'; end++ {
+			}
+		}
+
+		// the rest of the range is blank, search before it
+		if len(bytes.TrimSpace(c.data[lineStart:end])) == 0 {
+			hi = start
+			continue
+		}
+
+		// parse the line
+		lineHash, count, err := parseBreachedLine(c.data[lineStart:end])
+
+		// check if an error occurred
+		if err != nil {
+			return 0, fmt.Errorf("error in BreachedPasswordChecker.searchText method at offset %d: %s", lineStart, err.Error())
+		}
+
+		// compare the line hash with the target, the blank lines before the line are on the upper side
+		switch cmp := bytes.Compare(bytes.ToUpper(lineHash), target); {
+		case cmp == 0:
+			return count, nil
+		case cmp < 0:
+			lo = end + 1
+		default:
+			hi = start
+		}
+	}
+
+	// digest not found
+	return 0, nil
+}
+
+// parseBreachedLine splits a "SHA1:count" line into the hex hash and the count
+// a missing count is treated as 1 so plain lists of hashes are accepted
+func parseBreachedLine(line []byte) ([]byte, int64, error) {
+	// remove the carriage return of CRLF files and surrounding spaces
+	line = bytes.TrimSpace(line)
+
+	// check the hash length
+	if len(line) < hex.EncodedLen(sha1.Size) {
+		return nil, 0, fmt.Errorf("malformed line %q", line)
+	}
+
+	// split the hash and the count
+	lineHash, rest := line[:hex.EncodedLen(sha1.Size)], line[hex.EncodedLen(sha1.Size):]
+	if len(rest) == 0 {
+		return lineHash, 1, nil
+	}
+
+	// check the separator
+	if rest[0] != ':' {
+		return nil, 0, fmt.Errorf("malformed line %q", line)
+	}
+
+	// parse the count
+	count, err := strconv.ParseInt(string(rest[1:]), 10, 64)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, 0, fmt.Errorf("malformed count in line %q: %s", line, err.Error())
+	}
+
+	return lineHash, count, nil
+}
+
+// ConvertBreachedPasswordFile converts a "SHA1:count" text file into the compact index format
+// The src parameter is the text file, it must be sorted by hash(the "ordered by hash" download)
+// The dst parameter receives the compact index
+// counts larger than the maximum uint32 are saturated
+// the number of records written is returned
+func ConvertBreachedPasswordFile(src io.Reader, dst io.Writer) (int64, error) {
+	// buffer the output
+	w := bufio.NewWriter(dst)
+
+	// write the magic
+	if _, err := w.WriteString(breachedIndexMagic); err != nil {
+		return 0, fmt.Errorf("error in ConvertBreachedPasswordFile function while writing header: %s", err.Error())
+	}
+
+	// read the input line by line
+	scanner := bufio.NewScanner(src)
+	record := make([]byte, breachedIndexRecordSize)
+	previous := make([]byte, sha1.Size)
+	records := int64(0)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		// skip blank lines
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		// parse the line
+		lineHash, count, err := parseBreachedLine(scanner.Bytes())
+
+		// check if an error occurred
+		if err != nil {
+			return records, fmt.Errorf("error in ConvertBreachedPasswordFile function on line %d: %s", lineNumber, err.Error())
+		}
+
+		// decode the hash into the record
+		if _, err := hex.Decode(record[:sha1.Size], lineHash); err != nil {
+			return records, fmt.Errorf("error in ConvertBreachedPasswordFile function on line %d while decoding hash: %s", lineNumber, err.Error())
+		}
+
+		// check the ordering, binary search depends on it
+		if records > 0 && bytes.Compare(previous, record[:sha1.Size]) >= 0 {
+			return records, fmt.Errorf("error in ConvertBreachedPasswordFile function on line %d: input is not sorted by hash", lineNumber)
+		}
+		copy(previous, record[:sha1.Size])
+
+		// saturate the count
+		if count > 1<<32-1 {
+			count = 1<<32 - 1
+		} else if count < 0 {
+			count = 0
+		}
+		copy(record[sha1.Size:], ConvertUnsignedIntegerToByteSlice(uint64(count), 4, false))
+
+		// write the record
+		if _, err := w.Write(record); err != nil {
+			return records, fmt.Errorf("error in ConvertBreachedPasswordFile function while writing record: %s", err.Error())
+		}
+		records++
+	}
+
+	// check if an error occurred while reading
+	if err := scanner.Err(); err != nil {
+		return records, fmt.Errorf("error in ConvertBreachedPasswordFile function while reading input: %s", err.Error())
+	}
+
+	// flush the output
+	if err := w.Flush(); err != nil {
+		return records, fmt.Errorf("error in ConvertBreachedPasswordFile function while writing output: %s", err.Error())
+	}
+
+	// return the number of records
+	return records, nil
+}
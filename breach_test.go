@@ -0,0 +1,139 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeBreachedFile writes a sorted "SHA1:count" file with the given passwords and returns its path
+func writeBreachedFile(t *testing.T, passwords map[string]int) string {
+	// build the lines
+	lines := make([]string, 0, len(passwords))
+	for password, count := range passwords {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), count))
+	}
+
+	// sort by hash like the Pwned Passwords download
+	sort.Strings(lines)
+
+	// write the file with CRLF line endings
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600); err != nil {
+		t.Fatalf("error in writeBreachedFile function: %s", err.Error())
+	}
+
+	return path
+}
+
+// tests the BreachedPasswordChecker with the text and the compact formats
+func TestBreachedPasswordChecker(t *testing.T) {
+	// build a breached list with a few hundred random passwords and some well known ones
+	breached := map[string]int{"password": 9545824, "123456": 37359195, "letmein": 1}
+	for i := 0; i < 300; i++ {
+		password, err := GenerateRandomPassword(8, 16)
+
+		// check for error on password generation
+		if err != nil {
+			t.Fatalf("error in TestBreachedPasswordChecker function while generating password: %s", err.Error())
+		}
+
+		breached[password] = i + 1
+	}
+
+	textPath := writeBreachedFile(t, breached)
+
+	// convert the text file into a compact index
+	src, err := os.Open(textPath)
+	if err != nil {
+		t.Fatalf("error in TestBreachedPasswordChecker function while opening text file: %s", err.Error())
+	}
+	defer src.Close()
+
+	var compact bytes.Buffer
+	records, err := ConvertBreachedPasswordFile(src, &compact)
+	if err != nil || records != int64(len(breached)) {
+		t.Fatalf("error in TestBreachedPasswordChecker function while converting: %d records, %v", records, err)
+	}
+
+	compactPath := filepath.Join(t.TempDir(), "pwned.idx")
+	if err := os.WriteFile(compactPath, compact.Bytes(), 0o600); err != nil {
+		t.Fatalf("error in TestBreachedPasswordChecker function while writing index: %s", err.Error())
+	}
+
+	for _, path := range []string{textPath, compactPath} {
+		checker, err := OpenBreachedPasswordChecker(path)
+
+		// check for error on open
+		if err != nil {
+			t.Fatalf("error in TestBreachedPasswordChecker function while opening %s: %s", path, err.Error())
+		}
+
+		// every breached password must be found with its count
+		for password, want := range breached {
+			if got, err := checker.Count(password); err != nil || got != int64(want) {
+				t.Errorf("error in TestBreachedPasswordChecker function: count of %q is %d(%v), want %d", password, got, err, want)
+			}
+		}
+
+		// passwords that are not in the list must not be found
+		if got, err := checker.Count("correct horse battery staple 42"); err != nil || got != 0 {
+			t.Errorf("error in TestBreachedPasswordChecker function: unexpected count %d(%v)", got, err)
+		}
+
+		// the checker plugs into the Hasher as a pre-hash validation step
+		hasher, err := NewHasher(WithIterationCount(1000), WithPasswordValidator(checker))
+		if err != nil {
+			t.Fatalf("error in TestBreachedPasswordChecker function while creating hasher: %s", err.Error())
+		}
+
+		if _, err := hasher.Hash("password"); !errors.Is(err, ErrBreachedPassword) {
+			t.Errorf("error in TestBreachedPasswordChecker function: breached password was not rejected: %v", err)
+		}
+
+		if _, err := hasher.Hash("correct horse battery staple 42"); err != nil {
+			t.Errorf("error in TestBreachedPasswordChecker function: safe password was rejected: %s", err.Error())
+		}
+
+		// the minimum count allows rare passwords
+		checker.MinimumCount = 10
+		if err := checker.ValidatePassword("letmein"); err != nil {
+			t.Errorf("error in TestBreachedPasswordChecker function: password below minimum count was rejected: %s", err.Error())
+		}
+
+		if err := checker.Close(); err != nil {
+			t.Errorf("error in TestBreachedPasswordChecker function while closing: %s", err.Error())
+		}
+	}
+
+	// unsorted input must be refused by the converter
+	if _, err := ConvertBreachedPasswordFile(strings.NewReader(strings.Repeat("F", 40)+":1\n"+strings.Repeat("0", 40)+":1\n"), &bytes.Buffer{}); err == nil {
+		t.Errorf("error in TestBreachedPasswordChecker function: unsorted input was accepted")
+	}
+
+	// blank lines anywhere in the text format are skipped like the converter does
+	data, _ := os.ReadFile(textPath)
+	lines := strings.Split(string(data), "\r\n")
+	for i := len(lines) - 1; i >= 0; i -= 7 {
+		lines = append(lines[:i], append([]string{"", " \r", ""}[:i%3+1], lines[i:]...)...)
+	}
+
+	checker := &BreachedPasswordChecker{data: []byte("\n" + strings.Join(lines, "\n") + "\n\n"), unmap: func() error { return nil }}
+	for password, want := range breached {
+		if got, err := checker.Count(password); err != nil || got != int64(want) {
+			t.Errorf("error in TestBreachedPasswordChecker function: count of %q with blank lines is %d(%v), want %d", password, got, err, want)
+		}
+	}
+
+	if got, err := checker.Count("correct horse battery staple 42"); err != nil || got != 0 {
+		t.Errorf("error in TestBreachedPasswordChecker function: unexpected count %d(%v) with blank lines", got, err)
+	}
+}
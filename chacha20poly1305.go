@@ -0,0 +1,276 @@
+package pbkdf
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// ChaCha20-Poly1305 sizes in bytes
+const (
+	// ChaCha20Poly1305KeySize is the key size
+	ChaCha20Poly1305KeySize = 32
+	// ChaCha20Poly1305NonceSize is the nonce size
+	ChaCha20Poly1305NonceSize = 12
+	// ChaCha20Poly1305Overhead is the size of the Poly1305 tag
+	ChaCha20Poly1305Overhead = 16
+)
+
+// maximum plaintext size, the 32 bit block counter starts at 1
+const chacha20Poly1305MaxPlaintext = (1<<32 - 1) * 64
+
+// chacha20Poly1305 is the ChaCha20-Poly1305 AEAD of RFC8439(https://datatracker.ietf.org/doc/html/rfc8439)
+type chacha20Poly1305 struct {
+	key [8]uint32
+}
+
+// NewChaCha20Poly1305 creates the ChaCha20-Poly1305 AEAD
+// It is based on the RFC8439(https://datatracker.ietf.org/doc/html/rfc8439), it is a pure Go implementation
+// The key parameter is the 32 byte key
+func NewChaCha20Poly1305(key []byte) (cipher.AEAD, error) {
+	if len(key) != ChaCha20Poly1305KeySize {
+		return nil, errors.New("error in NewChaCha20Poly1305 function: key must be 32 bytes")
+	}
+
+	c := &chacha20Poly1305{}
+	for i := range c.key {
+		c.key[i] = binary.LittleEndian.Uint32(key[4*i:])
+	}
+
+	return c, nil
+}
+
+// NonceSize returns the nonce size
+func (c *chacha20Poly1305) NonceSize() int {
+	return ChaCha20Poly1305NonceSize
+}
+
+// Overhead returns the tag size
+func (c *chacha20Poly1305) Overhead() int {
+	return ChaCha20Poly1305Overhead
+}
+
+// Seal encrypts and authenticates plaintext, authenticates additionalData and appends the result to dst
+func (c *chacha20Poly1305) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != ChaCha20Poly1305NonceSize {
+		panic("pbkdf: ChaCha20-Poly1305 nonce must be 12 bytes")
+	}
+
+	if uint64(len(plaintext)) > chacha20Poly1305MaxPlaintext {
+		panic("pbkdf: ChaCha20-Poly1305 plaintext too long")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+ChaCha20Poly1305Overhead)
+	ciphertext, tag := out[:len(plaintext)], out[len(plaintext):]
+
+	// encrypt with the counter starting at 1, block 0 is the Poly1305 key
+	polyKey := c.xorKeyStream(ciphertext, plaintext, nonce)
+	poly1305Tag(tag, polyKey, additionalData, ciphertext)
+
+	return ret
+}
+
+// Open authenticates and decrypts ciphertext, authenticates additionalData and appends the plaintext to dst
+func (c *chacha20Poly1305) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != ChaCha20Poly1305NonceSize {
+		panic("pbkdf: ChaCha20-Poly1305 nonce must be 12 bytes")
+	}
+
+	if len(ciphertext) < ChaCha20Poly1305Overhead || uint64(len(ciphertext)-ChaCha20Poly1305Overhead) > chacha20Poly1305MaxPlaintext {
+		return nil, errors.New("pbkdf: message authentication failed")
+	}
+
+	tag := ciphertext[len(ciphertext)-ChaCha20Poly1305Overhead:]
+	ciphertext = ciphertext[:len(ciphertext)-ChaCha20Poly1305Overhead]
+
+	// check the tag before decrypting
+	polyKey := c.block(make([]byte, 0, 64), nonce, 0)[:32]
+
+	var expected [16]byte
+	poly1305Tag(expected[:], polyKey, additionalData, ciphertext)
+
+	if subtle.ConstantTimeCompare(expected[:], tag) != 1 {
+		return nil, errors.New("pbkdf: message authentication failed")
+	}
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	c.xorKeyStream(out, ciphertext, nonce)
+
+	return ret, nil
+}
+
+// xorKeyStream xors src with the key stream starting at block 1 and returns the Poly1305 key from block 0
+func (c *chacha20Poly1305) xorKeyStream(dst, src, nonce []byte) []byte {
+	polyKey := c.block(make([]byte, 0, 64), nonce, 0)[:32]
+
+	var keyStream [64]byte
+	for counter := uint32(1); len(src) > 0; counter++ {
+		c.block(keyStream[:0], nonce, counter)
+		n := subtle.XORBytes(dst, src, keyStream[:])
+		dst, src = dst[n:], src[n:]
+	}
+
+	return polyKey
+}
+
+// block appends the 64 byte ChaCha20 block of the counter to out
+func (c *chacha20Poly1305) block(out []byte, nonce []byte, counter uint32) []byte {
+	// "expand 32-byte k", key, counter, nonce
+	var state [16]uint32
+	state[0], state[1], state[2], state[3] = 0x61707865, 0x3320646e, 0x79622d32, 0x6b206574
+	copy(state[4:12], c.key[:])
+	state[12] = counter
+	state[13] = binary.LittleEndian.Uint32(nonce[0:])
+	state[14] = binary.LittleEndian.Uint32(nonce[4:])
+	state[15] = binary.LittleEndian.Uint32(nonce[8:])
+
+	x := state
+
+	// 20 rounds, column rounds then diagonal rounds
+	for i := 0; i < 10; i++ {
+		chacha20QuarterRound(&x, 0, 4, 8, 12)
+		chacha20QuarterRound(&x, 1, 5, 9, 13)
+		chacha20QuarterRound(&x, 2, 6, 10, 14)
+		chacha20QuarterRound(&x, 3, 7, 11, 15)
+		chacha20QuarterRound(&x, 0, 5, 10, 15)
+		chacha20QuarterRound(&x, 1, 6, 11, 12)
+		chacha20QuarterRound(&x, 2, 7, 8, 13)
+		chacha20QuarterRound(&x, 3, 4, 9, 14)
+	}
+
+	for i := range x {
+		out = binary.LittleEndian.AppendUint32(out, x[i]+state[i])
+	}
+
+	return out
+}
+
+// chacha20QuarterRound is the ChaCha quarter round on the words a, b, c and d of the state
+func chacha20QuarterRound(x *[16]uint32, a, b, c, d int) {
+	x[a] += x[b]
+	x[d] = bits.RotateLeft32(x[d]^x[a], 16)
+	x[c] += x[d]
+	x[b] = bits.RotateLeft32(x[b]^x[c], 12)
+	x[a] += x[b]
+	x[d] = bits.RotateLeft32(x[d]^x[a], 8)
+	x[c] += x[d]
+	x[b] = bits.RotateLeft32(x[b]^x[c], 7)
+}
+
+// poly1305Tag writes to tag the Poly1305 MAC of additionalData || pad16 || ciphertext || pad16 || len(additionalData) || len(ciphertext)
+func poly1305Tag(tag []byte, key []byte, additionalData, ciphertext []byte) {
+	var p poly1305
+	p.init(key)
+	p.writePadded(additionalData)
+	p.writePadded(ciphertext)
+
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[0:], uint64(len(additionalData)))
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(ciphertext)))
+	p.writePadded(lengths[:])
+
+	p.sum(tag)
+}
+
+// poly1305 is the Poly1305 one-time authenticator, h is the 130 bit accumulator in three 64 bit limbs
+type poly1305 struct {
+	r0, r1 uint64
+	s0, s1 uint64
+	h0, h1 uint64
+	h2     uint64
+}
+
+// init clamps r and sets s from the 32 byte one-time key
+func (p *poly1305) init(key []byte) {
+	p.r0 = binary.LittleEndian.Uint64(key[0:]) & 0x0FFFFFFC0FFFFFFF
+	p.r1 = binary.LittleEndian.Uint64(key[8:]) & 0x0FFFFFFC0FFFFFFC
+	p.s0 = binary.LittleEndian.Uint64(key[16:])
+	p.s1 = binary.LittleEndian.Uint64(key[24:])
+}
+
+// writePadded processes data as 16 byte blocks, the last block is padded with zeros
+func (p *poly1305) writePadded(data []byte) {
+	for len(data) > 0 {
+		var block [16]byte
+		n := copy(block[:], data)
+		data = data[n:]
+		p.block(&block)
+	}
+}
+
+// block computes h = (h + block + 2^128) * r mod 2^130 - 5
+func (p *poly1305) block(block *[16]byte) {
+	// h += block with the 2^128 bit set
+	var c uint64
+	p.h0, c = bits.Add64(p.h0, binary.LittleEndian.Uint64(block[0:]), 0)
+	p.h1, c = bits.Add64(p.h1, binary.LittleEndian.Uint64(block[8:]), c)
+	p.h2 += c + 1
+
+	// h * r, h2 is at most a few bits so h2 * r fits in 64 bits
+	h0r0hi, h0r0lo := bits.Mul64(p.h0, p.r0)
+	h1r0hi, h1r0lo := bits.Mul64(p.h1, p.r0)
+	h0r1hi, h0r1lo := bits.Mul64(p.h0, p.r1)
+	h1r1hi, h1r1lo := bits.Mul64(p.h1, p.r1)
+	h2r0 := p.h2 * p.r0
+	h2r1 := p.h2 * p.r1
+
+	// m1 = h1r0 + h0r1, m2 = h1r1 + h2r0, m3 = h2r1
+	m1lo, c := bits.Add64(h1r0lo, h0r1lo, 0)
+	m1hi, _ := bits.Add64(h1r0hi, h0r1hi, c)
+	m2lo, c := bits.Add64(h1r1lo, h2r0, 0)
+	m2hi, _ := bits.Add64(h1r1hi, 0, c)
+
+	// t = m0 + m1 << 64 + m2 << 128 + m3 << 192
+	t0 := h0r0lo
+	t1, c := bits.Add64(m1lo, h0r0hi, 0)
+	t2, c := bits.Add64(m2lo, m1hi, c)
+	t3, _ := bits.Add64(h2r1, m2hi, c)
+
+	// reduce with 2^130 = 5, t = (t mod 2^130) + 4 * (t >> 130) + (t >> 130)
+	p.h0, p.h1, p.h2 = t0, t1, t2&3
+	cclo, cchi := t2&^3, t3
+
+	p.h0, c = bits.Add64(p.h0, cclo, 0)
+	p.h1, c = bits.Add64(p.h1, cchi, c)
+	p.h2 += c
+
+	cclo, cchi = cclo>>2|cchi<<62, cchi>>2
+
+	p.h0, c = bits.Add64(p.h0, cclo, 0)
+	p.h1, c = bits.Add64(p.h1, cchi, c)
+	p.h2 += c
+}
+
+// sum writes (h mod 2^130 - 5) + s mod 2^128 to out
+func (p *poly1305) sum(out []byte) {
+	// h - p, kept when it does not borrow
+	g0, b := bits.Sub64(p.h0, 0xFFFFFFFFFFFFFFFB, 0)
+	g1, b := bits.Sub64(p.h1, 0xFFFFFFFFFFFFFFFF, b)
+	_, b = bits.Sub64(p.h2, 3, b)
+
+	mask := b - 1
+	h0 := p.h0&^mask | g0&mask
+	h1 := p.h1&^mask | g1&mask
+
+	// h + s
+	h0, c := bits.Add64(h0, p.s0, 0)
+	h1, _ = bits.Add64(h1, p.s1, c)
+
+	binary.LittleEndian.PutUint64(out[0:], h0)
+	binary.LittleEndian.PutUint64(out[8:], h1)
+}
+
+// sliceForAppend extends in by n bytes, it returns the whole slice and the n new bytes
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+
+	tail = head[len(in):]
+	return
+}
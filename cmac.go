@@ -0,0 +1,149 @@
+package pbkdf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"hash"
+)
+
+// cmac is the CMAC message authentication code of RFC4493(https://datatracker.ietf.org/doc/html/rfc4493)
+// and NIST SP 800-38B, it works with any 64 or 128 bit block cipher
+type cmac struct {
+	block  cipher.Block
+	k1, k2 []byte
+	x      []byte
+	buf    []byte
+	offset int
+}
+
+// NewCMAC creates a CMAC keyed by the given block cipher
+// The block parameter is the block cipher, its block size must be 8 or 16 bytes
+func NewCMAC(block cipher.Block) (hash.Hash, error) {
+	// get the constant of the subkey generation
+	var rb byte
+	switch block.BlockSize() {
+	case 8:
+		rb = 0x1b
+	case 16:
+		rb = 0x87
+	default:
+		return nil, errors.New("error in NewCMAC function: block size must be 8 or 16 bytes")
+	}
+
+	// L = CIPH_K(0), K1 = L << 1 and K2 = K1 << 1, xored with Rb when the shifted out bit is set
+	size := block.BlockSize()
+	c := &cmac{block: block, k1: make([]byte, size), k2: make([]byte, size), x: make([]byte, size), buf: make([]byte, size)}
+	block.Encrypt(c.k1, c.k1)
+	cmacShift(c.k1, c.k1, rb)
+	cmacShift(c.k2, c.k1, rb)
+
+	return c, nil
+}
+
+// cmacShift sets dst to src shifted left by one bit and xored with rb if the most significant bit was set
+func cmacShift(dst, src []byte, rb byte) {
+	msb := src[0] >> 7
+	for i := 0; i < len(src)-1; i++ {
+		dst[i] = src[i]<<1 | src[i+1]>>7
+	}
+	dst[len(src)-1] = src[len(src)-1]<<1 ^ byte(subtle.ConstantTimeSelect(int(msb), int(rb), 0))
+}
+
+// Reset resets the MAC to its initial state
+func (c *cmac) Reset() {
+	for i := range c.x {
+		c.x[i] = 0
+	}
+	c.offset = 0
+}
+
+// Size returns the MAC size, the cipher block size
+func (c *cmac) Size() int {
+	return len(c.x)
+}
+
+// BlockSize returns the cipher block size
+func (c *cmac) BlockSize() int {
+	return len(c.x)
+}
+
+// Write adds data to the MAC
+// the last block is kept in the buffer because it is xored with a subkey in Sum
+func (c *cmac) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		// the buffer is full and more data follows, process it
+		if c.offset == len(c.buf) {
+			subtle.XORBytes(c.x, c.x, c.buf)
+			c.block.Encrypt(c.x, c.x)
+			c.offset = 0
+		}
+
+		// fill the buffer
+		copied := copy(c.buf[c.offset:], p)
+		c.offset += copied
+		p = p[copied:]
+	}
+
+	return n, nil
+}
+
+// Sum appends the MAC to in without changing the state
+func (c *cmac) Sum(in []byte) []byte {
+	last := make([]byte, len(c.buf))
+	copy(last, c.buf[:c.offset])
+
+	// a complete last block is xored with K1, an incomplete one is padded with 10* and xored with K2
+	if c.offset == len(c.buf) {
+		subtle.XORBytes(last, last, c.k1)
+	} else {
+		last[c.offset] = 0x80
+		subtle.XORBytes(last, last, c.k2)
+	}
+
+	// T = CIPH_K(X xor last)
+	subtle.XORBytes(last, last, c.x)
+	c.block.Encrypt(last, last)
+
+	return append(in, last...)
+}
+
+// AESCMACPRF128 is the AES-CMAC-PRF-128 pseudorandom function of RFC4615(https://datatracker.ietf.org/doc/html/rfc4615)
+// it accepts keys of any length, a key that is not 16 bytes is first reduced to 16 bytes with AES-CMAC under the zero key
+// it implements the PRF type so PBKDF2WithPRF can run on it
+func AESCMACPRF128(key []byte) hash.Hash {
+	// reduce the key to 128 bits
+	if len(key) != 16 {
+		zero, _ := aes.NewCipher(make([]byte, 16))
+		mac, _ := NewCMAC(zero)
+		mac.Write(key)
+		key = mac.Sum(nil)
+	}
+
+	block, _ := aes.NewCipher(key)
+	mac, _ := NewCMAC(block)
+
+	return mac
+}
+
+// CMACPRF returns the CMAC PRF of a block cipher, the key is used as the cipher key without reduction
+// The newCipher parameter creates the cipher(for example aes.NewCipher for CMAC-AES128, CMAC-AES192 and CMAC-AES256)
+// the PRF returns nil when the cipher rejects the key or its block size is not 8 or 16 bytes
+func CMACPRF(newCipher func(key []byte) (cipher.Block, error)) PRF {
+	return func(key []byte) hash.Hash {
+		block, err := newCipher(key)
+		if err != nil {
+			return nil
+		}
+
+		mac, err := NewCMAC(block)
+		if err != nil {
+			return nil
+		}
+
+		return mac
+	}
+}
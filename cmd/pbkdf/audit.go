@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bufio"
+	"crypto"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/giovanibageston/pbkdf/v2"
+)
+
+// finding codes of the audit command, they are stable strings meant for automated pipelines
+const (
+	findingUnparseable   = "hash.unparseable"
+	findingWeakAlgorithm = "hash.weak_algorithm"
+	findingLowIterations = "hash.low_iterations"
+	findingShortSalt     = "hash.short_salt"
+	findingShortKey      = "hash.short_key"
+	findingDuplicateSalt = "hash.duplicate_salt"
+	findingDuplicateHash = "hash.duplicate_hash"
+)
+
+// names of the formats in the histograms
+const (
+	colonFormat = "salt:iterationCount:hash"
+	phcFormat   = "phc"
+)
+
+// maxTextFindings is the number of findings printed as text, JSON has all of them
+const maxTextFindings = 100
+
+// maxLineLength is the longest line of the lines and jsonl inputs
+const maxLineLength = 1 << 20
+
+// auditFinding is an entry flagged by the audit
+type auditFinding struct {
+	Line    int    `json:"line"`
+	ID      string `json:"id,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// DuplicateOf is the line of the first entry with the same salt or hash
+	DuplicateOf int `json:"duplicateOf,omitempty"`
+}
+
+// auditHistograms counts the parameters of the entries of one format
+type auditHistograms struct {
+	Entries     int            `json:"entries"`
+	Algorithms  map[string]int `json:"algorithms,omitempty"`
+	Hashes      map[string]int `json:"hashes,omitempty"`
+	Iterations  map[int64]int  `json:"iterations"`
+	SaltLengths map[int]int    `json:"saltLengths"`
+	KeyLengths  map[int]int    `json:"keyLengths"`
+}
+
+// auditOutput is the JSON output of the audit command
+type auditOutput struct {
+	Entries     int                         `json:"entries"`
+	Empty       int                         `json:"empty"`
+	Unparseable int                         `json:"unparseable"`
+	Flagged     int                         `json:"flagged"`
+	Formats     map[string]*auditHistograms `json:"formats"`
+	Findings    []auditFinding              `json:"findings"`
+}
+
+// auditPolicy holds the minimum parameters of the audit
+type auditPolicy struct {
+	minIterations int64
+	minSaltLength int
+	minKeyLength  int
+}
+
+// auditRecord is an encoded password read from the input
+type auditRecord struct {
+	line    int
+	id      string
+	encoded string
+	err     error // the line could not be read as a record of the input format
+}
+
+// runAudit reads encoded passwords and reports their parameters and the entries below the policy
+func runAudit(env environment, args []string) int {
+	fs := newFlagSet(env, "audit", "[file]")
+	input := fs.String("input", "lines", "input format: lines(one encoded password per line), csv(with a header row) or jsonl")
+	field := fs.String("field", "password", "CSV column or JSONL field holding the encoded password")
+	idField := fs.String("id", "", "CSV column or JSONL field identifying the entry in the findings(user id, email address, ...)")
+	minIterations := fs.Int64("min-iterations", pbkdf.DefaultIterationCount, "minimum iteration count of PBKDF1 and PBKDF2 entries")
+	minSaltLength := fs.Int("min-salt-length", int(pbkdf.SP800132MinSaltLength), "minimum salt length in bytes")
+	minKeyLength := fs.Int("min-key-length", int(pbkdf.SP800132MinKeyLength), "minimum key length in bytes")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+
+	if status := parseFlags(fs, args); status >= 0 {
+		return status
+	}
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	// open the input, the standard input without a file or with -
+	r := env.stdin
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return fail(env, *asJSON, err)
+		}
+		defer file.Close()
+
+		r = file
+	}
+
+	// audit every record
+	a := newAuditor(auditPolicy{minIterations: *minIterations, minSaltLength: *minSaltLength, minKeyLength: *minKeyLength})
+	if err := readAuditRecords(r, *input, *field, *idField, a.add); err != nil {
+		return fail(env, *asJSON, err)
+	}
+
+	// print the result
+	if *asJSON {
+		writeJSON(env.stdout, a.out)
+	} else {
+		a.out.print(env.stdout)
+	}
+
+	if a.out.Flagged > 0 {
+		return exitFindings
+	}
+
+	return exitOK
+}
+
+// readAuditRecords calls fn for every entry of the input, blank lines are skipped
+// malformed JSONL lines and CSV rows are passed to fn with their error so they are flagged and the audit goes on
+// JSONL objects without the field or with a value that is not a string or null are flagged too
+func readAuditRecords(r io.Reader, input, field, idField string, fn func(record auditRecord)) error {
+	switch input {
+	case "lines":
+		return scanRecords(r, func(line int, text string) error {
+			if encoded := strings.TrimSpace(text); encoded != "" {
+				fn(auditRecord{line: line, encoded: encoded})
+			}
+
+			return nil
+		})
+
+	case "jsonl":
+		return scanRecords(r, func(line int, text string) error {
+			if strings.TrimSpace(text) == "" {
+				return nil
+			}
+
+			var object map[string]any
+			if err := json.Unmarshal([]byte(text), &object); err != nil {
+				fn(auditRecord{line: line, err: fmt.Errorf("line is not a JSON object: %s", err.Error())})
+				return nil
+			}
+
+			// null is an account without a password, a missing field or another type is flagged
+			record := auditRecord{line: line, id: jsonString(object[idField])}
+			value, ok := object[field]
+			switch value := value.(type) {
+			case string:
+				record.encoded = value
+			case nil:
+				if !ok {
+					record.err = fmt.Errorf("no field %q in the JSON object", field)
+				}
+			default:
+				record.err = fmt.Errorf("field %q is not a string", field)
+			}
+
+			fn(record)
+			return nil
+		})
+
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+
+		// find the columns in the header
+		header, err := reader.Read()
+		if err != nil {
+			return fmt.Errorf("reading the CSV header: %s", err.Error())
+		}
+
+		column, idColumn := indexOf(header, field), indexOf(header, idField)
+		if column < 0 {
+			return fmt.Errorf("no column %q in the CSV header", field)
+		}
+
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+
+			// a malformed row is flagged, the reader goes on with the next one
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				fn(auditRecord{line: parseErr.StartLine, err: fmt.Errorf("invalid CSV row: %s", parseErr.Err.Error())})
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			record := auditRecord{}
+			record.line, _ = reader.FieldPos(0)
+			if column < len(row) {
+				record.encoded = row[column]
+			}
+
+			if idColumn >= 0 && idColumn < len(row) {
+				record.id = row[idColumn]
+			}
+
+			fn(record)
+		}
+	}
+
+	return fmt.Errorf("unknown input format %q, use lines, csv or jsonl", input)
+}
+
+// scanRecords calls fn for every line of r with its line number, starting at 1
+func scanRecords(r io.Reader, fn func(line int, text string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	for line := 1; scanner.Scan(); line++ {
+		if err := fn(line, scanner.Text()); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// auditor accumulates the histograms and findings
+type auditor struct {
+	policy auditPolicy
+	out    auditOutput
+	salts  map[string]int
+	keys   map[string]int
+}
+
+// newAuditor creates an auditor with the given policy
+func newAuditor(policy auditPolicy) *auditor {
+	return &auditor{
+		policy: policy,
+		out:    auditOutput{Formats: make(map[string]*auditHistograms), Findings: []auditFinding{}},
+		salts:  make(map[string]int),
+		keys:   make(map[string]int),
+	}
+}
+
+// add audits one record
+func (a *auditor) add(record auditRecord) {
+	// empty entries are accounts without a password
+	if record.encoded == "" && record.err == nil {
+		a.out.Empty++
+		return
+	}
+
+	a.out.Entries++
+	findings := len(a.out.Findings)
+	defer func() {
+		if len(a.out.Findings) > findings {
+			a.out.Flagged++
+		}
+	}()
+
+	flag := func(code string, duplicateOf int, format string, args ...any) {
+		a.out.Findings = append(a.out.Findings, auditFinding{Line: record.line, ID: record.id, Code: code, Message: fmt.Sprintf(format, args...), DuplicateOf: duplicateOf})
+	}
+
+	if record.err != nil {
+		a.out.Unparseable++
+		flag(findingUnparseable, 0, "%s", record.err.Error())
+		return
+	}
+
+	h, err := pbkdf.ParsePasswordHash(record.encoded)
+	if err != nil {
+		a.out.Unparseable++
+		flag(findingUnparseable, 0, "%s", err.Error())
+		return
+	}
+
+	// histograms
+	format, hash := colonFormat, crypto.Hash(0)
+	if h.KDF() != nil {
+		format = phcFormat
+		hash = kdfHash(h.KDF())
+	}
+
+	histograms := a.out.Formats[format]
+	if histograms == nil {
+		histograms = &auditHistograms{Iterations: make(map[int64]int), SaltLengths: make(map[int]int), KeyLengths: make(map[int]int)}
+		if format == phcFormat {
+			histograms.Algorithms, histograms.Hashes = make(map[string]int), make(map[string]int)
+		}
+
+		a.out.Formats[format] = histograms
+	}
+
+	histograms.Entries++
+	histograms.Iterations[h.Iterations()]++
+	histograms.SaltLengths[len(h.Salt())]++
+	histograms.KeyLengths[h.KeyLength()]++
+
+	if format == phcFormat {
+		histograms.Algorithms[h.Algorithm()]++
+		if hash != 0 {
+			histograms.Hashes[hashName(hash)]++
+		}
+	}
+
+	// policy, the iteration count only applies to PBKDF1 and PBKDF2, the other algorithms have their own cost parameters
+	algorithm := h.Algorithm()
+	isPBKDF := format == colonFormat || hash != 0
+
+	switch {
+	case strings.HasPrefix(algorithm, "pbkdf1-") || strings.HasPrefix(algorithm, "pbkdf2-legacy-"):
+		flag(findingWeakAlgorithm, 0, "%s is deprecated, rehash with PBKDF2", algorithm)
+	case hash != 0 && !approvedHash(hash):
+		flag(findingWeakAlgorithm, 0, "%s is not an approved HMAC hash", hashName(hash))
+	}
+
+	if isPBKDF && h.Iterations() < a.policy.minIterations {
+		flag(findingLowIterations, 0, "%d iterations, at least %d required", h.Iterations(), a.policy.minIterations)
+	}
+
+	if len(h.Salt()) < a.policy.minSaltLength {
+		flag(findingShortSalt, 0, "%d byte salt, at least %d required", len(h.Salt()), a.policy.minSaltLength)
+	}
+
+	if h.KeyLength() < a.policy.minKeyLength {
+		flag(findingShortKey, 0, "%d byte key, at least %d required", h.KeyLength(), a.policy.minKeyLength)
+	}
+
+	// duplicates, a repeated salt weakens every entry using it and a repeated hash means the same password and salt
+	salt := string(h.Salt())
+	if first, ok := a.salts[salt]; ok {
+		flag(findingDuplicateSalt, first, "salt already used on line %d", first)
+	} else {
+		a.salts[salt] = record.line
+	}
+
+	key := hashKey(h)
+	if first, ok := a.keys[key]; ok {
+		flag(findingDuplicateHash, first, "hash already used on line %d", first)
+	} else {
+		a.keys[key] = record.line
+	}
+}
+
+// print writes the histograms and findings as text
+func (out auditOutput) print(w io.Writer) {
+	fmt.Fprintf(w, "entries: %d, empty: %d, unparseable: %d, flagged: %d\n", out.Entries, out.Empty, out.Unparseable, out.Flagged)
+
+	for _, format := range sortedKeys(out.Formats) {
+		histograms := out.Formats[format]
+
+		fmt.Fprintf(w, "\n%s: %d entries\n", format, histograms.Entries)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		if histograms.Algorithms != nil {
+			fmt.Fprintf(tw, "  algorithms\t%s\n", formatHistogram(histograms.Algorithms))
+			fmt.Fprintf(tw, "  hashes\t%s\n", formatHistogram(histograms.Hashes))
+		}
+		fmt.Fprintf(tw, "  iterations\t%s\n", formatHistogram(histograms.Iterations))
+		fmt.Fprintf(tw, "  salt lengths\t%s\n", formatHistogram(histograms.SaltLengths))
+		fmt.Fprintf(tw, "  key lengths\t%s\n", formatHistogram(histograms.KeyLengths))
+		tw.Flush()
+	}
+
+	if len(out.Findings) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\nfindings:\n")
+	for i, finding := range out.Findings {
+		if i == maxTextFindings {
+			fmt.Fprintf(w, "  ... %d more, use -json for the full list\n", len(out.Findings)-i)
+			break
+		}
+
+		entry := fmt.Sprintf("line %d", finding.Line)
+		if finding.ID != "" {
+			entry += " (" + finding.ID + ")"
+		}
+
+		fmt.Fprintf(w, "  %s: %s: %s\n", entry, finding.Code, finding.Message)
+	}
+}
+
+// formatHistogram returns the values of a histogram and their counts, sorted by value
+func formatHistogram[K string | int | int64](histogram map[K]int) string {
+	keys := make([]K, 0, len(histogram))
+	for key := range histogram {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = fmt.Sprintf("%v x%d", key, histogram[key])
+	}
+
+	return strings.Join(values, ", ")
+}
+
+// kdfHash returns the hash function of the PBKDF1 and PBKDF2 KDFs, zero for the other algorithms
+func kdfHash(kdf pbkdf.KDF) crypto.Hash {
+	switch p := kdf.(type) {
+	case pbkdf.PBKDF2Parameters:
+		return p.Hash
+	case pbkdf.PBKDF2LegacyParameters:
+		return p.Hash
+	case pbkdf.PBKDF1Parameters:
+		return p.Hash
+	}
+
+	return 0
+}
+
+// hashName returns the name of a hash function, the reverse of parseHash
+func hashName(hash crypto.Hash) string {
+	for name, h := range hashes {
+		if h == hash {
+			return name
+		}
+	}
+
+	return hash.String()
+}
+
+// approvedHash reports whether the hash is approved by NIST SP 800-132
+func approvedHash(hash crypto.Hash) bool {
+	for _, approved := range pbkdf.DefaultCompliancePolicy().ApprovedHashes {
+		if approved == hash {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hashKey returns the derived key of an entry, keyed by the algorithm so equal bytes of different algorithms don't collide
+func hashKey(h pbkdf.PasswordHash) string {
+	encoded := h.Encoded()
+	return h.Algorithm() + "$" + encoded[strings.LastIndexAny(encoded, ":$")+1:]
+}
+
+// indexOf returns the index of name in the header, -1 if it isn't there or name is empty
+func indexOf(header []string, name string) int {
+	for i, column := range header {
+		if name != "" && strings.TrimSpace(column) == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// jsonString returns a JSON value identifying an entry as a string
+func jsonString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+
+	data, _ := json.Marshal(v)
+	return string(data)
+}
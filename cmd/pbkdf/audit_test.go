@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/giovanibageston/pbkdf/v2"
+)
+
+// tests the histograms and findings of every input format
+func TestAudit(t *testing.T) {
+	strong, _ := pbkdf.EncodePasswordKDF(pbkdf.PBKDF2Parameters{Hash: crypto.SHA256, Iterations: 600000}, "password", 16, 32)
+	weak, _ := pbkdf.EncodePasswordPBKDF2(crypto.SHA256, "password", 8, 1000, 32)
+	legacy, _ := pbkdf.EncodePasswordKDF(pbkdf.PBKDF2LegacyParameters{Hash: crypto.SHA256, Iterations: 600000}, "password", 16, 32)
+	scrypt, _ := pbkdf.EncodePasswordKDF(pbkdf.ScryptParameters{N: 16, R: 8, P: 1}, "password", 16, 32)
+
+	// the same entries in the three inputs, the weak one twice
+	entries := []string{strong, weak, legacy, scrypt, "not a hash", weak, ""}
+
+	lines := strings.Join(entries, "\n") + "\n"
+
+	var table strings.Builder
+	w := csv.NewWriter(&table)
+	w.Write([]string{"id", "email", "password"})
+
+	jsonl := ""
+	for i, entry := range entries {
+		w.Write([]string{fmt.Sprint(i + 1), fmt.Sprintf("user%d@example.com", i+1), entry})
+		jsonl += fmt.Sprintf(`{"id":%d,"password":%q}`+"\n", i+1, entry)
+	}
+	w.Flush()
+
+	for _, test := range []struct {
+		input string
+		data  string
+		line  int
+	}{
+		{"lines", lines, 0},
+		{"csv", table.String(), 1},
+		{"jsonl", jsonl, 0},
+	} {
+		status, stdout, stderr := runCommand(test.data, "audit", "-json", "-input", test.input, "-id", "id")
+
+		var out auditOutput
+		if err := json.Unmarshal([]byte(stdout), &out); status != exitFindings || err != nil {
+			t.Fatalf("error in TestAudit function: %s audit gave %d, %q, %q", test.input, status, stdout, stderr)
+		}
+
+		// counts and histograms
+		phc, colon := out.Formats[phcFormat], out.Formats[colonFormat]
+		if out.Entries != 6 || out.Unparseable != 1 || out.Flagged != 4 || test.input != "lines" && out.Empty != 1 ||
+			phc == nil || phc.Entries != 3 || phc.Algorithms["pbkdf2-sha256"] != 1 || phc.Hashes["sha256"] != 2 || phc.Iterations[600000] != 2 ||
+			colon == nil || colon.Entries != 2 || colon.SaltLengths[8] != 2 || colon.KeyLengths[32] != 2 {
+			t.Errorf("error in TestAudit function: unexpected %s summary %s", test.input, stdout)
+		}
+
+		// findings, the lines of the CSV input are shifted by the header
+		var codes []string
+		for _, finding := range out.Findings {
+			codes = append(codes, fmt.Sprintf("%d:%s:%d", finding.Line-test.line, finding.Code, finding.DuplicateOf))
+			if test.input != "lines" && finding.ID != fmt.Sprint(finding.Line-test.line) {
+				t.Errorf("error in TestAudit function: %s finding %+v has a wrong id", test.input, finding)
+			}
+		}
+
+		expected := []string{
+			"2:hash.low_iterations:0", "2:hash.short_salt:0", "3:hash.weak_algorithm:0", "5:hash.unparseable:0",
+			"6:hash.low_iterations:0", "6:hash.short_salt:0",
+			fmt.Sprintf("6:hash.duplicate_salt:%d", 2+test.line), fmt.Sprintf("6:hash.duplicate_hash:%d", 2+test.line),
+		}
+
+		if strings.Join(codes, " ") != strings.Join(expected, " ") {
+			t.Errorf("error in TestAudit function: %s findings are %v", test.input, codes)
+		}
+	}
+
+	// text output and a clean input
+	status, stdout, _ := runCommand(strong+"\n", "audit")
+	if status != exitOK || !strings.Contains(stdout, "pbkdf2-sha256 x1") || strings.Contains(stdout, "findings") {
+		t.Errorf("error in TestAudit function: clean audit gave %d, %q", status, stdout)
+	}
+
+	// malformed lines in the middle of the input are flagged and the audit goes on
+	for _, test := range []struct {
+		input string
+		data  string
+	}{
+		{"jsonl", fmt.Sprintf(`{"password":%q}`+"\n"+`{"password":`+"\n"+`{"password":%q}`+"\n", strong, strong)},
+		{"csv", fmt.Sprintf("password\n%s\nbroken\"quote\n%s\n", strong, strong)},
+	} {
+		status, stdout, stderr := runCommand(test.data, "audit", "-json", "-input", test.input)
+
+		var out auditOutput
+		if err := json.Unmarshal([]byte(stdout), &out); status != exitFindings || err != nil || out.Entries != 3 || out.Unparseable != 1 ||
+			len(out.Findings) != 3 || out.Findings[0].Code != findingUnparseable || out.Findings[0].Line != 2+strings.Count(test.input, "csv") ||
+			out.Findings[2].Code != findingDuplicateHash || out.Findings[2].Line != 3+strings.Count(test.input, "csv") {
+			t.Errorf("error in TestAudit function: %s audit with a malformed line gave %d, %q, %q", test.input, status, stdout, stderr)
+		}
+	}
+
+	// a JSONL field that is missing or not a string is flagged, not counted as an empty password
+	jsonl = fmt.Sprintf(`{"pwd":%q}`+"\n"+`{"password":42}`+"\n"+`{"password":null}`+"\n", strong)
+	status, stdout, stderr := runCommand(jsonl, "audit", "-json", "-input", "jsonl")
+
+	var out auditOutput
+	if err := json.Unmarshal([]byte(stdout), &out); status != exitFindings || err != nil || out.Entries != 2 || out.Unparseable != 2 || out.Empty != 1 ||
+		len(out.Findings) != 2 || out.Findings[0].Code != findingUnparseable || out.Findings[1].Line != 2 {
+		t.Errorf("error in TestAudit function: JSONL audit with a wrong field gave %d, %q, %q", status, stdout, stderr)
+	}
+
+	// errors
+	for _, args := range [][]string{{"-input", "xml"}, {"-input", "csv", "-field", "hash"}, {"missing.txt"}} {
+		if status, _, _ := runCommand("id,password\n{\n", append([]string{"audit"}, args...)...); status != exitError {
+			t.Errorf("error in TestAudit function: %v gave %d", args, status)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/giovanibageston/pbkdf/v2"
+)
+
+// benchResult is the measurement of one kdf, hash and key length
+type benchResult struct {
+	KDF       string `json:"kdf"`
+	Hash      string `json:"hash"`
+	KeyLength int64  `json:"keyLength"`
+	// IterationsPerSecond is the single-threaded throughput, an iteration of a key longer than the hash costs one call of the PRF per block
+	IterationsPerSecond float64 `json:"iterationsPerSecond"`
+	// ParallelIterationsPerSecond is the throughput of all the workers together
+	ParallelIterationsPerSecond float64 `json:"parallelIterationsPerSecond"`
+	// Speedup is the parallel throughput divided by the single-threaded one
+	Speedup float64 `json:"speedup"`
+	// TargetIterations is the iteration count of a derivation lasting the target latency on one core
+	TargetIterations int64 `json:"targetIterations"`
+	// HashesPerSecond is the number of derivations per second with TargetIterations on all the workers, the capacity of an auth server
+	HashesPerSecond float64 `json:"hashesPerSecond"`
+}
+
+// benchOutput is the JSON output of the bench command
+type benchOutput struct {
+	TargetMilliseconds float64       `json:"targetMilliseconds"`
+	Workers            int           `json:"workers"`
+	Results            []benchResult `json:"results"`
+}
+
+// runBench measures the throughput of PBKDF1 and PBKDF2 on this host and the iteration counts reaching a target latency
+func runBench(env environment, args []string) int {
+	fs := newFlagSet(env, "bench", "")
+	kdfList := fs.String("kdf", "pbkdf1,pbkdf2", "key derivation functions separated by commas: "+strings.Join(sortedKeys(kdfs), ", "))
+	hashList := fs.String("hash", "sha1,sha256,sha512", "hash functions separated by commas")
+	keyLengthList := fs.String("key-length", "16,32,64", "derived key lengths in bytes separated by commas, PBKDF1 is skipped for keys longer than the hash")
+	target := fs.Duration("target", 250*time.Millisecond, "target latency of one derivation")
+	duration := fs.Duration("duration", 500*time.Millisecond, "duration of each measurement")
+	workers := fs.Int("parallel", runtime.GOMAXPROCS(0), "number of parallel workers")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+
+	if status := parseFlags(fs, args); status >= 0 {
+		return status
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	// check the flags
+	if *target <= 0 || *duration <= 0 || *workers < 1 {
+		return fail(env, *asJSON, errors.New("target, duration and parallel must be positive"))
+	}
+
+	kdfNames := strings.Split(*kdfList, ",")
+	for _, name := range kdfNames {
+		if _, ok := kdfs[name]; !ok {
+			return fail(env, *asJSON, fmt.Errorf("unknown kdf %q, use one of %s", name, strings.Join(sortedKeys(kdfs), ", ")))
+		}
+	}
+
+	hashNames := strings.Split(*hashList, ",")
+	for _, name := range hashNames {
+		if _, err := parseHash(name); err != nil {
+			return fail(env, *asJSON, err)
+		}
+	}
+
+	var keyLengths []int64
+	for _, value := range strings.Split(*keyLengthList, ",") {
+		keyLength, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || keyLength <= 0 {
+			return fail(env, *asJSON, fmt.Errorf("invalid key length %q", value))
+		}
+
+		keyLengths = append(keyLengths, keyLength)
+	}
+
+	// measure every combination
+	out := benchOutput{TargetMilliseconds: float64(*target) / float64(time.Millisecond), Workers: *workers}
+
+	for _, kdfName := range kdfNames {
+		for _, hashName := range hashNames {
+			hash, _ := parseHash(hashName)
+
+			for _, keyLength := range keyLengths {
+				// PBKDF1 can not derive keys longer than the hash
+				if kdfName == "pbkdf1" && keyLength > int64(hash.Size()) {
+					continue
+				}
+
+				result, err := benchmark(kdfs[kdfName], hash, keyLength, *workers, *duration, *target)
+				if err != nil {
+					return fail(env, *asJSON, fmt.Errorf("%s-%s with %d byte keys: %s", kdfName, hashName, keyLength, err.Error()))
+				}
+
+				result.KDF, result.Hash = kdfName, hashName
+				out.Results = append(out.Results, result)
+			}
+		}
+	}
+
+	// print the result
+	if *asJSON {
+		writeJSON(env.stdout, out)
+		return exitOK
+	}
+
+	tw := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "kdf\thash\tkey\titer/s\titer/s x%d\tspeedup\titerations for %s\thashes/s x%d\t\n", *workers, *target, *workers)
+	for _, r := range out.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.0f\t%.0f\t%.2f\t%d\t%.1f\t\n",
+			r.KDF, r.Hash, r.KeyLength, r.IterationsPerSecond, r.ParallelIterationsPerSecond, r.Speedup, r.TargetIterations, r.HashesPerSecond)
+	}
+	tw.Flush()
+
+	return exitOK
+}
+
+// benchmark measures the single-threaded and parallel throughput of a kdf
+func benchmark(kdf pbkdf.PBKDF, hash crypto.Hash, keyLength int64, workers int, duration, target time.Duration) (benchResult, error) {
+	password, salt := []byte("benchmark password"), make([]byte, pbkdf.DefaultSaltLength)
+
+	// find an iteration count lasting a small part of the measurement so the calls overhead doesn't count
+	c := int64(1000)
+	for {
+		start := time.Now()
+		if _, err := kdf(hash, password, salt, c, keyLength); err != nil {
+			return benchResult{}, err
+		}
+
+		if time.Since(start) >= duration/20 || c >= 1<<40 {
+			break
+		}
+
+		c *= 2
+	}
+
+	// derive until the measurement lasted duration on every worker
+	measure := func(workers int) float64 {
+		var (
+			wg    sync.WaitGroup
+			mutex sync.Mutex
+			total int64
+		)
+
+		start := time.Now()
+		deadline := start.Add(duration)
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				var n int64
+				for time.Now().Before(deadline) {
+					kdf(hash, password, salt, c, keyLength)
+					n += c
+				}
+
+				mutex.Lock()
+				total += n
+				mutex.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		return float64(total) / time.Since(start).Seconds()
+	}
+
+	result := benchResult{KeyLength: keyLength, IterationsPerSecond: measure(1), ParallelIterationsPerSecond: measure(workers)}
+	result.Speedup = result.ParallelIterationsPerSecond / result.IterationsPerSecond
+	result.TargetIterations = max(int64(result.IterationsPerSecond*target.Seconds()), 1)
+	result.HashesPerSecond = result.ParallelIterationsPerSecond / float64(result.TargetIterations)
+
+	return result, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// tests the measured combinations and the calibrated iteration counts
+func TestBench(t *testing.T) {
+	status, stdout, stderr := runCommand("", "bench", "-json", "-duration", "10ms", "-target", "100ms", "-parallel", "2", "-hash", "sha1,sha512", "-key-length", "20,32")
+
+	var out benchOutput
+	if err := json.Unmarshal([]byte(stdout), &out); status != exitOK || err != nil {
+		t.Fatalf("error in TestBench function: bench gave %d, %q, %q", status, stdout, stderr)
+	}
+
+	// PBKDF1 with SHA-1 can not derive 32 bytes
+	if len(out.Results) != 7 || out.Workers != 2 || out.TargetMilliseconds != 100 {
+		t.Fatalf("error in TestBench function: unexpected results %+v", out)
+	}
+
+	for _, r := range out.Results {
+		if r.KDF == "pbkdf1" && r.Hash == "sha1" && r.KeyLength == 32 {
+			t.Errorf("error in TestBench function: PBKDF1 was measured with a key longer than the hash")
+		}
+
+		// the target iteration count lasts the target latency at the measured throughput
+		if r.IterationsPerSecond <= 0 || r.ParallelIterationsPerSecond <= 0 || abs(r.TargetIterations-int64(r.IterationsPerSecond/10)) > 1 {
+			t.Errorf("error in TestBench function: unexpected result %+v", r)
+		}
+	}
+
+	// table output
+	status, stdout, _ = runCommand("", "bench", "-duration", "1ms", "-kdf", "pbkdf2", "-hash", "sha256", "-key-length", "32")
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); status != exitOK || len(lines) != 2 || !strings.Contains(lines[1], "pbkdf2") {
+		t.Errorf("error in TestBench function: table output gave %d, %q", status, stdout)
+	}
+
+	// invalid flags
+	for _, args := range [][]string{{"-kdf", "pbkdf3"}, {"-hash", "sha0"}, {"-key-length", "0"}, {"-target", "0s"}, {"-parallel", "0"}} {
+		if status, _, _ := runCommand("", append([]string{"bench"}, args...)...); status != exitError {
+			t.Errorf("error in TestBench function: %v gave %d", args, status)
+		}
+	}
+}
+
+// abs returns the absolute value of n
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/giovanibageston/pbkdf/v2"
+)
+
+// convertBatchSize is the number of records converted by a worker at once
+const convertBatchSize = 1024
+
+// errConvertStopped stops reading the input after a failed conversion
+var errConvertStopped = errors.New("conversion stopped")
+
+// jsonMember is a member of a JSON object, kept in the order of the input
+type jsonMember struct {
+	key   string
+	value json.RawMessage
+}
+
+// convertRecord is a line of the input with its converted encoded password
+type convertRecord struct {
+	line    int
+	text    string       // lines input
+	row     []string     // csv input
+	members []jsonMember // jsonl input
+	field   int          // index of the encoded password in row or members, -1 if there is none
+	err     error
+}
+
+// convertBatch is a batch of records, done is closed once they are converted
+type convertBatch struct {
+	records []convertRecord
+	done    chan struct{}
+}
+
+// runConvert rewrites the encoded passwords of a file in another format with parallel workers, the order of the records is kept
+func runConvert(env environment, args []string) int {
+	formats := strings.Join(pbkdf.RegisteredPasswordFormats(), ", ")
+
+	fs := newFlagSet(env, "convert", "[file]")
+	from := fs.String("from", "", "format of the input strings: "+formats)
+	to := fs.String("to", "", "format of the output strings: "+formats)
+	hashFlag := fs.String("hash", "", "hash function of the colon(salt:iterationCount:hash) strings, they do not record it")
+	legacy := fs.Bool("legacy", false, "the colon strings were encoded with the PBKDF2 of versions before it used HMAC(pbkdf2-legacy)")
+	input := fs.String("input", "lines", "input format: lines(one encoded password per line), csv(with a header row) or jsonl")
+	field := fs.String("field", "password", "CSV column or JSONL field holding the encoded password")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "number of parallel workers")
+	skip := fs.Bool("skip", false, "keep the strings that can not be converted unchanged and report them on the standard error instead of failing")
+
+	if status := parseFlags(fs, args); status >= 0 {
+		return status
+	}
+
+	if fs.NArg() > 1 || *from == "" || *to == "" {
+		fs.Usage()
+		return exitError
+	}
+
+	// check the flags
+	options := pbkdf.ConvertOptions{Legacy: *legacy}
+	if *hashFlag != "" {
+		hash, err := parseHash(*hashFlag)
+		if err != nil {
+			return fail(env, false, err)
+		}
+
+		options.Hash = hash
+	}
+
+	if *workers < 1 {
+		return fail(env, false, errors.New("workers must be positive"))
+	}
+
+	for _, format := range []string{*from, *to} {
+		if _, err := pbkdf.LookupPasswordFormat(format); err != nil {
+			return fail(env, false, fmt.Errorf("unknown format %q, use one of %s", format, formats))
+		}
+	}
+
+	// open the input, the standard input without a file or with -
+	r := env.stdin
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return fail(env, false, err)
+		}
+		defer file.Close()
+
+		r = file
+	}
+
+	convert := func(encoded string) (string, error) {
+		return pbkdf.ConvertPasswordHash(encoded, *from, *to, options)
+	}
+
+	skipped, err := convertStream(r, env.stdout, *input, *field, *workers, convert, func(record convertRecord) error {
+		if !*skip {
+			return fmt.Errorf("line %d: %s", record.line, record.err.Error())
+		}
+
+		fmt.Fprintf(env.stderr, "pbkdf: line %d: %s\n", record.line, record.err.Error())
+		return nil
+	})
+
+	if err != nil {
+		return fail(env, false, err)
+	}
+
+	if skipped > 0 {
+		fmt.Fprintf(env.stderr, "pbkdf: %d entries were not converted\n", skipped)
+		return exitFindings
+	}
+
+	return exitOK
+}
+
+// convertStream converts the records of r with the workers and writes them to w in the input order
+// onError is called in order for every record that could not be converted, if it returns an error the conversion stops
+// the number of records that could not be converted is returned
+func convertStream(r io.Reader, w io.Writer, input, field string, workers int, convert func(string) (string, error), onError func(convertRecord) error) (int, error) {
+	var (
+		batches = make(chan *convertBatch, workers)
+		jobs    = make(chan *convertBatch, workers)
+		stop    = make(chan struct{})
+		readErr error
+	)
+
+	// read the input in batches, every batch goes to the writer in order and to a worker
+	go func() {
+		defer close(batches)
+		defer close(jobs)
+
+		batch := &convertBatch{done: make(chan struct{})}
+		send := func() error {
+			select {
+			case batches <- batch:
+			case <-stop:
+				return errConvertStopped
+			}
+
+			jobs <- batch
+			batch = &convertBatch{done: make(chan struct{})}
+			return nil
+		}
+
+		readErr = readConvertRecords(r, input, field, func(record convertRecord) error {
+			batch.records = append(batch.records, record)
+			if len(batch.records) < convertBatchSize {
+				return nil
+			}
+
+			return send()
+		})
+
+		if readErr == nil && len(batch.records) > 0 {
+			readErr = send()
+		}
+	}()
+
+	// convert the batches
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for batch := range jobs {
+				for i := range batch.records {
+					convertRecordField(&batch.records[i], input, convert)
+				}
+				close(batch.done)
+			}
+		}()
+	}
+	defer wg.Wait()
+
+	// write the batches in order
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	failed := 0
+
+	for batch := range batches {
+		<-batch.done
+
+		for _, record := range batch.records {
+			if record.err != nil {
+				failed++
+				if err := onError(record); err != nil {
+					close(stop)
+					for range batches {
+					}
+
+					// the rows written before the failure are kept
+					cw.Flush()
+					bw.Flush()
+					return failed, err
+				}
+			}
+
+			writeConvertRecord(bw, cw, input, record)
+		}
+	}
+
+	cw.Flush()
+	if err := bw.Flush(); err != nil {
+		return failed, err
+	}
+
+	return failed, readErr
+}
+
+// readConvertRecords calls fn for every record of the input
+func readConvertRecords(r io.Reader, input, field string, fn func(record convertRecord) error) error {
+	switch input {
+	case "lines":
+		return scanRecords(r, func(line int, text string) error {
+			return fn(convertRecord{line: line, text: strings.TrimRight(text, "\r")})
+		})
+
+	case "jsonl":
+		return scanRecords(r, func(line int, text string) error {
+			record := convertRecord{line: line, text: text, field: -1}
+			if strings.TrimSpace(text) == "" {
+				return fn(record)
+			}
+
+			members, err := parseJSONObject([]byte(text))
+			if err != nil {
+				return fmt.Errorf("line %d: %s", line, err.Error())
+			}
+
+			record.members = members
+			for i, member := range members {
+				if member.key == field {
+					record.field = i
+				}
+			}
+
+			// the object is kept unchanged and reported
+			if record.field < 0 {
+				record.err = fmt.Errorf("no field %q in the JSON object", field)
+			}
+
+			return fn(record)
+		})
+
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+
+		// the header is written unchanged
+		header, err := reader.Read()
+		if err != nil {
+			return fmt.Errorf("reading the CSV header: %s", err.Error())
+		}
+
+		column := indexOf(header, field)
+		if column < 0 {
+			return fmt.Errorf("no column %q in the CSV header", field)
+		}
+
+		if err := fn(convertRecord{line: 1, row: header, field: -1}); err != nil {
+			return err
+		}
+
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			record := convertRecord{row: row, field: -1}
+			record.line, _ = reader.FieldPos(0)
+			if column < len(row) {
+				record.field = column
+			}
+
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return fmt.Errorf("unknown input format %q, use lines, csv or jsonl", input)
+}
+
+// convertRecordField converts the encoded password of a record, empty values and JSON nulls are kept
+func convertRecordField(record *convertRecord, input string, convert func(string) (string, error)) {
+	switch {
+	case input == "lines":
+		if converted, err := convertValue(record.text, convert); err != nil {
+			record.err = err
+		} else {
+			record.text = converted
+		}
+
+	case input == "csv" && record.field >= 0:
+		if converted, err := convertValue(record.row[record.field], convert); err != nil {
+			record.err = err
+		} else {
+			record.row[record.field] = converted
+		}
+
+	case input == "jsonl" && record.field >= 0:
+		value := record.members[record.field].value
+
+		if string(value) == "null" {
+			return
+		}
+
+		var encoded string
+		if !bytes.HasPrefix(value, []byte(`"`)) || json.Unmarshal(value, &encoded) != nil {
+			record.err = errors.New("the password field is not a string")
+			return
+		}
+
+		converted, err := convertValue(encoded, convert)
+		if err != nil {
+			record.err = err
+			return
+		}
+
+		record.members[record.field].value, _ = json.Marshal(converted)
+	}
+}
+
+// convertValue converts a non-empty encoded password
+func convertValue(encoded string, convert func(string) (string, error)) (string, error) {
+	if encoded == "" {
+		return "", nil
+	}
+
+	return convert(encoded)
+}
+
+// writeConvertRecord writes a record in the input format
+func writeConvertRecord(bw *bufio.Writer, cw *csv.Writer, input string, record convertRecord) {
+	switch {
+	case input == "csv":
+		cw.Write(record.row)
+
+	case input == "jsonl" && strings.TrimSpace(record.text) != "":
+		bw.WriteByte('{')
+		for i, member := range record.members {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+
+			key, _ := json.Marshal(member.key)
+			bw.Write(key)
+			bw.WriteByte(':')
+			bw.Write(member.value)
+		}
+		bw.WriteString("}\n")
+
+	default:
+		bw.WriteString(record.text + "\n")
+	}
+}
+
+// parseJSONObject returns the members of a JSON object in order
+func parseJSONObject(data []byte) ([]jsonMember, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, errors.New("line is not a JSON object")
+	}
+
+	var members []jsonMember
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		member := jsonMember{key: token.(string)}
+		if err := decoder.Decode(&member.value); err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	// the closing brace and nothing after it
+	if token, err := decoder.Token(); err != nil || token != json.Delim('}') {
+		return nil, errors.New("line is not a JSON object")
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("line has data after the JSON object")
+	}
+
+	return members, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/giovanibageston/pbkdf/v2"
+)
+
+// tests that conversions keep the order and the other fields of every input format
+func TestConvert(t *testing.T) {
+	django := "pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c="
+	phc := "$pbkdf2-sha256$i=1000$c2Vhc2FsdA$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c"
+
+	// more records than a batch, with different iteration counts to check the order
+	var input, expected strings.Builder
+	for i := 1; i <= 3*convertBatchSize+5; i++ {
+		encoded := strings.Replace(django, "$1000$", fmt.Sprintf("$%d$", i), 1)
+		converted, err := pbkdf.ConvertPasswordHash(encoded, pbkdf.FormatDjango, pbkdf.FormatPHC, pbkdf.ConvertOptions{})
+		if err != nil {
+			t.Fatalf("error in TestConvert function while converting %q: %s", encoded, err.Error())
+		}
+
+		fmt.Fprintln(&input, encoded)
+		fmt.Fprintln(&expected, converted)
+	}
+
+	status, stdout, stderr := runCommand(input.String(), "convert", "-from", "django", "-to", "phc", "-workers", "4")
+	if status != exitOK || stdout != expected.String() {
+		t.Errorf("error in TestConvert function: lines conversion gave %d, %q", status, stderr)
+	}
+
+	// CSV with a quoted field and an empty value
+	csvInput := "id,name,password\n1,\"Doe, Jane\"," + django + "\n2,nobody,\n"
+	csvOutput := "id,name,password\n1,\"Doe, Jane\"," + phc + "\n2,nobody,\n"
+	if status, stdout, _ := runCommand(csvInput, "convert", "-from", "django", "-to", "phc", "-input", "csv"); status != exitOK || stdout != csvOutput {
+		t.Errorf("error in TestConvert function: CSV conversion gave %d, %q", status, stdout)
+	}
+
+	// JSONL keeps the order and the values of the other members
+	jsonlInput := `{"id":1,"password":"` + django + `","roles":["a", "b"]}` + "\n" + `{"id":2,"password":null}` + "\n"
+	jsonlOutput := `{"id":1,"password":"` + phc + `","roles":["a", "b"]}` + "\n" + `{"id":2,"password":null}` + "\n"
+	if status, stdout, _ := runCommand(jsonlInput, "convert", "-from", "django", "-to", "phc", "-input", "jsonl"); status != exitOK || stdout != jsonlOutput {
+		t.Errorf("error in TestConvert function: JSONL conversion gave %d, %q", status, stdout)
+	}
+
+	// lossy conversions fail, or are kept with -skip
+	short, _ := pbkdf.EncodePasswordKDF(pbkdf.PBKDF2Parameters{Hash: crypto.SHA256, Iterations: 1000}, "password", 16, 16)
+	lossy := phc + "\n" + short + "\n"
+
+	if status, _, stderr := runCommand(lossy, "convert", "-from", "phc", "-to", "django"); status != exitError || !strings.Contains(stderr, "line 2") {
+		t.Errorf("error in TestConvert function: lossy conversion gave %d, %q", status, stderr)
+	}
+
+	if status, stdout, _ := runCommand(lossy, "convert", "-from", "phc", "-to", "django", "-skip"); status != exitFindings || stdout != django+"\n"+short+"\n" {
+		t.Errorf("error in TestConvert function: skipped conversion gave %d, %q", status, stdout)
+	}
+
+	// the CSV rows converted before a failure are written
+	lossyCSV := "id,password\n1," + phc + "\n2," + short + "\n"
+	if status, stdout, _ := runCommand(lossyCSV, "convert", "-from", "phc", "-to", "django", "-input", "csv"); status != exitError || stdout != "id,password\n1,"+django+"\n" {
+		t.Errorf("error in TestConvert function: failed CSV conversion gave %d, %q", status, stdout)
+	}
+
+	// JSONL objects without the field or with another type are refused, or kept with -skip
+	wrongField := `{"pwd":"` + django + `"}` + "\n" + `{"password":42}` + "\n"
+	if status, _, stderr := runCommand(wrongField, "convert", "-from", "django", "-to", "phc", "-input", "jsonl"); status != exitError || !strings.Contains(stderr, `line 1: no field "password"`) {
+		t.Errorf("error in TestConvert function: JSONL conversion with a wrong field gave %d, %q", status, stderr)
+	}
+
+	if status, stdout, stderr := runCommand(wrongField, "convert", "-from", "django", "-to", "phc", "-input", "jsonl", "-skip"); status != exitFindings || stdout != wrongField ||
+		!strings.Contains(stderr, "line 2: the password field is not a string") {
+		t.Errorf("error in TestConvert function: skipped JSONL conversion gave %d, %q, %q", status, stdout, stderr)
+	}
+
+	// the colon format needs the hash
+	colon, _ := pbkdf.ConvertPasswordHash(phc, pbkdf.FormatPHC, pbkdf.FormatColon, pbkdf.ConvertOptions{Hash: crypto.SHA256})
+	if status, stdout, _ := runCommand(phc, "convert", "-from", "phc", "-to", "colon", "-hash", "sha256"); status != exitOK || stdout != colon+"\n" {
+		t.Errorf("error in TestConvert function: colon conversion gave %d, %q", status, stdout)
+	}
+
+	if status, _, _ := runCommand(phc, "convert", "-from", "phc", "-to", "colon"); status != exitError {
+		t.Errorf("error in TestConvert function: colon conversion without a hash gave %d", status)
+	}
+
+	// colon strings of the versions before PBKDF2 used HMAC
+	legacy := "c2FsdA==:2:nar1Fck3qez3lS3S01tE0oMFuKg="
+	if status, stdout, _ := runCommand(legacy, "convert", "-from", "colon", "-to", "phc", "-hash", "sha1", "-legacy"); status != exitOK || stdout != "$pbkdf2-legacy-sha1$i=2$c2FsdA$nar1Fck3qez3lS3S01tE0oMFuKg\n" {
+		t.Errorf("error in TestConvert function: legacy conversion gave %d, %q", status, stdout)
+	}
+
+	// usage errors
+	for _, args := range [][]string{{"-from", "phc"}, {"-from", "phc", "-to", "bcrypt"}, {"-from", "phc", "-to", "django", "-workers", "0"}, {"-from", "phc", "-to", "django", "-input", "csv", "-field", "hash"}} {
+		if status, _, _ := runCommand("password\n", append([]string{"convert"}, args...)...); status != exitError {
+			t.Errorf("error in TestConvert function: %v gave %d", args, status)
+		}
+	}
+}
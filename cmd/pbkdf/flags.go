@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/giovanibageston/pbkdf/v2"
+)
+
+// hash functions by the names used in registry names
+var hashes = map[string]crypto.Hash{
+	"md5":         crypto.MD5,
+	"sha1":        crypto.SHA1,
+	"sha224":      crypto.SHA224,
+	"sha256":      crypto.SHA256,
+	"sha384":      crypto.SHA384,
+	"sha512":      crypto.SHA512,
+	"sha512-224":  crypto.SHA512_224,
+	"sha512-256":  crypto.SHA512_256,
+	"sha3-224":    crypto.SHA3_224,
+	"sha3-256":    crypto.SHA3_256,
+	"sha3-384":    crypto.SHA3_384,
+	"sha3-512":    crypto.SHA3_512,
+	"blake2b-256": crypto.BLAKE2b_256,
+	"blake2b-384": crypto.BLAKE2b_384,
+	"blake2b-512": crypto.BLAKE2b_512,
+}
+
+// kdfs by name
+var kdfs = map[string]pbkdf.PBKDF{
+	"pbkdf1":        pbkdf.PBKDF1,
+	"pbkdf2":        pbkdf.PBKDF2,
+	"pbkdf2-legacy": pbkdf.PBKDF2Legacy,
+}
+
+// parseHash returns the hash function with the given name
+func parseHash(name string) (crypto.Hash, error) {
+	hash, ok := hashes[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown hash %q, use one of %s", name, strings.Join(sortedKeys(hashes), ", "))
+	}
+
+	if !hash.Available() {
+		return 0, fmt.Errorf("hash %q is not available in this binary", name)
+	}
+
+	return hash, nil
+}
+
+// hasherFlags holds the flags mapped to the Hasher options
+type hasherFlags struct {
+	hash       string
+	kdf        string
+	saltLength int64
+	iterations int64
+	keyLength  int64
+
+	algorithm         string
+	params            string
+	argon2Passes      int64
+	argon2Memory      int64
+	argon2Parallelism int64
+
+	policy     bool
+	minLength  int
+	blocklist  string
+	context    string
+	breached   string
+	compliance bool
+}
+
+// register adds the flags to a flag set
+func (f *hasherFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.hash, "hash", "sha256", "hash function of the PRF: "+strings.Join(sortedKeys(hashes), ", "))
+	fs.StringVar(&f.kdf, "kdf", "pbkdf2", "key derivation function of salt:iterationCount:hash strings: "+strings.Join(sortedKeys(kdfs), ", "))
+	fs.Int64Var(&f.saltLength, "salt-length", pbkdf.DefaultSaltLength, "salt length in bytes")
+	fs.Int64Var(&f.iterations, "iterations", pbkdf.DefaultIterationCount, "iteration count")
+	fs.Int64Var(&f.keyLength, "key-length", pbkdf.DefaultKeyLength, "derived key length in bytes")
+
+	fs.StringVar(&f.algorithm, "algorithm", "", "registered KDF(pbkdf2-sha256, scrypt, argon2id, ...), the password is encoded as a PHC string")
+	fs.StringVar(&f.params, "params", "", "parameters of -algorithm as name=value pairs separated by commas(ln=15,r=8,p=1), PBKDF1 and PBKDF2 default to i=-iterations")
+	fs.Int64Var(&f.argon2Passes, "argon2-passes", pbkdf.DefaultArgon2Passes, "Argon2 passes")
+	fs.Int64Var(&f.argon2Memory, "argon2-memory", pbkdf.DefaultArgon2Memory, "Argon2 memory in KiB")
+	fs.Int64Var(&f.argon2Parallelism, "argon2-parallelism", pbkdf.DefaultArgon2Parallelism, "Argon2 parallelism")
+
+	fs.BoolVar(&f.policy, "policy", false, "check the password against the NIST SP 800-63B default policy before hashing")
+	fs.IntVar(&f.minLength, "min-length", 0, "minimum password length of the policy, implies -policy")
+	fs.StringVar(&f.blocklist, "blocklist", "", "file of blocked passwords of the policy, one per line, implies -policy")
+	fs.StringVar(&f.context, "context", "", "context words rejected by the policy(username, service name, ...) separated by commas, implies -policy")
+	fs.StringVar(&f.breached, "breached", "", "Pwned Passwords file or compact index, breached passwords are rejected")
+	fs.BoolVar(&f.compliance, "compliance", false, "enable the NIST SP 800-132 compliance mode")
+}
+
+// contextWords returns the words of -context
+func (f *hasherFlags) contextWords() []string {
+	if f.context == "" {
+		return nil
+	}
+
+	return strings.Split(f.context, ",")
+}
+
+// hasher creates the Hasher configured by the flags
+// the returned function releases the resources opened for the validators
+func (f *hasherFlags) hasher() (*pbkdf.Hasher, func(), error) {
+	closer := func() {}
+
+	// compliance mode must be enabled before the first derivation
+	if f.compliance {
+		if err := pbkdf.EnableComplianceMode(pbkdf.DefaultCompliancePolicy()); err != nil {
+			return nil, closer, err
+		}
+	}
+
+	// PBKDF options
+	hash, err := parseHash(f.hash)
+	if err != nil {
+		return nil, closer, err
+	}
+
+	kdf, ok := kdfs[strings.ToLower(f.kdf)]
+	if !ok {
+		return nil, closer, fmt.Errorf("unknown kdf %q, use one of %s", f.kdf, strings.Join(sortedKeys(kdfs), ", "))
+	}
+
+	options := []pbkdf.HasherOption{
+		pbkdf.WithHash(hash),
+		pbkdf.WithKDF(kdf),
+		pbkdf.WithSaltLength(f.saltLength),
+		pbkdf.WithIterationCount(f.iterations),
+		pbkdf.WithKeyLength(f.keyLength),
+	}
+
+	// KDF options
+	if f.algorithm != "" {
+		option, err := f.algorithmOption()
+		if err != nil {
+			return nil, closer, err
+		}
+
+		options = append(options, option)
+	}
+
+	// validation options
+	if f.policy || f.minLength > 0 || f.blocklist != "" || f.context != "" {
+		policy := pbkdf.DefaultPasswordPolicy()
+		if f.minLength > 0 {
+			policy.MinLength = f.minLength
+		}
+
+		if f.blocklist != "" {
+			words, err := readLines(f.blocklist)
+			if err != nil {
+				return nil, closer, err
+			}
+
+			policy.Blocklist = words
+		}
+
+		options = append(options, pbkdf.WithPasswordPolicy(policy))
+	}
+
+	if f.breached != "" {
+		checker, err := pbkdf.OpenBreachedPasswordChecker(f.breached)
+		if err != nil {
+			return nil, closer, err
+		}
+
+		closer = func() { checker.Close() }
+		options = append(options, pbkdf.WithPasswordValidator(checker))
+	}
+
+	h, err := pbkdf.NewHasher(options...)
+	if err != nil {
+		closer()
+		return nil, func() {}, err
+	}
+
+	return h, closer, nil
+}
+
+// algorithmOption returns the WithArgon2 or WithAlgorithm option of -algorithm
+func (f *hasherFlags) algorithmOption() (pbkdf.HasherOption, error) {
+	name := strings.ToLower(f.algorithm)
+
+	// Argon2 has its own flags
+	for _, variant := range []pbkdf.Argon2Variant{pbkdf.Argon2d, pbkdf.Argon2i, pbkdf.Argon2id} {
+		if name == variant.String() && f.params == "" {
+			return pbkdf.WithArgon2(variant, f.argon2Passes, f.argon2Memory, f.argon2Parallelism), nil
+		}
+	}
+
+	// the other algorithms are created from the registry
+	params := make(map[string]int64)
+	if f.params == "" && strings.HasPrefix(name, "pbkdf") {
+		params["i"] = f.iterations
+	}
+
+	if f.params != "" {
+		for _, pair := range strings.Split(f.params, ",") {
+			key, value, ok := strings.Cut(pair, "=")
+			n, err := strconv.ParseInt(value, 10, 64)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("invalid parameter %q, parameters must be name=value", pair)
+			}
+
+			params[key] = n
+		}
+	}
+
+	kdf, err := pbkdf.NewKDF(name, params)
+	if err != nil {
+		if errors.Is(err, pbkdf.ErrUnknownKDF) {
+			return nil, fmt.Errorf("unknown algorithm %q, use one of %s", f.algorithm, strings.Join(pbkdf.RegisteredKDFs(), ", "))
+		}
+
+		return nil, err
+	}
+
+	return pbkdf.WithAlgorithm(kdf), nil
+}
+
+// readLines returns the non-empty lines of a file without the line endings
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/giovanibageston/pbkdf/v2"
+)
+
+// hashOutput is the JSON output of the hash command
+type hashOutput struct {
+	Hash       string `json:"hash"`
+	Algorithm  string `json:"algorithm,omitempty"`
+	Iterations int64  `json:"iterations"`
+	SaltLength int    `json:"saltLength"`
+	KeyLength  int    `json:"keyLength"`
+}
+
+// runHash reads a password and prints its encoded string
+func runHash(env environment, args []string) int {
+	var (
+		hasherFlags   hasherFlags
+		passwordFlags passwordFlags
+	)
+
+	fs := newFlagSet(env, "hash", "")
+	hasherFlags.register(fs)
+	passwordFlags.register(fs)
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+
+	if status := parseFlags(fs, args); status >= 0 {
+		return status
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	// create the hasher before reading the password so invalid flags fail early
+	hasher, closer, err := hasherFlags.hasher()
+	if err != nil {
+		return fail(env, *asJSON, err)
+	}
+	defer closer()
+
+	password, err := passwordFlags.readPassword(env, true)
+	if err != nil {
+		return fail(env, *asJSON, err)
+	}
+
+	encoded, err := hasher.HashWithContext(password, hasherFlags.contextWords()...)
+	if err != nil {
+		return fail(env, *asJSON, err)
+	}
+
+	// print the result
+	if !*asJSON {
+		fmt.Fprintln(env.stdout, encoded)
+		return exitOK
+	}
+
+	parsed, err := pbkdf.ParsePasswordHash(encoded)
+	if err != nil {
+		return fail(env, *asJSON, err)
+	}
+
+	writeJSON(env.stdout, hashOutput{
+		Hash:       encoded,
+		Algorithm:  parsed.Algorithm(),
+		Iterations: parsed.Iterations(),
+		SaltLength: len(parsed.Salt()),
+		KeyLength:  parsed.KeyLength(),
+	})
+
+	return exitOK
+}
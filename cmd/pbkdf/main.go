@@ -0,0 +1,170 @@
+// Command pbkdf hashes and verifies passwords with the pbkdf package
+//
+// Usage:
+//
+//	pbkdf hash [flags]
+//	pbkdf verify [flags] encodedPassword
+//	pbkdf bench [flags]
+//	pbkdf audit [flags] [file]
+//	pbkdf convert -from format -to format [flags] [file]
+//
+// The password is never passed as an argument, it is read from the terminal without echo,
+// from the first line of the standard input or from the file descriptor given with -password-fd
+//
+// The exit status of verify is 0 if the password matches, 1 if it doesn't and 2 on errors,
+// the one of audit is 0 if no entry is flagged, 1 if some are and 2 on errors,
+// the one of convert is 1 when -skip left entries unchanged, the other commands exit with 0 on success and 2 on errors
+// Every command prints JSON instead of text with -json
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/giovanibageston/pbkdf/v2"
+)
+
+// exit statuses
+const (
+	// exitOK is returned on success and when a password matches
+	exitOK = 0
+	// exitMismatch is returned when a password doesn't match
+	exitMismatch = 1
+	// exitFindings is returned when the audit flags an entry
+	exitFindings = 1
+	// exitError is returned on usage and runtime errors
+	exitError = 2
+)
+
+// environment of a command, replaced by the tests
+type environment struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// command is a subcommand of the tool
+type command struct {
+	summary string
+	run     func(env environment, args []string) int
+}
+
+// commands by name
+var commands = map[string]command{
+	"audit":   {"report the parameters of encoded passwords and flag the weak ones", runAudit},
+	"convert": {"rewrite encoded passwords in another format", runConvert},
+	"bench":   {"measure the PBKDF1 and PBKDF2 throughput and the iteration counts of a target latency", runBench},
+	"hash":    {"hash a password and print the encoded string", runHash},
+	"verify":  {"verify a password against an encoded string", runVerify},
+}
+
+func main() {
+	os.Exit(run(environment{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}, os.Args[1:]))
+}
+
+// run runs the subcommand named by the first argument and returns the exit status
+func run(env environment, args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(env.stderr)
+		if len(args) == 0 {
+			return exitError
+		}
+
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(env.stderr, "pbkdf: unknown command %q\n", args[0])
+		usage(env.stderr)
+		return exitError
+	}
+
+	return cmd.run(env, args[1:])
+}
+
+// usage prints the list of commands
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage: pbkdf <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `run "pbkdf <command> -h" for the flags of a command`)
+}
+
+// newFlagSet creates the flag set of a subcommand, errors are returned instead of exiting
+func newFlagSet(env environment, name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(env.stderr, "usage: pbkdf %s [flags] %s\n\nflags:\n", name, arguments)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parseFlags parses the arguments of a subcommand
+// the returned status is -1 if the command should continue
+func parseFlags(fs *flag.FlagSet, args []string) int {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+
+		return exitError
+	}
+
+	return -1
+}
+
+// jsonError is the JSON output of a failed command
+type jsonError struct {
+	Error      string                    `json:"error"`
+	Violations []pbkdf.PasswordViolation `json:"violations,omitempty"`
+}
+
+// fail reports an error as text on the standard error or as JSON on the standard output and returns exitError
+func fail(env environment, asJSON bool, err error) int {
+	if !asJSON {
+		fmt.Fprintf(env.stderr, "pbkdf: %s\n", err.Error())
+		return exitError
+	}
+
+	// include the policy violations so scripts don't have to parse the message
+	out := jsonError{Error: err.Error()}
+
+	var policyErr *pbkdf.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		out.Violations = policyErr.Violations
+	}
+
+	writeJSON(env.stdout, out)
+	return exitError
+}
+
+// writeJSON writes v as a single line of JSON
+func writeJSON(w io.Writer, v any) {
+	data, err := json.Marshal(v)
+
+	// check if an error occurred, the outputs are plain structures so it should not happen
+	if err != nil {
+		data = []byte(`{"error":"encoding JSON output"}`)
+	}
+
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// runCommand runs the tool with the given standard input and returns the exit status and outputs
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+
+	status := run(environment{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}, args)
+	return status, stdout.String(), stderr.String()
+}
+
+// tests hashing and verifying with the exit statuses and the JSON output
+func TestHashVerify(t *testing.T) {
+	status, encoded, stderr := runCommand("correct horse\n", "hash", "-iterations", "1000", "-salt-length", "24")
+	encoded = strings.TrimSpace(encoded)
+	if status != exitOK || strings.Count(encoded, ":") != 2 {
+		t.Fatalf("error in TestHashVerify function: hash gave %d, %q, %q", status, encoded, stderr)
+	}
+
+	// match, mismatch and rehash
+	tests := []struct {
+		password string
+		args     []string
+		status   int
+		output   string
+	}{
+		{"correct horse\n", []string{"-iterations", "1000", "-salt-length", "24"}, exitOK, "match\n"},
+		{"correct horse", []string{"-iterations", "1000", "-salt-length", "24"}, exitOK, "match\n"},
+		{"correct horse\r\n", []string{}, exitOK, "match, needs rehash\n"},
+		{"wrong horse\n", []string{}, exitMismatch, "mismatch\n"},
+		{"correct horse\n", []string{"-hash", "sha512"}, exitMismatch, "mismatch\n"},
+		{"", []string{}, exitError, ""},
+		{"correct horse\n", []string{"-hash", "sha0"}, exitError, ""},
+	}
+
+	for _, test := range tests {
+		args := append(append([]string{"verify"}, test.args...), encoded)
+		if status, stdout, _ := runCommand(test.password, args...); status != test.status || stdout != test.output {
+			t.Errorf("error in TestHashVerify function: verify %v gave %d, %q", test.args, status, stdout)
+		}
+	}
+
+	// JSON output of a PHC string
+	status, stdout, _ := runCommand("correct horse\n", "hash", "-json", "-algorithm", "scrypt", "-params", "ln=4,r=8,p=1", "-key-length", "16")
+
+	var hashed hashOutput
+	if err := json.Unmarshal([]byte(stdout), &hashed); status != exitOK || err != nil || hashed.Algorithm != "scrypt" || hashed.KeyLength != 16 {
+		t.Fatalf("error in TestHashVerify function: JSON hash gave %d, %q", status, stdout)
+	}
+
+	status, stdout, _ = runCommand("correct horse\n", "verify", "-json", hashed.Hash)
+
+	var verified verifyOutput
+	if err := json.Unmarshal([]byte(stdout), &verified); status != exitOK || err != nil || !verified.Match || !verified.NeedsRehash {
+		t.Errorf("error in TestHashVerify function: JSON verify gave %d, %q", status, stdout)
+	}
+
+	// strings of other registered formats are detected
+	django := "pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c="
+	if status, stdout, _ := runCommand("password\n", "verify", django); status != exitOK || stdout != "match, needs rehash\n" {
+		t.Errorf("error in TestHashVerify function: verify of a Django string gave %d, %q", status, stdout)
+	}
+
+	if status, _, stderr := runCommand("password\n", "verify", "bcrypt"); status != exitError || !strings.Contains(stderr, "unknown password hash format") {
+		t.Errorf("error in TestHashVerify function: verify of an unknown format gave %d, %q", status, stderr)
+	}
+
+	// errors are JSON too
+	status, stdout, _ = runCommand("correct horse\n", "verify", "-json", "$unknown$i=1$c2FsdA$a2V5")
+	if status != exitError || !strings.HasPrefix(stdout, `{"error":`) {
+		t.Errorf("error in TestHashVerify function: JSON error gave %d, %q", status, stdout)
+	}
+}
+
+// tests the validation flags
+func TestHashPolicy(t *testing.T) {
+	blocklist := t.TempDir() + "/blocklist.txt"
+	os.WriteFile(blocklist, []byte("letmein123\r\npassword1\n"), 0o600)
+
+	tests := []struct {
+		password string
+		args     []string
+		status   int
+		code     string
+	}{
+		{"short\n", []string{"-policy"}, exitError, "password.too_short"},
+		{"long enough\n", []string{"-min-length", "12"}, exitError, "password.too_short"},
+		{"LetMeIn123\n", []string{"-blocklist", blocklist}, exitError, "password.blocklisted"},
+		{"alice-secret\n", []string{"-context", "alice"}, exitError, "password.context_word"},
+		{"alice-secret\n", []string{"-policy"}, exitOK, ""},
+	}
+
+	for _, test := range tests {
+		args := append([]string{"hash", "-json", "-iterations", "1000"}, test.args...)
+		status, stdout, _ := runCommand(test.password, args...)
+
+		var out jsonError
+		json.Unmarshal([]byte(stdout), &out)
+
+		if status != test.status || test.code != "" && (len(out.Violations) != 1 || string(out.Violations[0].Code) != test.code) {
+			t.Errorf("error in TestHashPolicy function: %v gave %d, %q", test.args, status, stdout)
+		}
+	}
+}
+
+// tests reading the password from a file descriptor, the standard input is not read
+func TestPasswordFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("error in TestPasswordFD function while creating a pipe: %s", err.Error())
+	}
+	defer r.Close()
+
+	fmt.Fprintln(w, "from the pipe")
+	w.Close()
+
+	fd := fmt.Sprint(r.Fd())
+	status, encoded, _ := runCommand("from stdin\n", "hash", "-iterations", "1000", "-password-fd", fd)
+	if status != exitOK {
+		t.Fatalf("error in TestPasswordFD function: hash gave %d", status)
+	}
+
+	if status, _, _ := runCommand("from the pipe\n", "verify", "-iterations", "1000", strings.TrimSpace(encoded)); status != exitOK {
+		t.Errorf("error in TestPasswordFD function: password was not read from the pipe")
+	}
+}
+
+// tests the usage errors
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"unknown"}, {"hash", "extra"}, {"verify"}, {"hash", "-undefined"}, {"hash", "-kdf", "md4"}, {"hash", "-algorithm", "md4"}} {
+		if status, _, _ := runCommand("password\n", args...); status != exitError {
+			t.Errorf("error in TestUsage function: %v gave %d", args, status)
+		}
+	}
+
+	if status, _, stderr := runCommand("", "hash", "-h"); status != exitOK || !strings.Contains(stderr, "-password-fd") {
+		t.Errorf("error in TestUsage function: help gave %d, %q", status, stderr)
+	}
+}
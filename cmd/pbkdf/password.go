@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxPasswordLength is the longest password line read, longer lines are an error instead of being truncated
+const maxPasswordLength = 4096
+
+// passwordFlags holds the flags selecting where passwords are read from
+type passwordFlags struct {
+	fd int
+}
+
+// register adds the flags to a flag set
+func (f *passwordFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&f.fd, "password-fd", -1, "read the password from the first line of this file descriptor instead of the terminal or the standard input")
+}
+
+// readPassword reads a password
+// from the file descriptor of -password-fd, or else from the terminal without echo when the standard input is one,
+// or else from the first line of the standard input
+// The confirm parameter asks for the password twice on a terminal
+func (f *passwordFlags) readPassword(env environment, confirm bool) (string, error) {
+	// file descriptor
+	if f.fd >= 0 {
+		file := os.NewFile(uintptr(f.fd), fmt.Sprintf("fd %d", f.fd))
+		if file == nil {
+			return "", fmt.Errorf("invalid file descriptor %d", f.fd)
+		}
+
+		return readPasswordLine(file)
+	}
+
+	// terminal
+	if file, ok := env.stdin.(*os.File); ok && isTerminal(file) {
+		password, err := promptPassword(env, file, "Password: ")
+		if err != nil || !confirm {
+			return password, err
+		}
+
+		again, err := promptPassword(env, file, "Confirm password: ")
+		if err != nil {
+			return "", err
+		}
+
+		if password != again {
+			return "", errors.New("passwords do not match")
+		}
+
+		return password, nil
+	}
+
+	// standard input
+	return readPasswordLine(env.stdin)
+}
+
+// promptPassword prints the prompt on the standard error and reads a line from the terminal without echo
+func promptPassword(env environment, terminal *os.File, prompt string) (string, error) {
+	fmt.Fprint(env.stderr, prompt)
+	defer fmt.Fprintln(env.stderr)
+
+	restore, err := disableEcho(terminal)
+	if err != nil {
+		return "", fmt.Errorf("disabling terminal echo: %s", err.Error())
+	}
+	defer restore()
+
+	return readPasswordLine(terminal)
+}
+
+// readPasswordLine reads the first line of r without the line ending
+// bytes are read one at a time so nothing after the line is consumed,
+// an empty input is an error but an empty line is an empty password
+func readPasswordLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+
+			if len(line) == maxPasswordLength {
+				return "", fmt.Errorf("password longer than %d bytes", maxPasswordLength)
+			}
+
+			line = append(line, b[0])
+			continue
+		}
+
+		// check if an error occurred
+		if err == io.EOF {
+			if len(line) == 0 {
+				return "", errors.New("no password on input")
+			}
+
+			break
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("reading password: %s", err.Error())
+		}
+	}
+
+	return string(bytes.TrimSuffix(line, []byte("\r"))), nil
+}
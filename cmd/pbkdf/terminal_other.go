@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// isTerminal always reports false on platforms where the echo can not be disabled,
+// passwords are then read from the standard input or -password-fd
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+// disableEcho is not supported
+func disableEcho(f *os.File) (func(), error) {
+	return nil, errors.New("not supported on this platform")
+}
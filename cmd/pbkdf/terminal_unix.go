@@ -0,0 +1,44 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// isTerminal reports whether the file is a terminal
+func isTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	return ioctlTermios(f.Fd(), ioctlGetTermios, &termios) == nil
+}
+
+// disableEcho turns off the echo of the terminal, the returned function restores its previous state
+func disableEcho(f *os.File) (func(), error) {
+	var old syscall.Termios
+	if err := ioctlTermios(f.Fd(), ioctlGetTermios, &old); err != nil {
+		return nil, err
+	}
+
+	// keep the line editing and the signals, only the echo is removed
+	termios := old
+	termios.Lflag &^= syscall.ECHO
+	termios.Lflag |= syscall.ICANON | syscall.ISIG
+	termios.Iflag |= syscall.ICRNL
+
+	if err := ioctlTermios(f.Fd(), ioctlSetTermios, &termios); err != nil {
+		return nil, err
+	}
+
+	return func() { ioctlTermios(f.Fd(), ioctlSetTermios, &old) }, nil
+}
+
+// ioctlTermios gets or sets the terminal attributes
+func ioctlTermios(fd uintptr, request uintptr, termios *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(termios))); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
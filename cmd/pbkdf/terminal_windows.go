@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// console input mode flags
+const (
+	enableLineInput      = 0x2
+	enableEchoInput      = 0x4
+	enableProcessedInput = 0x1
+)
+
+// SetConsoleMode is not in the syscall package
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// isTerminal reports whether the file is a console
+func isTerminal(f *os.File) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(f.Fd()), &mode) == nil
+}
+
+// disableEcho turns off the echo of the console, the returned function restores its previous mode
+func disableEcho(f *os.File) (func(), error) {
+	var old uint32
+	if err := syscall.GetConsoleMode(syscall.Handle(f.Fd()), &old); err != nil {
+		return nil, err
+	}
+
+	// keep the line editing and Ctrl+C, only the echo is removed
+	mode := old&^enableEchoInput | enableLineInput | enableProcessedInput
+	if r, _, err := setConsoleMode.Call(f.Fd(), uintptr(mode)); r == 0 {
+		return nil, err
+	}
+
+	return func() { setConsoleMode.Call(f.Fd(), uintptr(old)) }, nil
+}
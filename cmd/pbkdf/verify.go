@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/giovanibageston/pbkdf/v2"
+)
+
+// verifyOutput is the JSON output of the verify command
+type verifyOutput struct {
+	Match       bool `json:"match"`
+	NeedsRehash bool `json:"needsRehash"`
+}
+
+// runVerify reads a password and checks it against the encoded string given as argument
+// the format of the string is detected, salt:iterationCount:hash strings are verified with -hash and -kdf,
+// the other ones(PHC, Django, passlib, ...) with the algorithm they name
+// the rehash check compares the string with the other hasher flags
+func runVerify(env environment, args []string) int {
+	var (
+		hasherFlags   hasherFlags
+		passwordFlags passwordFlags
+	)
+
+	fs := newFlagSet(env, "verify", "encodedPassword")
+	hasherFlags.register(fs)
+	passwordFlags.register(fs)
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+
+	if status := parseFlags(fs, args); status >= 0 {
+		return status
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	encoded := fs.Arg(0)
+
+	hasher, closer, err := hasherFlags.hasher()
+	if err != nil {
+		return fail(env, *asJSON, err)
+	}
+	defer closer()
+
+	password, err := passwordFlags.readPassword(env, false)
+	if err != nil {
+		return fail(env, *asJSON, err)
+	}
+
+	format, err := pbkdf.DetectPasswordFormat(encoded)
+	if err != nil {
+		return fail(env, *asJSON, err)
+	}
+
+	var match bool
+	if format.Name() == pbkdf.FormatColon {
+		match, err = hasher.Verify(password, encoded)
+	} else {
+		match, err = pbkdf.Verify(password, encoded)
+	}
+
+	if err != nil {
+		return fail(env, *asJSON, err)
+	}
+
+	// print the result
+	out := verifyOutput{Match: match, NeedsRehash: match && hasher.NeedsRehash(encoded)}
+
+	if *asJSON {
+		writeJSON(env.stdout, out)
+	} else {
+		switch {
+		case out.NeedsRehash:
+			fmt.Fprintln(env.stdout, "match, needs rehash")
+		case match:
+			fmt.Fprintln(env.stdout, "match")
+		default:
+			fmt.Fprintln(env.stdout, "mismatch")
+		}
+	}
+
+	if !match {
+		return exitMismatch
+	}
+
+	return exitOK
+}
@@ -0,0 +1,206 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNotCompliant is wrapped by every error caused by a compliance mode rule
+var ErrNotCompliant = errors.New("not compliant with NIST SP 800-132")
+
+// minimum values required by NIST SP 800-132, a CompliancePolicy can only be stricter
+const (
+	// SP800132MinSaltLength is the minimum salt length in bytes(128 bits)
+	SP800132MinSaltLength int64 = 16
+	// SP800132MinKeyLength is the minimum derived key length in bytes(112 bits)
+	SP800132MinKeyLength int64 = 14
+	// SP800132MinIterationCount is the minimum iteration count
+	SP800132MinIterationCount int64 = 1000
+)
+
+// CompliancePolicy holds the rules enforced on every derivation while compliance mode is enabled
+type CompliancePolicy struct {
+	// ApprovedHashes are the hash functions allowed as the HMAC hash
+	ApprovedHashes []crypto.Hash
+	// MinSaltLength is the minimum salt length in bytes
+	MinSaltLength int64
+	// MinKeyLength is the minimum derived key length in bytes
+	MinKeyLength int64
+	// MinIterationCount is the minimum iteration count
+	MinIterationCount int64
+}
+
+// DefaultCompliancePolicy returns the SP 800-132 rules
+// HMAC with SHA-1, SHA-2 or SHA-3, a salt of at least 128 bits, a key of at least 112 bits and at least 1000 iterations
+// SP 800-132 calls 1000 iterations a minimum, raise MinIterationCount to the value used by the application
+func DefaultCompliancePolicy() CompliancePolicy {
+	return CompliancePolicy{
+		ApprovedHashes: []crypto.Hash{
+			crypto.SHA1,
+			crypto.SHA224, crypto.SHA256, crypto.SHA384, crypto.SHA512, crypto.SHA512_224, crypto.SHA512_256,
+			crypto.SHA3_224, crypto.SHA3_256, crypto.SHA3_384, crypto.SHA3_512,
+		},
+		MinSaltLength:     SP800132MinSaltLength,
+		MinKeyLength:      SP800132MinKeyLength,
+		MinIterationCount: SP800132MinIterationCount,
+	}
+}
+
+// current compliance policy, nil when compliance mode is disabled
+var compliancePolicy atomic.Pointer[CompliancePolicy]
+
+// result of the known-answer self-test, it runs once per process
+var (
+	selfTestOnce sync.Once
+	selfTestErr  error
+)
+
+// EnableComplianceMode enables compliance mode for the whole process
+// The policy parameter holds the rules, it must not be weaker than the SP 800-132 minimums
+// while enabled PBKDF2 checks every derivation against the policy,
+// PBKDF1 and PBKDF2Legacy always fail and the known-answer self-test runs before the first derivation
+func EnableComplianceMode(policy CompliancePolicy) error {
+	// check the policy against the SP 800-132 minimums
+	switch {
+	case len(policy.ApprovedHashes) == 0:
+		return errors.New("error in EnableComplianceMode function: the policy must approve at least one hash")
+	case policy.MinSaltLength < SP800132MinSaltLength:
+		return fmt.Errorf("error in EnableComplianceMode function: minimum salt length must be at least %d bytes", SP800132MinSaltLength)
+	case policy.MinKeyLength < SP800132MinKeyLength:
+		return fmt.Errorf("error in EnableComplianceMode function: minimum key length must be at least %d bytes", SP800132MinKeyLength)
+	case policy.MinIterationCount < SP800132MinIterationCount:
+		return fmt.Errorf("error in EnableComplianceMode function: minimum iteration count must be at least %d", SP800132MinIterationCount)
+	}
+
+	// approved hashes must be approved by SP 800-132 too
+	approved := DefaultCompliancePolicy().ApprovedHashes
+	for _, hash := range policy.ApprovedHashes {
+		if !containsHash(approved, hash) {
+			return fmt.Errorf("error in EnableComplianceMode function: hash function %d is not an approved HMAC hash", hash)
+		}
+	}
+
+	// store a copy so later changes to the caller's slice have no effect
+	policy.ApprovedHashes = append([]crypto.Hash{}, policy.ApprovedHashes...)
+	compliancePolicy.Store(&policy)
+
+	return nil
+}
+
+// DisableComplianceMode disables compliance mode
+func DisableComplianceMode() {
+	compliancePolicy.Store(nil)
+}
+
+// ComplianceModeEnabled reports whether compliance mode is enabled
+func ComplianceModeEnabled() bool {
+	return currentCompliancePolicy() != nil
+}
+
+// currentCompliancePolicy returns the policy in force or nil
+func currentCompliancePolicy() *CompliancePolicy {
+	return compliancePolicy.Load()
+}
+
+// ComplianceSelfTest runs the PBKDF2 known-answer self-test
+// it runs once per process and the result is cached, compliance mode calls it before the first derivation
+func ComplianceSelfTest() error {
+	selfTestOnce.Do(func() {
+		selfTestErr = runComplianceSelfTest()
+	})
+
+	return selfTestErr
+}
+
+// runComplianceSelfTest checks the PBKDF2 output against known answers
+// the vectors are from RFC 6070(HMAC-SHA-1) and RFC 7914(HMAC-SHA-256)
+func runComplianceSelfTest() error {
+	vectors := []struct {
+		hash     crypto.Hash
+		P, S     string
+		c        int64
+		expected string
+	}{
+		{crypto.SHA1, "password", "salt", 2, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{crypto.SHA256, "passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	}
+
+	for _, v := range vectors {
+		// decode the expected key
+		expected, _ := hex.DecodeString(v.expected)
+
+		// derive the key bypassing the policy, the vectors use short salts
+		DK, err := pbkdf2("PBKDF2", []byte(v.S), v.c, int64(len(expected)), newHMACPRF(v.hash, []byte(v.P)))
+
+		// check if an error occurred
+		if err != nil {
+			return fmt.Errorf("error in ComplianceSelfTest function: %w: %s", ErrNotCompliant, err.Error())
+		}
+
+		// compare with the known answer
+		if !bytes.Equal(DK, expected) {
+			return fmt.Errorf("error in ComplianceSelfTest function: %w: known-answer test failed for hash function %d", ErrNotCompliant, v.hash)
+		}
+	}
+
+	return nil
+}
+
+// checkPBKDF2Compliance checks the PBKDF2 parameters against the policy in force
+// nil is returned when compliance mode is disabled
+func checkPBKDF2Compliance(hash crypto.Hash, S []byte, c int64, dkLen int64) error {
+	// get the policy
+	policy := currentCompliancePolicy()
+	if policy == nil {
+		return nil
+	}
+
+	// the self-test must pass before the first derivation
+	if err := ComplianceSelfTest(); err != nil {
+		return err
+	}
+
+	// check the parameters
+	switch {
+	case !containsHash(policy.ApprovedHashes, hash):
+		return fmt.Errorf("%w: hash function %d is not approved", ErrNotCompliant, hash)
+	case int64(len(S)) < policy.MinSaltLength:
+		return fmt.Errorf("%w: salt is %d bits, at least %d bits are required", ErrNotCompliant, len(S)*8, policy.MinSaltLength*8)
+	case dkLen < policy.MinKeyLength:
+		return fmt.Errorf("%w: derived key is %d bits, at least %d bits are required", ErrNotCompliant, dkLen*8, policy.MinKeyLength*8)
+	case c < policy.MinIterationCount:
+		return fmt.Errorf("%w: iteration count is %d, at least %d iterations are required", ErrNotCompliant, c, policy.MinIterationCount)
+	}
+
+	return nil
+}
+
+// checkApprovedHash checks the hash is available and approved when compliance mode is enabled
+func checkApprovedHash(hash crypto.Hash) error {
+	if !hash.Available() {
+		return fmt.Errorf("hash function %d is not available", hash)
+	}
+
+	// the key-based KDFs of SP 800-56C and SP 800-108 are approved with an approved hash
+	if policy := currentCompliancePolicy(); policy != nil && !containsHash(policy.ApprovedHashes, hash) {
+		return fmt.Errorf("%w: hash function %d is not approved", ErrNotCompliant, hash)
+	}
+
+	return nil
+}
+
+// containsHash reports whether hashes contains hash
+func containsHash(hashes []crypto.Hash, hash crypto.Hash) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,56 @@
+package pbkdf
+
+import (
+	"crypto"
+	"errors"
+	"testing"
+)
+
+// tests the compliance mode rules
+func TestComplianceMode(t *testing.T) {
+	// weaker policies are refused
+	weak := DefaultCompliancePolicy()
+	weak.MinSaltLength = 8
+	if err := EnableComplianceMode(weak); err == nil {
+		t.Errorf("error in TestComplianceMode function: weak policy was accepted")
+	}
+
+	if err := EnableComplianceMode(DefaultCompliancePolicy()); err != nil {
+		t.Fatalf("error in TestComplianceMode function while enabling compliance mode: %s", err.Error())
+	}
+	defer DisableComplianceMode()
+
+	salt := make([]byte, 16)
+	tests := []struct {
+		name string
+		kdf  PBKDF
+		hash crypto.Hash
+		S    []byte
+		c    int64
+		dk   int64
+		ok   bool
+	}{
+		{"compliant", PBKDF2, crypto.SHA256, salt, 1000, 32, true},
+		{"PBKDF1", PBKDF1, crypto.SHA1, salt, 1000, 20, false},
+		{"legacy", PBKDF2Legacy, crypto.SHA256, salt, 1000, 32, false},
+		{"MD5", PBKDF2, crypto.MD5, salt, 1000, 16, false},
+		{"short salt", PBKDF2, crypto.SHA256, salt[:15], 1000, 32, false},
+		{"short key", PBKDF2, crypto.SHA256, salt, 1000, 13, false},
+		{"few iterations", PBKDF2, crypto.SHA256, salt, 999, 32, false},
+	}
+
+	for _, test := range tests {
+		_, err := test.kdf(test.hash, []byte("password"), test.S, test.c, test.dk)
+
+		if test.ok && err != nil {
+			t.Errorf("error in TestComplianceMode function: %s was rejected: %s", test.name, err.Error())
+		} else if !test.ok && !errors.Is(err, ErrNotCompliant) {
+			t.Errorf("error in TestComplianceMode function: %s was not rejected as non compliant: %v", test.name, err)
+		}
+	}
+
+	// the self-test ran on the first derivation
+	if err := ComplianceSelfTest(); err != nil {
+		t.Errorf("error in TestComplianceMode function: self-test failed: %s", err.Error())
+	}
+}
@@ -0,0 +1,167 @@
+package pbkdf
+
+import (
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// OtherInfo holds the fields of the OtherInfo input of the Concat KDF
+// It is based on the NIST SP 800-56A(https://csrc.nist.gov/pubs/sp/800/56/a/r3/final) section 5.8.2
+// AlgorithmID, PartyUInfo and PartyVInfo are variable length fields, they are encoded as Datalen || Data
+// with Datalen the byte length of Data as a 32 bit big-endian integer
+// SuppPubInfo and SuppPrivInfo are encoded as they are, so they must already hold their final encoding
+type OtherInfo struct {
+	// AlgorithmID identifies how the derived key will be used(for example the JOSE "enc" or "alg" value)
+	AlgorithmID []byte
+	// PartyUInfo is public information about party U(the JOSE "apu" value)
+	PartyUInfo []byte
+	// PartyVInfo is public information about party V(the JOSE "apv" value)
+	PartyVInfo []byte
+	// SuppPubInfo is the optional supplementary public information(the key length in bits for JOSE)
+	SuppPubInfo []byte
+	// SuppPrivInfo is the optional supplementary private information
+	SuppPrivInfo []byte
+}
+
+// Bytes returns the encoding AlgorithmID || PartyUInfo || PartyVInfo || SuppPubInfo || SuppPrivInfo
+func (o OtherInfo) Bytes() []byte {
+	encoded := make([]byte, 0, 12+len(o.AlgorithmID)+len(o.PartyUInfo)+len(o.PartyVInfo)+len(o.SuppPubInfo)+len(o.SuppPrivInfo))
+
+	// variable length fields are prefixed with their length
+	for _, field := range [][]byte{o.AlgorithmID, o.PartyUInfo, o.PartyVInfo} {
+		encoded = append(encoded, ConvertUnsignedIntegerToByteSlice(uint64(len(field)), 4, false)...)
+		encoded = append(encoded, field...)
+	}
+
+	encoded = append(encoded, o.SuppPubInfo...)
+	encoded = append(encoded, o.SuppPrivInfo...)
+
+	return encoded
+}
+
+// JOSEOtherInfo returns the OtherInfo used by JOSE ECDH-ES
+// It is based on the RFC7518(https://datatracker.ietf.org/doc/html/rfc7518) section 4.6.2
+// algorithm: the "enc" value for direct key agreement or the "alg" value for key wrapping(for example A128GCM or ECDH-ES+A128KW)
+// apu: the decoded "apu" header parameter, nil when absent
+// apv: the decoded "apv" header parameter, nil when absent
+// keyDataLen: the byte length of the derived key, SuppPubInfo is its length in bits as a 32 bit big-endian integer
+func JOSEOtherInfo(algorithm string, apu, apv []byte, keyDataLen int64) OtherInfo {
+	return OtherInfo{
+		AlgorithmID: []byte(algorithm),
+		PartyUInfo:  apu,
+		PartyVInfo:  apv,
+		SuppPubInfo: ConvertUnsignedIntegerToByteSlice(uint64(keyDataLen*8), 4, false),
+	}
+}
+
+// cmsSharedInfo is the ECC-CMS-SharedInfo structure of RFC5753
+type cmsSharedInfo struct {
+	KeyInfo     pkix.AlgorithmIdentifier
+	EntityUInfo []byte `asn1:"optional,explicit,tag:0"`
+	SuppPubInfo []byte `asn1:"explicit,tag:2"`
+}
+
+// CMSSharedInfo returns the DER encoded ECC-CMS-SharedInfo used as SharedInfo by the CMS ECDH key agreement
+// It is based on the RFC5753(https://datatracker.ietf.org/doc/html/rfc5753) section 7.2
+// keyWrap: the object identifier of the key wrap algorithm(for example 2.16.840.1.101.3.4.1.5 for AES-128 key wrap), it is encoded without parameters
+// ukm: the optional user keying material, nil when absent
+// keyLength: the byte length of the key encryption key
+func CMSSharedInfo(keyWrap asn1.ObjectIdentifier, ukm []byte, keyLength int64) ([]byte, error) {
+	// check the key length
+	if keyLength <= 0 || keyLength*8 > int64(^uint32(0)) {
+		return nil, errors.New("error in CMSSharedInfo function: key length must be positive and fit in 32 bits")
+	}
+
+	// encode the structure
+	encoded, err := asn1.Marshal(cmsSharedInfo{
+		KeyInfo:     pkix.AlgorithmIdentifier{Algorithm: keyWrap},
+		EntityUInfo: ukm,
+		SuppPubInfo: ConvertUnsignedIntegerToByteSlice(uint64(keyLength*8), 4, false),
+	})
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in CMSSharedInfo function while encoding: %s", err.Error())
+	}
+
+	return encoded, nil
+}
+
+// ConcatKDF is the Concat KDF, the one-step key derivation function with a hash
+// It is based on the NIST SP 800-56A(https://csrc.nist.gov/pubs/sp/800/56/a/r3/final) and NIST SP 800-56C option 1
+// hash: the hash function(can be any crypto.Hash)
+// Z: the shared secret
+// otherInfo: the fixed info, usually OtherInfo.Bytes()
+// dkLen: the byte length of the derived key
+// returns: H(counter || Z || otherInfo) for counter = 1, 2, ... truncated to dkLen bytes, the counter is a 32 bit big-endian integer
+// when compliance mode is enabled the hash must be approved
+func ConcatKDF(hash crypto.Hash, Z []byte, otherInfo []byte, dkLen int64) ([]byte, error) {
+	DK, err := hashKDF(hash, Z, otherInfo, dkLen, true)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in ConcatKDF function: %w", err)
+	}
+
+	return DK, nil
+}
+
+// X963KDF is the ANSI X9.63 key derivation function
+// It is based on the SEC 1(https://www.secg.org/sec1-v2.pdf) section 3.6.1 and NIST SP 800-135 section 4.1
+// hash: the hash function(can be any crypto.Hash)
+// Z: the shared secret
+// sharedInfo: the optional shared information(for example CMSSharedInfo for CMS)
+// dkLen: the byte length of the derived key
+// returns: H(Z || counter || sharedInfo) for counter = 1, 2, ... truncated to dkLen bytes, the counter is a 32 bit big-endian integer
+// when compliance mode is enabled the hash must be approved
+func X963KDF(hash crypto.Hash, Z []byte, sharedInfo []byte, dkLen int64) ([]byte, error) {
+	DK, err := hashKDF(hash, Z, sharedInfo, dkLen, false)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in X963KDF function: %w", err)
+	}
+
+	return DK, nil
+}
+
+// hashKDF runs the hash based KDF shared by ConcatKDF and X963KDF
+// The counterFirst parameter places the counter before Z(Concat KDF) instead of after it(X9.63)
+func hashKDF(hash crypto.Hash, Z []byte, info []byte, dkLen int64, counterFirst bool) ([]byte, error) {
+	// check the hash
+	if err := checkApprovedHash(hash); err != nil {
+		return nil, err
+	}
+
+	// check the parameters
+	hLen := int64(hash.Size())
+	if dkLen < 0 {
+		return nil, errors.New("derived key length must not be negative")
+	}
+
+	if dkLen > (int64(1)<<32-1)*hLen {
+		return nil, errors.New("derived key too long")
+	}
+
+	// DK = K(1) || K(2) || ... truncated to dkLen bytes
+	DK := make([]byte, 0, dkLen+hLen)
+	h := hash.New()
+
+	for counter := uint64(1); int64(len(DK)) < dkLen; counter++ {
+		h.Reset()
+		if counterFirst {
+			h.Write(ConvertUnsignedIntegerToByteSlice(counter, 4, false))
+			h.Write(Z)
+		} else {
+			h.Write(Z)
+			h.Write(ConvertUnsignedIntegerToByteSlice(counter, 4, false))
+		}
+		h.Write(info)
+		DK = h.Sum(DK)
+	}
+
+	return DK[:dkLen], nil
+}
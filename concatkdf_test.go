@@ -0,0 +1,70 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// tests the OtherInfo encodings against RFC7518 appendix C and a hand encoded ECC-CMS-SharedInfo
+func TestOtherInfo(t *testing.T) {
+	// JOSE ECDH-ES with A128GCM, apu "Alice" and apv "Bob"
+	expected, _ := hex.DecodeString("000000074131323847434d00000005416c69636500000003426f6200000080")
+	if encoded := JOSEOtherInfo("A128GCM", []byte("Alice"), []byte("Bob"), 16).Bytes(); !bytes.Equal(encoded, expected) {
+		t.Errorf("error in TestOtherInfo function: JOSE OtherInfo is %x, want %x", encoded, expected)
+	}
+
+	// the private information is appended after the public one
+	encoded := OtherInfo{AlgorithmID: []byte{1}, SuppPubInfo: []byte{2}, SuppPrivInfo: []byte{3}}.Bytes()
+	if expected, _ := hex.DecodeString("000000010100000000000000000203"); !bytes.Equal(encoded, expected) {
+		t.Errorf("error in TestOtherInfo function: OtherInfo is %x, want %x", encoded, expected)
+	}
+
+	// AES-128 key wrap without and with user keying material
+	aes128Wrap := asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 5}
+	tests := []struct {
+		ukm      []byte
+		expected string
+	}{
+		{nil, "3015300b0609608648016503040105a206040400000080"},
+		{[]byte{0xaa, 0xbb}, "301b300b0609608648016503040105a0040402aabba206040400000080"},
+	}
+
+	for _, test := range tests {
+		sharedInfo, err := CMSSharedInfo(aes128Wrap, test.ukm, 16)
+		if err != nil || hex.EncodeToString(sharedInfo) != test.expected {
+			t.Errorf("error in TestOtherInfo function: ECC-CMS-SharedInfo is %x, want %s(%v)", sharedInfo, test.expected, err)
+		}
+	}
+}
+
+// tests the Concat KDF and X9.63 KDF limits and compliance mode
+func TestConcatKDF(t *testing.T) {
+	// the counter is placed before Z by the Concat KDF and after it by X9.63
+	concat, _ := ConcatKDF(crypto.SHA256, []byte("Z"), []byte("info"), 32)
+	x963, _ := X963KDF(crypto.SHA256, []byte("Z"), []byte("info"), 32)
+	if bytes.Equal(concat, x963) {
+		t.Errorf("error in TestConcatKDF function: Concat KDF and X9.63 KDF gave the same key")
+	}
+
+	if _, err := ConcatKDF(crypto.SHA256, []byte("Z"), nil, -1); err == nil {
+		t.Errorf("error in TestConcatKDF function: negative key length was accepted")
+	}
+
+	if _, err := X963KDF(crypto.Hash(0), []byte("Z"), nil, 16); err == nil {
+		t.Errorf("error in TestConcatKDF function: unavailable hash was accepted")
+	}
+
+	// only approved hashes are accepted in compliance mode
+	if err := EnableComplianceMode(DefaultCompliancePolicy()); err != nil {
+		t.Fatalf("error in TestConcatKDF function while enabling compliance mode: %s", err.Error())
+	}
+	defer DisableComplianceMode()
+
+	if _, err := ConcatKDF(crypto.MD5, []byte("Z"), nil, 16); !errors.Is(err, ErrNotCompliant) {
+		t.Errorf("error in TestConcatKDF function: MD5 was accepted in compliance mode(%v)", err)
+	}
+}
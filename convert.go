@@ -0,0 +1,74 @@
+package pbkdf
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+)
+
+// ErrLossyConversion is returned when a password hash can not be written in another format without losing or inventing information
+var ErrLossyConversion = errors.New("conversion would lose information")
+
+// ConvertOptions holds the options of ConvertPasswordHash
+type ConvertOptions struct {
+	// Hash is the hash function of the FormatColon strings, they do not record it
+	// without it strings can not be converted from or to FormatColon
+	Hash crypto.Hash
+	// Legacy tells the FormatColon strings were encoded with PBKDF2Legacy, before PBKDF2 used HMAC
+	// they can then be converted to PHC strings of pbkdf2-legacy-<hash>, which Verify recognizes and NeedsRehash flags
+	Legacy bool
+}
+
+// ConvertPasswordHash rewrites a PBKDF2 password hash in another format, the password is not needed
+// The encodedPassword parameter is the string to convert
+// The from and to parameters are names of registered formats: FormatColon, FormatPHC, FormatDjango, FormatPasslib or custom ones
+// The options parameter holds the hash function and the construction of FormatColon strings
+// every parameter is preserved, if the target format can not represent one of them(a hash function it doesn't support,
+// a key length other than the hash size, a binary salt for Django, ...) the error wraps ErrLossyConversion
+// strings that are not in the canonical form of their format are rejected so the result always verifies the same passwords
+func ConvertPasswordHash(encodedPassword string, from, to string, options ConvertOptions) (string, error) {
+	// check the parameters
+	source, err := convertFormat(from, options)
+	if err != nil {
+		return "", fmt.Errorf("error in ConvertPasswordHash function: %w", err)
+	}
+
+	target, err := convertFormat(to, options)
+	if err != nil {
+		return "", fmt.Errorf("error in ConvertPasswordHash function: %w", err)
+	}
+
+	// parse the string
+	kdf, salt, key, err := source.Parse(encodedPassword)
+	if err != nil {
+		return "", fmt.Errorf("error in ConvertPasswordHash function while parsing: %w", err)
+	}
+
+	switch kdf.(type) {
+	case PBKDF2Parameters, PBKDF2LegacyParameters:
+	default:
+		return "", fmt.Errorf("error in ConvertPasswordHash function: %s is not PBKDF2", kdf.Name())
+	}
+
+	// a string in another form than the one written by the format could hold information the parser dropped
+	if canonical, err := source.Encode(kdf, salt, key); err != nil || canonical != encodedPassword {
+		return "", fmt.Errorf("error in ConvertPasswordHash function: %s string is not in canonical form", from)
+	}
+
+	// encode the string
+	converted, err := target.Encode(kdf, salt, key)
+	if err != nil {
+		return "", fmt.Errorf("error in ConvertPasswordHash function while encoding: %w", err)
+	}
+
+	return converted, nil
+}
+
+// convertFormat returns the registered format name, FormatColon uses the hash function and the construction of the options
+func convertFormat(name string, options ConvertOptions) (PasswordFormat, error) {
+	if name == FormatColon {
+		return colonPasswordFormat{hash: options.Hash, legacy: options.Legacy}, nil
+	}
+
+	return LookupPasswordFormat(name)
+}
@@ -0,0 +1,112 @@
+package pbkdf
+
+import (
+	"crypto"
+	"errors"
+	"testing"
+)
+
+// tests conversions of strings created by Django and passlib(computed with Python's hashlib.pbkdf2_hmac)
+func TestConvertPasswordHash(t *testing.T) {
+	tests := []struct {
+		format  string
+		encoded string
+		phc     string
+	}{
+		{FormatDjango, "pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=", "$pbkdf2-sha256$i=1000$c2Vhc2FsdA$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c"},
+		{FormatDjango, "pbkdf2_sha1$1000$seasalt$C8KvRfPW529R7JpDHEDOP35Xr0g=", "$pbkdf2-sha1$i=1000$c2Vhc2FsdA$C8KvRfPW529R7JpDHEDOP35Xr0g"},
+		{FormatPasslib, "$pbkdf2-sha512$1000$.vv8/f7/ACsvfg$4x1ExXMzMzt4O7sQ0WKTYIg/YoSsch5VRzx2fZ2JvYnQz0NaXb7mRa/T7S6QIw/hgZyYseehXCWqCUFXgRyDKg",
+			"$pbkdf2-sha512$i=1000$+vv8/f7/ACsvfg$4x1ExXMzMzt4O7sQ0WKTYIg/YoSsch5VRzx2fZ2JvYnQz0NaXb7mRa/T7S6QIw/hgZyYseehXCWqCUFXgRyDKg"},
+		{FormatPasslib, "$pbkdf2$1000$.vv8/f7/ACsvfg$2n/VUdBFMlL/eAvug7d15brthi8", "$pbkdf2-sha1$i=1000$+vv8/f7/ACsvfg$2n/VUdBFMlL/eAvug7d15brthi8"},
+	}
+
+	for _, test := range tests {
+		phc, err := ConvertPasswordHash(test.encoded, test.format, FormatPHC, ConvertOptions{})
+		if err != nil || phc != test.phc {
+			t.Fatalf("error in TestConvertPasswordHash function: %s gave %q, %v", test.encoded, phc, err)
+		}
+
+		if ok, err := VerifyPasswordKDF("password", phc); err != nil || !ok {
+			t.Errorf("error in TestConvertPasswordHash function: converted %s does not verify the password: %v", test.encoded, err)
+		}
+
+		// and back
+		if back, err := ConvertPasswordHash(phc, FormatPHC, test.format, ConvertOptions{}); err != nil || back != test.encoded {
+			t.Errorf("error in TestConvertPasswordHash function: %s converted back to %q, %v", phc, back, err)
+		}
+	}
+
+	// the colon format needs the hash function in both directions
+	colon, _ := EncodePasswordPBKDF2(crypto.SHA256, "password", 16, 1000, 32)
+	if _, err := ConvertPasswordHash(colon, FormatColon, FormatPHC, ConvertOptions{}); !errors.Is(err, ErrLossyConversion) {
+		t.Errorf("error in TestConvertPasswordHash function: colon string without a hash gave %v", err)
+	}
+
+	passlib, err := ConvertPasswordHash(colon, FormatColon, FormatPasslib, ConvertOptions{Hash: crypto.SHA256})
+	if err != nil {
+		t.Fatalf("error in TestConvertPasswordHash function while converting a colon string: %s", err.Error())
+	}
+
+	if back, err := ConvertPasswordHash(passlib, FormatPasslib, FormatColon, ConvertOptions{Hash: crypto.SHA256}); err != nil || back != colon {
+		t.Errorf("error in TestConvertPasswordHash function: colon string converted back to %q, %v", back, err)
+	}
+
+	if _, err := ConvertPasswordHash(passlib, FormatPasslib, FormatColon, ConvertOptions{Hash: crypto.SHA512}); !errors.Is(err, ErrLossyConversion) {
+		t.Errorf("error in TestConvertPasswordHash function: colon string with another hash gave %v", err)
+	}
+
+	// colon strings encoded before PBKDF2 used HMAC become pbkdf2-legacy PHC strings
+	legacy := "c2FsdA==:2:nar1Fck3qez3lS3S01tE0oMFuKg="
+	phc, err := ConvertPasswordHash(legacy, FormatColon, FormatPHC, ConvertOptions{Hash: crypto.SHA1, Legacy: true})
+	if err != nil || phc != "$pbkdf2-legacy-sha1$i=2$c2FsdA$nar1Fck3qez3lS3S01tE0oMFuKg" {
+		t.Fatalf("error in TestConvertPasswordHash function: legacy colon string converted to %q, %v", phc, err)
+	}
+
+	if ok, err := Verify("password", phc); err != nil || !ok {
+		t.Errorf("error in TestConvertPasswordHash function: converted legacy string does not verify the password: %v", err)
+	}
+
+	if back, err := ConvertPasswordHash(phc, FormatPHC, FormatColon, ConvertOptions{Hash: crypto.SHA1, Legacy: true}); err != nil || back != legacy {
+		t.Errorf("error in TestConvertPasswordHash function: legacy string converted back to %q, %v", back, err)
+	}
+
+	for _, to := range []string{FormatColon, FormatDjango, FormatPasslib} {
+		if _, err := ConvertPasswordHash(phc, FormatPHC, to, ConvertOptions{Hash: crypto.SHA1}); !errors.Is(err, ErrLossyConversion) {
+			t.Errorf("error in TestConvertPasswordHash function: legacy string to %s gave %v", to, err)
+		}
+	}
+
+	// conversions losing information are refused
+	shortKey, _ := EncodePasswordKDF(PBKDF2Parameters{Hash: crypto.SHA256, Iterations: 1000}, "password", 16, 16)
+	sha384, _ := EncodePasswordKDF(PBKDF2Parameters{Hash: crypto.SHA384, Iterations: 1000}, "password", 16, 48)
+	binarySalt := tests[2].phc
+
+	for _, lossy := range []struct{ encoded, to string }{
+		{shortKey, FormatDjango},
+		{shortKey, FormatPasslib},
+		{sha384, FormatDjango},
+		{sha384, FormatPasslib},
+		{binarySalt, FormatDjango},
+	} {
+		if _, err := ConvertPasswordHash(lossy.encoded, FormatPHC, lossy.to, ConvertOptions{}); !errors.Is(err, ErrLossyConversion) {
+			t.Errorf("error in TestConvertPasswordHash function: %s to %s gave %v", lossy.encoded, lossy.to, err)
+		}
+	}
+
+	// invalid and non-canonical strings
+	scrypt, _ := EncodePasswordKDF(ScryptParameters{N: 16, R: 8, P: 1}, "password", 16, 32)
+	for _, invalid := range []struct{ encoded, from string }{
+		{scrypt, FormatPHC},
+		{"pbkdf2_sha256$01000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=", FormatDjango},
+		{"pbkdf2_md5$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=", FormatDjango},
+		{"pbkdf2_sha256$1000$seasalt", FormatDjango},
+		{"$pbkdf2-sha512$1000$.vv8/f7/ACsvfg$4x1E", FormatPasslib},
+		{"$pbkdf2-sha256$1000$+vv8/f7/ACsvfg$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y.i7c", FormatPasslib},
+		{"c2FsdA==:1000:a2V5", FormatDjango},
+		{tests[0].phc, "bcrypt"},
+	} {
+		if _, err := ConvertPasswordHash(invalid.encoded, invalid.from, FormatPHC, ConvertOptions{Hash: crypto.SHA256}); err == nil {
+			t.Errorf("error in TestConvertPasswordHash function: %s was converted from %s", invalid.encoded, invalid.from)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ErrWrongPassword is wrapped by the errors of Open, NewStreamReader and KeySlot.Unlock when the password does not match
+var ErrWrongPassword = errors.New("wrong password")
+
+// ErrInvalidEnvelope is wrapped by the errors of Open and StreamReader when the envelope or stream is malformed or was modified
+var ErrInvalidEnvelope = errors.New("invalid envelope")
+
+// EnvelopeCipher selects the AEAD that encrypts an envelope
+type EnvelopeCipher byte
+
+// envelope ciphers, the values are the identifiers stored in the envelope
+const (
+	// EnvelopeAES256GCM is AES-256 in GCM mode with a 12 byte nonce
+	EnvelopeAES256GCM EnvelopeCipher = 1
+	// EnvelopeChaCha20Poly1305 is ChaCha20-Poly1305 with a 12 byte nonce, it is rejected in compliance mode
+	EnvelopeChaCha20Poly1305 EnvelopeCipher = 2
+)
+
+// String returns the name of the cipher(AES-256-GCM or ChaCha20-Poly1305)
+func (c EnvelopeCipher) String() string {
+	switch c {
+	case EnvelopeAES256GCM:
+		return "AES-256-GCM"
+	case EnvelopeChaCha20Poly1305:
+		return "ChaCha20-Poly1305"
+	}
+
+	return fmt.Sprintf("EnvelopeCipher(%d)", byte(c))
+}
+
+// newAEAD creates the AEAD of the cipher with a 32 byte key
+func (c EnvelopeCipher) newAEAD(key []byte) (cipher.AEAD, error) {
+	switch c {
+	case EnvelopeAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return cipher.NewGCM(block)
+	case EnvelopeChaCha20Poly1305:
+		// ChaCha20-Poly1305 is not a FIPS approved cipher
+		if currentCompliancePolicy() != nil {
+			return nil, fmt.Errorf("%w: %s is not approved", ErrNotCompliant, c)
+		}
+
+		return NewChaCha20Poly1305(key)
+	}
+
+	return nil, fmt.Errorf("unknown cipher %d", byte(c))
+}
+
+// envelope format constants
+const (
+	// EnvelopeVersion is the version of the envelope format written by Seal
+	EnvelopeVersion = 1
+	// EnvelopeArmorType is the PEM block type of an armored envelope
+	EnvelopeArmorType = "PBKDF ENVELOPE"
+	// identifier of PBKDF2 in the envelope
+	envelopeKDFPBKDF2 = 1
+	// length of the password check value
+	envelopeCheckLength = 8
+	// length of the key of both ciphers
+	envelopeKeyLength = 32
+)
+
+// envelopeMagic starts every binary envelope
+var envelopeMagic = []byte("PBKE")
+
+// SealOptions holds the optional parameters of SealWithOptions, zero values select the defaults
+type SealOptions struct {
+	// Hash is the PBKDF2 hash function, zero means SHA-256
+	Hash crypto.Hash
+	// Iterations is the PBKDF2 iteration count, zero means DefaultIterationCount
+	Iterations int64
+	// SaltLength is the salt length in bytes, zero means DefaultSaltLength
+	SaltLength int64
+	// Cipher is the AEAD, zero means EnvelopeAES256GCM
+	Cipher EnvelopeCipher
+}
+
+// DefaultEnvelopeMaxIterations is the highest PBKDF2 iteration count Open and NewStreamReader accept by default
+// the count is read from the header before anything is authenticated, so a modified envelope could otherwise keep the CPU busy for hours
+const DefaultEnvelopeMaxIterations int64 = 10000000
+
+// OpenOptions holds the optional limits of OpenWithOptions and NewStreamReaderWithOptions, zero values select the defaults
+type OpenOptions struct {
+	// MaxIterations is the highest accepted PBKDF2 iteration count, zero means DefaultEnvelopeMaxIterations
+	MaxIterations int64
+}
+
+// EnvelopeHeader holds the parameters recorded in an envelope, they are authenticated with the ciphertext
+type EnvelopeHeader struct {
+	// Version is the envelope format version
+	Version int
+	// Hash is the PBKDF2 hash function
+	Hash crypto.Hash
+	// Iterations is the PBKDF2 iteration count
+	Iterations int64
+	// Salt is the PBKDF2 salt
+	Salt []byte
+	// Cipher is the AEAD
+	Cipher EnvelopeCipher
+	// Nonce is the AEAD nonce
+	Nonce []byte
+	// Check is the password check value that tells a wrong password from a modified envelope
+	Check []byte
+}
+
+// Seal encrypts plaintext with a key derived from the password
+// It uses PBKDF2 with SHA-256, DefaultIterationCount iterations, a DefaultSaltLength byte salt and AES-256-GCM
+// password: the password
+// plaintext: the data to encrypt
+// aad: the optional additional authenticated data, it is not stored and must be given to Open again
+// returns: the binary envelope, ArmorEnvelope converts it to text
+func Seal(password string, plaintext, aad []byte) ([]byte, error) {
+	return SealWithOptions(password, plaintext, aad, SealOptions{})
+}
+
+// SealWithOptions is Seal with a choice of hash, iteration count, salt length and cipher
+// the binary format is "PBKE" || version || KDF || hash || iterations(32 bit) || salt length || salt ||
+// cipher || nonce length || nonce || check || ciphertext, the integers are big-endian and the lengths are one byte
+// PBKDF2 stretches the password once and HKDF-Expand derives the cipher key and the check value from it(see DeriveSubkeys)
+// the header is authenticated as additional data together with aad
+func SealWithOptions(password string, plaintext, aad []byte, options SealOptions) ([]byte, error) {
+	header, aead, err := newEnvelopeHeader(password, options, "envelope", 0)
+	if err != nil {
+		return nil, fmt.Errorf("error in SealWithOptions function: %w", err)
+	}
+
+	// encrypt, the header is authenticated with aad
+	envelope := header.bytes(envelopeMagic)
+	return aead.Seal(envelope, header.Nonce, plaintext, envelopeAAD(envelope, aad)), nil
+}
+
+// Open decrypts an envelope created by Seal or SealWithOptions
+// password: the password
+// envelope: the binary envelope or its armored text form
+// aad: the additional authenticated data given to Seal
+// the error wraps ErrWrongPassword when the password is wrong and ErrInvalidEnvelope when the envelope is malformed, modified or aad differs
+// envelopes with more than DefaultEnvelopeMaxIterations iterations are rejected, OpenWithOptions sets another limit
+func Open(password string, envelope, aad []byte) ([]byte, error) {
+	return OpenWithOptions(password, envelope, aad, OpenOptions{})
+}
+
+// OpenWithOptions is Open with a choice of the highest accepted iteration count
+// the iteration count is checked before the key is derived, a count above the limit wraps ErrInvalidEnvelope
+func OpenWithOptions(password string, envelope, aad []byte, options OpenOptions) ([]byte, error) {
+	// remove the armor
+	if bytes.HasPrefix(bytes.TrimSpace(envelope), []byte("-----BEGIN ")) {
+		binary, err := DearmorEnvelope(string(envelope))
+		if err != nil {
+			return nil, fmt.Errorf("error in OpenWithOptions function: %w", err)
+		}
+
+		envelope = binary
+	}
+
+	// parse the header
+	header, length, err := parseEnvelopeHeader(envelopeMagic, envelope)
+	if err != nil {
+		return nil, fmt.Errorf("error in OpenWithOptions function: %w: %s", ErrInvalidEnvelope, err.Error())
+	}
+
+	// the iteration count is not authenticated yet
+	if err := options.checkIterations(header.Iterations); err != nil {
+		return nil, fmt.Errorf("error in OpenWithOptions function: %w: %s", ErrInvalidEnvelope, err.Error())
+	}
+
+	// derive the key and the check value
+	aead, check, err := header.deriveAEAD(password, "envelope")
+	if err != nil {
+		return nil, fmt.Errorf("error in OpenWithOptions function: %w", err)
+	}
+
+	if len(header.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("error in OpenWithOptions function: %w: nonce must be %d bytes", ErrInvalidEnvelope, aead.NonceSize())
+	}
+
+	// the check value tells a wrong password from a modified envelope
+	if subtle.ConstantTimeCompare(check, header.Check) != 1 {
+		return nil, fmt.Errorf("error in OpenWithOptions function: %w", ErrWrongPassword)
+	}
+
+	// decrypt
+	plaintext, err := aead.Open(nil, header.Nonce, envelope[length:], envelopeAAD(envelope[:length], aad))
+	if err != nil {
+		return nil, fmt.Errorf("error in OpenWithOptions function: %w: authentication failed", ErrInvalidEnvelope)
+	}
+
+	return plaintext, nil
+}
+
+// ParseEnvelopeHeader returns the parameters recorded in an envelope without decrypting it
+// The envelope parameter is the binary envelope or its armored text form
+func ParseEnvelopeHeader(envelope []byte) (*EnvelopeHeader, error) {
+	// remove the armor
+	if bytes.HasPrefix(bytes.TrimSpace(envelope), []byte("-----BEGIN ")) {
+		binary, err := DearmorEnvelope(string(envelope))
+		if err != nil {
+			return nil, fmt.Errorf("error in ParseEnvelopeHeader function: %w", err)
+		}
+
+		envelope = binary
+	}
+
+	header, _, err := parseEnvelopeHeader(envelopeMagic, envelope)
+	if err != nil {
+		return nil, fmt.Errorf("error in ParseEnvelopeHeader function: %w: %s", ErrInvalidEnvelope, err.Error())
+	}
+
+	return header, nil
+}
+
+// ArmorEnvelope returns the armored text form of a binary envelope, a PEM block of type EnvelopeArmorType
+// the parameters are also written as PEM headers so the text is readable, only the binary header is authenticated
+func ArmorEnvelope(envelope []byte) (string, error) {
+	header, _, err := parseEnvelopeHeader(envelopeMagic, envelope)
+	if err != nil {
+		return "", fmt.Errorf("error in ArmorEnvelope function: %w: %s", ErrInvalidEnvelope, err.Error())
+	}
+
+	block := &pem.Block{
+		Type: EnvelopeArmorType,
+		Headers: map[string]string{
+			"Version":    fmt.Sprint(header.Version),
+			"KDF":        "PBKDF2-" + header.Hash.String(),
+			"Iterations": fmt.Sprint(header.Iterations),
+			"Cipher":     header.Cipher.String(),
+		},
+		Bytes: envelope,
+	}
+
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+// DearmorEnvelope returns the binary envelope of its armored text form
+func DearmorEnvelope(armored string) ([]byte, error) {
+	block, rest := pem.Decode([]byte(armored))
+	if block == nil || block.Type != EnvelopeArmorType || len(bytes.TrimSpace(rest)) != 0 {
+		return nil, fmt.Errorf("error in DearmorEnvelope function: %w: text is not one %s block", ErrInvalidEnvelope, EnvelopeArmorType)
+	}
+
+	return block.Bytes, nil
+}
+
+// checkIterations checks an iteration count read from a header against the limit of the options
+func (o OpenOptions) checkIterations(iterations int64) error {
+	maxIterations := o.MaxIterations
+	if maxIterations == 0 {
+		maxIterations = DefaultEnvelopeMaxIterations
+	}
+
+	if iterations > maxIterations {
+		return fmt.Errorf("iteration count %d is above the limit of %d", iterations, maxIterations)
+	}
+
+	return nil
+}
+
+// newEnvelopeHeader applies the defaults of the options, generates the salt and the nonce and derives the AEAD
+// purpose separates the keys of envelopes and streams, the nonce is nonceLength bytes shorter than the AEAD nonce
+func newEnvelopeHeader(password string, options SealOptions, purpose string, nonceLength int) (*EnvelopeHeader, cipher.AEAD, error) {
+	// apply the defaults
+	if options.Hash == 0 {
+		options.Hash = crypto.SHA256
+	}
+
+	if options.Iterations == 0 {
+		options.Iterations = DefaultIterationCount
+	}
+
+	if options.SaltLength == 0 {
+		options.SaltLength = DefaultSaltLength
+	}
+
+	if options.Cipher == 0 {
+		options.Cipher = EnvelopeAES256GCM
+	}
+
+	// check the parameters fit in the format
+	switch {
+	case options.Iterations < 0 || options.Iterations > int64(^uint32(0)):
+		return nil, nil, errors.New("iteration count must fit in 32 bits")
+	case options.SaltLength < 0 || options.SaltLength > 255:
+		return nil, nil, errors.New("salt length must be at most 255 bytes")
+	case options.Hash > 255:
+		return nil, nil, fmt.Errorf("hash function %d can not be stored", options.Hash)
+	}
+
+	// generate the salt
+	salt, err := GenerateRandomSequence(int(options.SaltLength))
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating salt: %s", err.Error())
+	}
+
+	header := &EnvelopeHeader{Version: EnvelopeVersion, Hash: options.Hash, Iterations: options.Iterations, Salt: salt, Cipher: options.Cipher}
+
+	// derive the key and the check value
+	aead, check, err := header.deriveAEAD(password, purpose)
+	if err != nil {
+		return nil, nil, err
+	}
+	header.Check = check
+
+	// generate the nonce
+	if header.Nonce, err = GenerateRandomSequence(aead.NonceSize() - nonceLength); err != nil {
+		return nil, nil, fmt.Errorf("generating nonce: %s", err.Error())
+	}
+
+	return header, aead, nil
+}
+
+// deriveAEAD derives the cipher key and the check value from the password and creates the AEAD
+// purpose is the prefix of the subkey labels
+func (h *EnvelopeHeader) deriveAEAD(password string, purpose string) (cipher.AEAD, []byte, error) {
+	subkeys, err := DeriveSubkeys(h.Hash, []byte(password), h.Salt, h.Iterations,
+		SubkeySpec{purpose + " key", envelopeKeyLength}, SubkeySpec{purpose + " check", envelopeCheckLength})
+
+	// check if an error occurred
+	if err != nil {
+		return nil, nil, fmt.Errorf("deriving key: %w", err)
+	}
+
+	aead, err := h.Cipher.newAEAD(subkeys[purpose+" key"])
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	return aead, subkeys[purpose+" check"], nil
+}
+
+// bytes returns the binary header starting with magic
+func (h *EnvelopeHeader) bytes(magic []byte) []byte {
+	header := make([]byte, 0, 20+len(h.Salt)+len(h.Nonce)+len(h.Check))
+	header = append(header, magic...)
+	header = append(header, byte(h.Version), envelopeKDFPBKDF2, byte(h.Hash))
+	header = append(header, ConvertUnsignedIntegerToByteSlice(uint64(h.Iterations), 4, false)...)
+	header = append(header, byte(len(h.Salt)))
+	header = append(header, h.Salt...)
+	header = append(header, byte(h.Cipher), byte(len(h.Nonce)))
+	header = append(header, h.Nonce...)
+	header = append(header, h.Check...)
+
+	return header
+}
+
+// parseEnvelopeHeader parses the binary header starting with magic and returns it with its length
+func parseEnvelopeHeader(magic []byte, envelope []byte) (*EnvelopeHeader, int, error) {
+	// magic, version, KDF, hash, iterations and salt length
+	if len(envelope) < 12 || !bytes.Equal(envelope[:4], magic) {
+		return nil, 0, errors.New("not an envelope")
+	}
+
+	if envelope[4] != EnvelopeVersion {
+		return nil, 0, fmt.Errorf("unsupported version %d", envelope[4])
+	}
+
+	if envelope[5] != envelopeKDFPBKDF2 {
+		return nil, 0, fmt.Errorf("unsupported KDF %d", envelope[5])
+	}
+
+	header := &EnvelopeHeader{
+		Version:    int(envelope[4]),
+		Hash:       crypto.Hash(envelope[6]),
+		Iterations: int64(ConvertSliceToUnsignedInteger(envelope[7:11], false)),
+	}
+
+	if header.Iterations == 0 {
+		return nil, 0, errors.New("iteration count must not be zero")
+	}
+
+	// salt, cipher and nonce length
+	offset := 12 + int(envelope[11])
+	if len(envelope) < offset+2 {
+		return nil, 0, errors.New("envelope is truncated")
+	}
+	header.Salt = envelope[12:offset]
+	header.Cipher = EnvelopeCipher(envelope[offset])
+
+	// nonce and check value
+	nonceEnd := offset + 2 + int(envelope[offset+1])
+	if len(envelope) < nonceEnd+envelopeCheckLength {
+		return nil, 0, errors.New("envelope is truncated")
+	}
+	header.Nonce = envelope[offset+2 : nonceEnd]
+	header.Check = envelope[nonceEnd : nonceEnd+envelopeCheckLength]
+
+	return header, nonceEnd + envelopeCheckLength, nil
+}
+
+// envelopeAAD returns the additional data of the AEAD, the binary header followed by the application's aad
+func envelopeAAD(header, aad []byte) []byte {
+	return append(append(make([]byte, 0, len(header)+len(aad)), header...), aad...)
+}
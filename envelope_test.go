@@ -0,0 +1,128 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// tests that Seal and Open round trip with both ciphers and in both forms
+func TestSealOpen(t *testing.T) {
+	plaintext, aad := []byte("attack at dawn"), []byte("message 1")
+
+	for _, cipher := range []EnvelopeCipher{EnvelopeAES256GCM, EnvelopeChaCha20Poly1305} {
+		envelope, err := SealWithOptions("password", plaintext, aad, SealOptions{Iterations: 1000, Cipher: cipher})
+		if err != nil {
+			t.Fatalf("error in TestSealOpen function while sealing with %s: %s", cipher, err.Error())
+		}
+
+		armored, err := ArmorEnvelope(envelope)
+		if err != nil {
+			t.Fatalf("error in TestSealOpen function while armoring: %s", err.Error())
+		}
+
+		if !strings.Contains(armored, "Cipher: "+cipher.String()) {
+			t.Errorf("error in TestSealOpen function: armor does not show the cipher:\n%s", armored)
+		}
+
+		for _, form := range [][]byte{envelope, []byte(armored)} {
+			opened, err := Open("password", form, aad)
+			if err != nil {
+				t.Fatalf("error in TestSealOpen function while opening with %s: %s", cipher, err.Error())
+			}
+
+			if !bytes.Equal(opened, plaintext) {
+				t.Errorf("error in TestSealOpen function: got %q, want %q", opened, plaintext)
+			}
+		}
+
+		// the header records the parameters
+		header, err := ParseEnvelopeHeader(envelope)
+		if err != nil {
+			t.Fatalf("error in TestSealOpen function while parsing the header: %s", err.Error())
+		}
+
+		if header.Version != EnvelopeVersion || header.Hash != crypto.SHA256 || header.Iterations != 1000 ||
+			int64(len(header.Salt)) != DefaultSaltLength || header.Cipher != cipher || len(header.Nonce) != 12 {
+			t.Errorf("error in TestSealOpen function: unexpected header %+v", header)
+		}
+	}
+
+	// two envelopes of the same plaintext differ
+	first, _ := SealWithOptions("password", plaintext, nil, SealOptions{Iterations: 1000})
+	second, _ := SealWithOptions("password", plaintext, nil, SealOptions{Iterations: 1000})
+	if bytes.Equal(first, second) {
+		t.Errorf("error in TestSealOpen function: envelopes are deterministic")
+	}
+}
+
+// tests that a wrong password and a modified envelope give different errors
+func TestOpenErrors(t *testing.T) {
+	envelope, err := SealWithOptions("password", []byte("attack at dawn"), []byte("aad"), SealOptions{Hash: crypto.SHA512, Iterations: 1000})
+	if err != nil {
+		t.Fatalf("error in TestOpenErrors function while sealing: %s", err.Error())
+	}
+
+	if _, err := Open("wrong password", envelope, []byte("aad")); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("error in TestOpenErrors function: wrong password gave %v", err)
+	}
+
+	if _, err := Open("password", envelope, []byte("other aad")); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("error in TestOpenErrors function: wrong aad gave %v", err)
+	}
+
+	// flip one bit of the ciphertext and of the authenticated header
+	for _, i := range []int{len(envelope) - 1, 8} {
+		modified := append([]byte(nil), envelope...)
+		modified[i] ^= 1
+
+		if _, err := Open("password", modified, []byte("aad")); err == nil || errors.Is(err, ErrWrongPassword) && i == len(envelope)-1 {
+			t.Errorf("error in TestOpenErrors function: modified byte %d gave %v", i, err)
+		}
+	}
+
+	// malformed envelopes
+	for _, malformed := range [][]byte{nil, []byte("PBKE"), envelope[:20], []byte("-----BEGIN PBKDF ENVELOPE-----\n")} {
+		if _, err := Open("password", malformed, nil); !errors.Is(err, ErrInvalidEnvelope) {
+			t.Errorf("error in TestOpenErrors function: malformed envelope %q gave %v", malformed, err)
+		}
+	}
+
+	// iteration counts of zero or above the limit are rejected before deriving the key
+	for _, iterations := range []uint64{0, 1<<32 - 1} {
+		modified := append([]byte(nil), envelope...)
+		copy(modified[7:11], ConvertUnsignedIntegerToByteSlice(iterations, 4, false))
+
+		if _, err := Open("password", modified, []byte("aad")); !errors.Is(err, ErrInvalidEnvelope) {
+			t.Errorf("error in TestOpenErrors function: %d iterations gave %v", iterations, err)
+		}
+	}
+
+	if _, err := OpenWithOptions("password", envelope, []byte("aad"), OpenOptions{MaxIterations: 999}); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("error in TestOpenErrors function: iteration count above MaxIterations gave %v", err)
+	}
+
+	if _, err := OpenWithOptions("password", envelope, []byte("aad"), OpenOptions{MaxIterations: 1000}); err != nil {
+		t.Errorf("error in TestOpenErrors function: iteration count at MaxIterations gave %v", err)
+	}
+
+	// ChaCha20-Poly1305 and weak parameters are rejected in compliance mode
+	if err := EnableComplianceMode(DefaultCompliancePolicy()); err != nil {
+		t.Fatalf("error in TestOpenErrors function while enabling compliance mode: %s", err.Error())
+	}
+	defer DisableComplianceMode()
+
+	if _, err := SealWithOptions("password", nil, nil, SealOptions{Iterations: 1000, Cipher: EnvelopeChaCha20Poly1305}); !errors.Is(err, ErrNotCompliant) {
+		t.Errorf("error in TestOpenErrors function: ChaCha20-Poly1305 gave %v in compliance mode", err)
+	}
+
+	if _, err := SealWithOptions("password", nil, nil, SealOptions{Iterations: 999}); !errors.Is(err, ErrNotCompliant) {
+		t.Errorf("error in TestOpenErrors function: 999 iterations gave %v in compliance mode", err)
+	}
+
+	if _, err := Open("password", envelope, []byte("aad")); err != nil {
+		t.Errorf("error in TestOpenErrors function while opening in compliance mode: %s", err.Error())
+	}
+}
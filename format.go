@@ -0,0 +1,475 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrUnknownFormat is returned when no registered format recognizes a string or a format name is not registered
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+// names of the formats registered by this package
+const (
+	// FormatColon is the salt:iterationCount:hashedPassword format of GeneratePasswordString, it does not record the hash function
+	FormatColon = "colon"
+	// FormatPHC is the PHC string format of GenerateKDFPasswordString: $pbkdf2-sha256$i=iterationCount$salt$hash, $argon2id$v=19$..., ...
+	FormatPHC = "phc"
+	// FormatDjango is the format of the Django PBKDF2 hashers: pbkdf2_sha256$iterationCount$salt$hash, the salt is text
+	FormatDjango = "django"
+	// FormatPasslib is the format of the passlib pbkdf2 handlers: $pbkdf2-sha256$iterationCount$salt$hash, in the adapted base64 of passlib
+	FormatPasslib = "passlib"
+)
+
+// PasswordFormat is a string encoding of password hashes
+// the formats of this package implement it and other packages can add theirs with RegisterPasswordFormat
+type PasswordFormat interface {
+	// Name returns the registry name of the format
+	Name() string
+	// Recognize reports whether a string is in this format, it should only look at its shape(prefix, separators, ...)
+	Recognize(encodedPassword string) bool
+	// Parse returns the KDF with its parameters, the salt and the derived key of a string
+	Parse(encodedPassword string) (KDF, []byte, []byte, error)
+	// Encode returns the string of a KDF, a salt and a derived key
+	// the error must wrap ErrLossyConversion if the format can not represent them
+	Encode(kdf KDF, salt, key []byte) (string, error)
+}
+
+// registry of password formats, in registration order
+var formatRegistry = struct {
+	sync.RWMutex
+	formats []PasswordFormat
+}{}
+
+// register the formats of this package
+// colon strings are verified with PBKDF2-SHA256, the defaults of NewHasher
+func init() {
+	for _, format := range []PasswordFormat{phcPasswordFormat{}, passlibPasswordFormat{}, djangoPasswordFormat{}, colonPasswordFormat{hash: crypto.SHA256}} {
+		RegisterPasswordFormat(format)
+	}
+}
+
+// RegisterPasswordFormat adds a format to the registry so Verify recognizes its strings
+// third-party packages usually call it from an init function
+// The format parameter's name must be a valid PHC identifier(lowercase letters, digits and -, at most 32 characters)
+// formats are tried in registration order, registering a name twice is an error
+func RegisterPasswordFormat(format PasswordFormat) error {
+	// check the parameters
+	if format == nil {
+		return errors.New("error in RegisterPasswordFormat function: format must not be nil")
+	}
+
+	if !isPHCName(format.Name()) {
+		return fmt.Errorf("error in RegisterPasswordFormat function: invalid name %q", format.Name())
+	}
+
+	formatRegistry.Lock()
+	defer formatRegistry.Unlock()
+
+	// check the name is free
+	for _, registered := range formatRegistry.formats {
+		if registered.Name() == format.Name() {
+			return fmt.Errorf("error in RegisterPasswordFormat function: %q is already registered", format.Name())
+		}
+	}
+
+	formatRegistry.formats = append(formatRegistry.formats, format)
+	return nil
+}
+
+// LookupPasswordFormat returns the registered format name
+// the error wraps ErrUnknownFormat if the name is not registered
+func LookupPasswordFormat(name string) (PasswordFormat, error) {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+
+	for _, format := range formatRegistry.formats {
+		if format.Name() == name {
+			return format, nil
+		}
+	}
+
+	return nil, fmt.Errorf("error in LookupPasswordFormat function: %w %q", ErrUnknownFormat, name)
+}
+
+// RegisteredPasswordFormats returns the names of the registered formats in registration order
+func RegisteredPasswordFormats() []string {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+
+	names := make([]string, len(formatRegistry.formats))
+	for i, format := range formatRegistry.formats {
+		names[i] = format.Name()
+	}
+
+	return names
+}
+
+// DetectPasswordFormat returns the first registered format recognizing a string
+// the error wraps ErrUnknownFormat if no format recognizes it
+func DetectPasswordFormat(encodedPassword string) (PasswordFormat, error) {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+
+	for _, format := range formatRegistry.formats {
+		if format.Recognize(encodedPassword) {
+			return format, nil
+		}
+	}
+
+	return nil, fmt.Errorf("error in DetectPasswordFormat function: %w", ErrUnknownFormat)
+}
+
+// Verify checks if a password matches an encoded password in any registered format
+// The password parameter is the password to be checked
+// The encodedPassword parameter is a string of a registered format, its format, KDF, hash and parameters are detected
+// the error wraps ErrUnknownFormat if no format recognizes the string
+// colon strings do not record their hash function and are verified with PBKDF2-SHA256, use a Hasher or VerifyPassword for other ones
+// colon strings that do not match are verified again with PBKDF2Legacy, they were encoded before PBKDF2 used HMAC
+// use a Hasher with WithLegacyPBKDF2Fallback and VerifyAndRehash to replace them
+func Verify(password, encodedPassword string) (bool, error) {
+	// find the format
+	format, err := DetectPasswordFormat(encodedPassword)
+	if err != nil {
+		return false, fmt.Errorf("error in Verify function: %w", err)
+	}
+
+	// get the password parameters
+	kdf, salt, key, err := format.Parse(encodedPassword)
+
+	// check if an error occurred
+	if err != nil {
+		return false, fmt.Errorf("error in Verify function while parsing %s string: %w", format.Name(), err)
+	}
+
+	if len(key) == 0 {
+		return false, fmt.Errorf("error in Verify function: %s string has an empty hash", format.Name())
+	}
+
+	// encode the password
+	key2, err := kdf.DeriveKey([]byte(password), salt, int64(len(key)))
+
+	// check if an error occurred
+	if err != nil {
+		return false, fmt.Errorf("error in Verify function while encoding password: %w", err)
+	}
+
+	// compare the hashes in constant time
+	if subtle.ConstantTimeCompare(key, key2) == 1 {
+		return true, nil
+	}
+
+	// colon strings encoded before PBKDF2 used HMAC
+	params, ok := kdf.(PBKDF2Parameters)
+	if !ok || format.Name() != FormatColon {
+		return false, nil
+	}
+
+	key2, err = PBKDF2LegacyParameters(params).DeriveKey([]byte(password), salt, int64(len(key)))
+
+	// check if an error occurred
+	if err != nil {
+		return false, fmt.Errorf("error in Verify function while encoding password with PBKDF2Legacy: %w", err)
+	}
+
+	return subtle.ConstantTimeCompare(key, key2) == 1, nil
+}
+
+// colonPasswordFormat is the salt:iterationCount:hashedPassword format with PBKDF2 and a fixed hash function
+// the registered one uses SHA-256, ConvertPasswordHash uses the hash of its options
+// legacy strings were encoded with PBKDF2Legacy, before PBKDF2 used HMAC
+type colonPasswordFormat struct {
+	hash   crypto.Hash
+	legacy bool
+}
+
+// Name returns FormatColon
+func (f colonPasswordFormat) Name() string {
+	return FormatColon
+}
+
+// Recognize reports whether the string has three fields separated by colons and no $
+func (f colonPasswordFormat) Recognize(encodedPassword string) bool {
+	return !strings.Contains(encodedPassword, "$") && strings.Count(encodedPassword, ":") == 2
+}
+
+// Parse parses a salt:iterationCount:hashedPassword string
+func (f colonPasswordFormat) Parse(encodedPassword string) (KDF, []byte, []byte, error) {
+	if f.hash == 0 {
+		return nil, nil, nil, fmt.Errorf("%w: %s strings do not record the hash function, set ConvertOptions.Hash", ErrLossyConversion, FormatColon)
+	}
+
+	salt, iterationCount, key, err := GetPasswordParametersFromString(encodedPassword)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if iterationCount <= 0 || len(key) == 0 {
+		return nil, nil, nil, errors.New("iteration count and hash must not be empty")
+	}
+
+	if f.legacy {
+		return PBKDF2LegacyParameters{Hash: f.hash, Iterations: iterationCount}, salt, key, nil
+	}
+
+	return PBKDF2Parameters{Hash: f.hash, Iterations: iterationCount}, salt, key, nil
+}
+
+// Encode encodes a salt:iterationCount:hashedPassword string, the KDF must be PBKDF2(or PBKDF2Legacy for legacy strings) with the hash of the format
+func (f colonPasswordFormat) Encode(kdf KDF, salt, key []byte) (string, error) {
+	var params PBKDF2Parameters
+	switch kdf := kdf.(type) {
+	case PBKDF2Parameters:
+		params = kdf
+	case PBKDF2LegacyParameters:
+		params = PBKDF2Parameters(kdf)
+	}
+
+	if _, legacy := kdf.(PBKDF2LegacyParameters); params.Hash == 0 || legacy != f.legacy {
+		return "", fmt.Errorf("%w: %s strings hold PBKDF2, or PBKDF2Legacy with ConvertOptions.Legacy, not %s", ErrLossyConversion, FormatColon, kdf.Name())
+	}
+
+	if f.hash == 0 || params.Hash != f.hash {
+		return "", fmt.Errorf("%w: %s strings do not record the hash function %s, set ConvertOptions.Hash to it", ErrLossyConversion, FormatColon, kdfHashName(params.Hash))
+	}
+
+	return GeneratePasswordString(salt, params.Iterations, key), nil
+}
+
+// phcPasswordFormat is the PHC string format of the KDF registry
+type phcPasswordFormat struct{}
+
+// Name returns FormatPHC
+func (phcPasswordFormat) Name() string {
+	return FormatPHC
+}
+
+// Recognize reports whether the string starts with $ and the name of a registered KDF and is not a passlib string
+func (phcPasswordFormat) Recognize(encodedPassword string) bool {
+	rest, ok := strings.CutPrefix(encodedPassword, "$")
+	if !ok {
+		return false
+	}
+
+	name, _, _ := strings.Cut(rest, "$")
+
+	kdfRegistry.RLock()
+	_, ok = kdfRegistry.factories[name]
+	kdfRegistry.RUnlock()
+
+	return ok && !(passlibPasswordFormat{}).Recognize(encodedPassword)
+}
+
+// Parse resolves a PHC string to a registered KDF
+func (phcPasswordFormat) Parse(encodedPassword string) (KDF, []byte, []byte, error) {
+	return GetKDFParametersFromString(encodedPassword)
+}
+
+// Encode encodes a PHC string, the KDF must be registered so the string can be parsed
+func (phcPasswordFormat) Encode(kdf KDF, salt, key []byte) (string, error) {
+	if err := checkKDF(kdf); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrLossyConversion, err.Error())
+	}
+
+	return GenerateKDFPasswordString(kdf, salt, key), nil
+}
+
+// Django hasher names and their hash functions
+var djangoAlgorithms = []struct {
+	name string
+	hash crypto.Hash
+}{
+	{"pbkdf2_sha256", crypto.SHA256},
+	{"pbkdf2_sha1", crypto.SHA1},
+}
+
+// djangoPasswordFormat is the format of the Django PBKDF2 hashers
+type djangoPasswordFormat struct{}
+
+// Name returns FormatDjango
+func (djangoPasswordFormat) Name() string {
+	return FormatDjango
+}
+
+// Recognize reports whether the string starts with the name of a Django PBKDF2 hasher
+func (djangoPasswordFormat) Recognize(encodedPassword string) bool {
+	for _, algorithm := range djangoAlgorithms {
+		if strings.HasPrefix(encodedPassword, algorithm.name+"$") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parse parses a Django pbkdf2_sha256$iterationCount$salt$hash string
+func (djangoPasswordFormat) Parse(encodedPassword string) (KDF, []byte, []byte, error) {
+	fields := strings.SplitN(encodedPassword, "$", 4)
+	if len(fields) != 4 {
+		return nil, nil, nil, errors.New("Django strings must be in the format algorithm$iterationCount$salt$hash")
+	}
+
+	// find the algorithm
+	var hash crypto.Hash
+	for _, algorithm := range djangoAlgorithms {
+		if algorithm.name == fields[0] {
+			hash = algorithm.hash
+		}
+	}
+
+	if hash == 0 {
+		return nil, nil, nil, fmt.Errorf("unknown Django algorithm %q", fields[0])
+	}
+
+	iterationCount, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || iterationCount <= 0 {
+		return nil, nil, nil, fmt.Errorf("invalid Django iteration count %q", fields[1])
+	}
+
+	// the salt is used as text, the hash is in standard base64
+	key, err := base64.StdEncoding.Strict().DecodeString(fields[3])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid Django hash: %s", err.Error())
+	}
+
+	return PBKDF2Parameters{Hash: hash, Iterations: iterationCount}, []byte(fields[2]), key, nil
+}
+
+// Encode encodes a Django pbkdf2_sha256$iterationCount$salt$hash string
+// Django only has SHA-256 and SHA-1 hashers, derives keys of the hash size and stores the salt as text without $
+func (djangoPasswordFormat) Encode(kdf KDF, salt, key []byte) (string, error) {
+	params, ok := kdf.(PBKDF2Parameters)
+	if !ok {
+		return "", fmt.Errorf("%w: Django strings can only hold PBKDF2", ErrLossyConversion)
+	}
+
+	name := ""
+	for _, algorithm := range djangoAlgorithms {
+		if algorithm.hash == params.Hash {
+			name = algorithm.name
+		}
+	}
+
+	switch {
+	case name == "":
+		return "", fmt.Errorf("%w: Django has no PBKDF2 hasher with %s", ErrLossyConversion, kdfHashName(params.Hash))
+	case len(key) != params.Hash.Size():
+		return "", fmt.Errorf("%w: Django derives %d byte keys, the key has %d bytes", ErrLossyConversion, params.Hash.Size(), len(key))
+	case len(salt) == 0 || !utf8.Valid(salt) || bytes.ContainsRune(salt, '$'):
+		return "", fmt.Errorf("%w: Django salts are non-empty text without $", ErrLossyConversion)
+	}
+
+	return fmt.Sprintf("%s$%d$%s$%s", name, params.Iterations, salt, base64.StdEncoding.EncodeToString(key)), nil
+}
+
+// passlibEncoding is the adapted base64 of passlib: the standard alphabet with . instead of + and no padding
+var passlibEncoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./").WithPadding(base64.NoPadding).Strict()
+
+// passlib identifiers and their hash functions
+var passlibAlgorithms = []struct {
+	ident string
+	hash  crypto.Hash
+}{
+	{"pbkdf2", crypto.SHA1},
+	{"pbkdf2-sha256", crypto.SHA256},
+	{"pbkdf2-sha512", crypto.SHA512},
+}
+
+// limits of the passlib pbkdf2 handlers
+const (
+	passlibMaxRounds     = 1<<32 - 1
+	passlibMaxSaltLength = 1024
+)
+
+// passlibPasswordFormat is the format of the passlib pbkdf2 handlers
+type passlibPasswordFormat struct{}
+
+// Name returns FormatPasslib
+func (passlibPasswordFormat) Name() string {
+	return FormatPasslib
+}
+
+// Recognize reports whether the string starts with a passlib pbkdf2 identifier followed by a number of rounds
+// PHC strings of the same algorithms have i= before the iteration count
+func (passlibPasswordFormat) Recognize(encodedPassword string) bool {
+	for _, algorithm := range passlibAlgorithms {
+		if rest, ok := strings.CutPrefix(encodedPassword, "$"+algorithm.ident+"$"); ok {
+			return len(rest) > 0 && rest[0] >= '0' && rest[0] <= '9'
+		}
+	}
+
+	return false
+}
+
+// Parse parses a passlib $pbkdf2-sha256$iterationCount$salt$hash string
+func (passlibPasswordFormat) Parse(encodedPassword string) (KDF, []byte, []byte, error) {
+	fields := strings.Split(encodedPassword, "$")
+	if len(fields) != 5 || fields[0] != "" {
+		return nil, nil, nil, errors.New("passlib strings must be in the format $ident$iterationCount$salt$hash")
+	}
+
+	// find the algorithm
+	var hash crypto.Hash
+	for _, algorithm := range passlibAlgorithms {
+		if algorithm.ident == fields[1] {
+			hash = algorithm.hash
+		}
+	}
+
+	if hash == 0 {
+		return nil, nil, nil, fmt.Errorf("unknown passlib identifier %q", fields[1])
+	}
+
+	iterationCount, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || iterationCount <= 0 {
+		return nil, nil, nil, fmt.Errorf("invalid passlib iteration count %q", fields[2])
+	}
+
+	salt, err := passlibEncoding.DecodeString(fields[3])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid passlib salt: %s", err.Error())
+	}
+
+	key, err := passlibEncoding.DecodeString(fields[4])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid passlib hash: %s", err.Error())
+	}
+
+	return PBKDF2Parameters{Hash: hash, Iterations: iterationCount}, salt, key, nil
+}
+
+// Encode encodes a passlib $pbkdf2-sha256$iterationCount$salt$hash string
+// passlib only has SHA-1, SHA-256 and SHA-512 handlers, derives keys of the hash size and limits the rounds and the salt length
+func (passlibPasswordFormat) Encode(kdf KDF, salt, key []byte) (string, error) {
+	params, ok := kdf.(PBKDF2Parameters)
+	if !ok {
+		return "", fmt.Errorf("%w: passlib strings can only hold PBKDF2", ErrLossyConversion)
+	}
+
+	ident := ""
+	for _, algorithm := range passlibAlgorithms {
+		if algorithm.hash == params.Hash {
+			ident = algorithm.ident
+		}
+	}
+
+	switch {
+	case ident == "":
+		return "", fmt.Errorf("%w: passlib has no pbkdf2 handler with %s", ErrLossyConversion, kdfHashName(params.Hash))
+	case len(key) != params.Hash.Size():
+		return "", fmt.Errorf("%w: passlib derives %d byte keys, the key has %d bytes", ErrLossyConversion, params.Hash.Size(), len(key))
+	case params.Iterations > passlibMaxRounds:
+		return "", fmt.Errorf("%w: passlib allows at most %d rounds", ErrLossyConversion, int64(passlibMaxRounds))
+	case len(salt) > passlibMaxSaltLength:
+		return "", fmt.Errorf("%w: passlib allows salts of at most %d bytes", ErrLossyConversion, passlibMaxSaltLength)
+	}
+
+	return fmt.Sprintf("$%s$%d$%s$%s", ident, params.Iterations, passlibEncoding.EncodeToString(salt), passlibEncoding.EncodeToString(key)), nil
+}
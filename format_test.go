@@ -0,0 +1,161 @@
+package pbkdf
+
+import (
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// hexPasswordFormat is a custom format for the tests: hex-sha256:iterationCount:salt:hash with hex encoding
+type hexPasswordFormat struct{}
+
+func (hexPasswordFormat) Name() string {
+	return "hex-sha256"
+}
+
+func (hexPasswordFormat) Recognize(encodedPassword string) bool {
+	return strings.HasPrefix(encodedPassword, "hex-sha256:")
+}
+
+func (hexPasswordFormat) Parse(encodedPassword string) (KDF, []byte, []byte, error) {
+	var (
+		iterationCount int64
+		salt, key      []byte
+	)
+
+	if _, err := fmt.Sscanf(encodedPassword, "hex-sha256:%d:%x:%x", &iterationCount, &salt, &key); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return PBKDF2Parameters{Hash: crypto.SHA256, Iterations: iterationCount}, salt, key, nil
+}
+
+func (hexPasswordFormat) Encode(kdf KDF, salt, key []byte) (string, error) {
+	params, ok := kdf.(PBKDF2Parameters)
+	if !ok || params.Hash != crypto.SHA256 || len(salt) == 0 {
+		return "", fmt.Errorf("%w: only PBKDF2-SHA256 with a salt", ErrLossyConversion)
+	}
+
+	return fmt.Sprintf("hex-sha256:%d:%s:%s", params.Iterations, hex.EncodeToString(salt), hex.EncodeToString(key)), nil
+}
+
+// tests the detection of the registered formats with Verify
+func TestVerify(t *testing.T) {
+	colon, _ := EncodePasswordPBKDF2(crypto.SHA256, "password", 16, 1000, 32)
+	legacy, _ := EncodePassword(crypto.SHA256, "password", 16, 1000, 32, PBKDF2Legacy)
+	phc, _ := EncodePasswordKDF(PBKDF2Parameters{Hash: crypto.SHA512, Iterations: 1000}, "password", 16, 64)
+	scrypt, _ := EncodePasswordKDF(ScryptParameters{N: 16, R: 8, P: 1}, "password", 16, 32)
+
+	tests := []struct {
+		encoded string
+		format  string
+	}{
+		{colon, FormatColon},
+		{legacy, FormatColon},
+		{phc, FormatPHC},
+		{scrypt, FormatPHC},
+		{"pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=", FormatDjango},
+		{"pbkdf2_sha1$1000$seasalt$C8KvRfPW529R7JpDHEDOP35Xr0g=", FormatDjango},
+		{"$pbkdf2-sha512$1000$.vv8/f7/ACsvfg$4x1ExXMzMzt4O7sQ0WKTYIg/YoSsch5VRzx2fZ2JvYnQz0NaXb7mRa/T7S6QIw/hgZyYseehXCWqCUFXgRyDKg", FormatPasslib},
+		{"$pbkdf2$1000$.vv8/f7/ACsvfg$2n/VUdBFMlL/eAvug7d15brthi8", FormatPasslib},
+	}
+
+	for _, test := range tests {
+		if format, err := DetectPasswordFormat(test.encoded); err != nil || format.Name() != test.format {
+			t.Errorf("error in TestVerify function: %s was detected as %v, %v", test.encoded, format, err)
+		}
+
+		if ok, err := Verify("password", test.encoded); err != nil || !ok {
+			t.Errorf("error in TestVerify function: %s does not verify the password: %v", test.encoded, err)
+		}
+
+		if ok, err := Verify("wrong password", test.encoded); err != nil || ok {
+			t.Errorf("error in TestVerify function: %s verifies a wrong password: %v", test.encoded, err)
+		}
+	}
+
+	// unknown and invalid strings
+	for _, encoded := range []string{"", "bcrypt", "$2b$10$abcdefghijklmnopqrstuv", "pbkdf2_md5$1000$salt$a2V5"} {
+		if _, err := Verify("password", encoded); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("error in TestVerify function: %q gave %v", encoded, err)
+		}
+	}
+
+	for _, encoded := range []string{"pbkdf2_sha256$1000$seasalt", "$pbkdf2-sha256$x$c2FsdA$a2V5", "c2FsdA==:0:a2V5"} {
+		if _, err := Verify("password", encoded); err == nil || errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("error in TestVerify function: %q gave %v", encoded, err)
+		}
+	}
+}
+
+// tests registering a custom format
+func TestRegisterPasswordFormat(t *testing.T) {
+	if _, err := LookupPasswordFormat("hex-sha256"); errors.Is(err, ErrUnknownFormat) {
+		if err := RegisterPasswordFormat(hexPasswordFormat{}); err != nil {
+			t.Fatalf("error in TestRegisterPasswordFormat function: %s", err.Error())
+		}
+	}
+
+	// verify and convert its strings
+	encoded := "hex-sha256:1000:73656173616c74:6085a4b7a3352455eb1e0e6cd2366305273b0b60b3e90bdc85227487c63e8bb7"
+	if ok, err := Verify("password", encoded); err != nil || !ok {
+		t.Errorf("error in TestRegisterPasswordFormat function: custom string does not verify the password: %v", err)
+	}
+
+	django := "pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c="
+	if converted, err := ConvertPasswordHash(django, FormatDjango, "hex-sha256", ConvertOptions{}); err != nil || converted != encoded {
+		t.Errorf("error in TestRegisterPasswordFormat function: Django string converted to %q, %v", converted, err)
+	}
+
+	if converted, err := ConvertPasswordHash(encoded, "hex-sha256", FormatDjango, ConvertOptions{}); err != nil || converted != django {
+		t.Errorf("error in TestRegisterPasswordFormat function: custom string converted to %q, %v", converted, err)
+	}
+
+	// invalid and duplicate registrations
+	for _, format := range []PasswordFormat{nil, hexPasswordFormat{}, phcPasswordFormat{}} {
+		if err := RegisterPasswordFormat(format); err == nil {
+			t.Errorf("error in TestRegisterPasswordFormat function: %v was registered", format)
+		}
+	}
+
+	if err := RegisterPasswordFormat(namedPasswordFormat{"Hex_SHA256"}); err == nil {
+		t.Error("error in TestRegisterPasswordFormat function: invalid name was registered")
+	}
+
+	if _, err := LookupPasswordFormat("bcrypt"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("error in TestRegisterPasswordFormat function: unknown name gave %v", err)
+	}
+
+	if _, err := ConvertPasswordHash(django, FormatDjango, "bcrypt", ConvertOptions{}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("error in TestRegisterPasswordFormat function: conversion to an unknown format gave %v", err)
+	}
+
+	names := RegisteredPasswordFormats()
+	if len(names) < 5 || names[len(names)-1] != "hex-sha256" {
+		t.Errorf("error in TestRegisterPasswordFormat function: registered formats are %v", names)
+	}
+}
+
+// namedPasswordFormat is a format recognizing no string with any name
+type namedPasswordFormat struct {
+	name string
+}
+
+func (f namedPasswordFormat) Name() string {
+	return f.name
+}
+
+func (namedPasswordFormat) Recognize(encodedPassword string) bool {
+	return false
+}
+
+func (namedPasswordFormat) Parse(encodedPassword string) (KDF, []byte, []byte, error) {
+	return hexPasswordFormat{}.Parse(encodedPassword)
+}
+
+func (namedPasswordFormat) Encode(kdf KDF, salt, key []byte) (string, error) {
+	return hexPasswordFormat{}.Encode(kdf, salt, key)
+}
@@ -0,0 +1,423 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// fuzzHashes are the hash functions picked by the fuzz targets, unavailable ones included on purpose
+var fuzzHashes = []crypto.Hash{crypto.SHA1, crypto.SHA256, crypto.SHA512, crypto.MD5, crypto.SHA224, crypto.SHA384, crypto.MD4, crypto.SHA3_256, crypto.BLAKE2b_256, 0}
+
+// fuzzParameters maps arbitrary fuzz inputs to small parameters so every run stays fast
+// negative and zero values are kept to exercise the parameter checks
+func fuzzParameters(hashIndex uint8, c int16, dkLen int16) (crypto.Hash, int64, int64) {
+	return fuzzHashes[int(hashIndex)%len(fuzzHashes)], int64(c % 64), int64(dkLen % 200)
+}
+
+// FuzzGetPasswordParametersFromString checks the parser never panics
+// and that the parsed parameters survive a GeneratePasswordString round trip
+func FuzzGetPasswordParametersFromString(f *testing.F) {
+	f.Add("c2FsdHNhbHQ=:1000:aGFzaGhhc2hoYXNo")
+	f.Add("::")
+	f.Add("a:b:c:d")
+	f.Add("=:-1:=")
+	f.Add(":9223372036854775808:")
+
+	f.Fuzz(func(t *testing.T, encodedPassword string) {
+		salt, iterationCount, key, err := GetPasswordParametersFromString(encodedPassword)
+		if err != nil {
+			return
+		}
+
+		// the string can be non canonical(padding, line breaks), the parameters must round trip
+		salt2, iterationCount2, key2, err := GetPasswordParametersFromString(GeneratePasswordString(salt, iterationCount, key))
+		if err != nil {
+			t.Fatalf("re-encoded password string does not parse: %s", err.Error())
+		}
+
+		if !bytes.Equal(salt, salt2) || iterationCount != iterationCount2 || !bytes.Equal(key, key2) {
+			t.Fatalf("parameters changed after round trip")
+		}
+	})
+}
+
+// FuzzGeneratePasswordString checks any parameters can be encoded and parsed back
+func FuzzGeneratePasswordString(f *testing.F) {
+	f.Add([]byte("saltsalt"), int64(1000), []byte("key"))
+	f.Add([]byte{}, int64(-1), []byte{})
+	f.Add([]byte(":::"), int64(0), []byte{0xff, 0x00})
+
+	f.Fuzz(func(t *testing.T, salt []byte, iterationCount int64, key []byte) {
+		encoded := GeneratePasswordString(salt, iterationCount, key)
+
+		salt2, iterationCount2, key2, err := GetPasswordParametersFromString(encoded)
+		if err != nil {
+			t.Fatalf("generated password string %q does not parse: %s", encoded, err.Error())
+		}
+
+		if !bytes.Equal(salt, salt2) || iterationCount != iterationCount2 || !bytes.Equal(key, key2) {
+			t.Fatalf("parameters changed after round trip")
+		}
+	})
+}
+
+// FuzzKDF checks PBKDF1, PBKDF2 and PBKDF2Legacy never panic on arbitrary parameters
+// and return deterministic keys of the requested length
+func FuzzKDF(f *testing.F) {
+	f.Add(uint8(0), []byte("password"), []byte("salt"), int16(2), int16(20))
+	f.Add(uint8(1), []byte{}, []byte{}, int16(1), int16(0))
+	f.Add(uint8(2), []byte("p"), []byte("s"), int16(-1), int16(-1))
+	f.Add(uint8(6), []byte("p"), []byte("s"), int16(1), int16(16))
+
+	f.Fuzz(func(t *testing.T, hashIndex uint8, P, S []byte, c int16, dkLen int16) {
+		hash, iterationCount, keyLength := fuzzParameters(hashIndex, c, dkLen)
+
+		for name, kdf := range map[string]PBKDF{"PBKDF1": PBKDF1, "PBKDF2": PBKDF2, "PBKDF2Legacy": PBKDF2Legacy} {
+			DK, err := kdf(hash, P, S, iterationCount, keyLength)
+			if err != nil {
+				continue
+			}
+
+			// check the key length
+			if int64(len(DK)) != keyLength {
+				t.Fatalf("%s returned %d bytes, want %d", name, len(DK), keyLength)
+			}
+
+			// check the output is deterministic
+			DK2, err := kdf(hash, P, S, iterationCount, keyLength)
+			if err != nil || !bytes.Equal(DK, DK2) {
+				t.Fatalf("%s is not deterministic", name)
+			}
+		}
+	})
+}
+
+// FuzzEncodeVerify checks verify(encode(p)) is true and verify(encode(p)) with another password is false
+func FuzzEncodeVerify(f *testing.F) {
+	f.Add(uint8(0), "password", "Password", int16(2), int16(20), uint8(8))
+	f.Add(uint8(2), "", "x", int16(1), int16(64), uint8(16))
+	f.Add(uint8(1), "пароль", "пароль ", int16(3), int16(33), uint8(1))
+
+	f.Fuzz(func(t *testing.T, hashIndex uint8, password, other string, c int16, dkLen int16, saltLength uint8) {
+		hash, iterationCount, keyLength := fuzzParameters(hashIndex, c, dkLen)
+
+		for name, kdf := range map[string]PBKDF{"PBKDF1": PBKDF1, "PBKDF2": PBKDF2} {
+			encoded, err := EncodePassword(hash, password, int64(saltLength%32), iterationCount, keyLength, kdf)
+			if err != nil {
+				continue
+			}
+
+			// the password must verify
+			if valid, err := VerifyPassword(hash, password, encoded, kdf); err != nil || !valid {
+				t.Fatalf("%s: password does not verify against its own encoding %q(%v)", name, encoded, err)
+			}
+
+			// another password must not verify, unless the key is too short to tell them apart
+			// HMAC pads short keys with zeros, so passwords that only differ in trailing NUL bytes are equivalent in PBKDF2
+			distinct := strings.TrimRight(other, "\x00") != strings.TrimRight(password, "\x00")
+			if valid, err := VerifyPassword(hash, other, encoded, kdf); err == nil && valid && distinct && keyLength >= 8 {
+				t.Fatalf("%s: different password verifies against %q", name, encoded)
+			}
+		}
+	})
+}
+
+// FuzzConvertUnsignedInteger checks the integer conversions round trip in both byte orders
+func FuzzConvertUnsignedInteger(f *testing.F) {
+	f.Add(uint64(1), uint8(4), false)
+	f.Add(uint64(1), uint8(4), true)
+	f.Add(uint64(1<<64-1), uint8(8), true)
+	f.Add(uint64(0x0102), uint8(0), false)
+
+	f.Fuzz(func(t *testing.T, integer uint64, byteLength uint8, bigEndian bool) {
+		length := int(byteLength % 9)
+
+		b := ConvertUnsignedIntegerToByteSlice(integer, length, bigEndian)
+		if len(b) != length {
+			t.Fatalf("got %d bytes, want %d", len(b), length)
+		}
+
+		// only the low length bytes of the integer are kept
+		expected := integer
+		if length < 8 {
+			expected &= 1<<(8*length) - 1
+		}
+
+		if got := ConvertSliceToUnsignedInteger(b, bigEndian); got != expected {
+			t.Fatalf("round trip of %d with %d bytes gave %d", expected, length, got)
+		}
+	})
+}
+
+// FuzzBreachedPasswordFile checks the breached password parser and converter never panic
+// and that every hash accepted by the converter is found by the checker
+func FuzzBreachedPasswordFile(f *testing.F) {
+	f.Add("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n")
+	f.Add("0000000000000000000000000000000000000000\nFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:2\n")
+	f.Add(":\n\n:1")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		// the text checker must not panic on malformed data
+		checker := &BreachedPasswordChecker{data: []byte(data), unmap: func() error { return nil }}
+		checker.Count("password")
+
+		// convert the data
+		var index bytes.Buffer
+		records, err := ConvertBreachedPasswordFile(strings.NewReader(data), &index)
+		if err != nil {
+			return
+		}
+
+		// every line of a valid file must be found in the text and compact formats
+		compact := &BreachedPasswordChecker{data: index.Bytes(), compact: true, unmap: func() error { return nil }}
+		for _, line := range strings.Split(data, "\n") {
+			lineHash, count, err := parseBreachedLine([]byte(line))
+			if err != nil {
+				continue
+			}
+
+			var digest [20]byte
+			hex.Decode(digest[:], lineHash)
+
+			if got, err := checker.CountSHA1(digest); err != nil || (got != count && count > 0) {
+				t.Fatalf("text checker found %d(%v) for %s, want %d", got, err, lineHash, count)
+			}
+
+			if got, _ := compact.CountSHA1(digest); count > 0 && count <= 1<<32-1 && got != count {
+				t.Fatalf("compact checker found %d for %s, want %d", got, lineHash, count)
+			}
+		}
+
+		if int64(index.Len()) != int64(len(breachedIndexMagic))+records*breachedIndexRecordSize {
+			t.Fatalf("index has %d bytes for %d records", index.Len(), records)
+		}
+	})
+}
+
+// FuzzPasswordPolicy checks the policy never panics and always reports valid codes
+func FuzzPasswordPolicy(f *testing.F) {
+	f.Add("correct horse battery staple", "alice")
+	f.Add("\xff\xfe", "")
+	f.Add("aaaaaaaa", "a.b@c")
+
+	policy := DefaultPasswordPolicy()
+	policy.RequireDigit = true
+	policy.MinCharacterClasses = 3
+
+	f.Fuzz(func(t *testing.T, password, context string) {
+		for _, v := range policy.CheckWithContext(password, context) {
+			if !strings.HasPrefix(string(v.Code), "password.") || v.String() == "" {
+				t.Fatalf("invalid violation %v", v)
+			}
+		}
+	})
+}
+
+// FuzzGetScryptParametersFromString checks the scrypt PHC parser never panics
+// and that the parsed parameters survive a GenerateScryptPasswordString round trip
+func FuzzGetScryptParametersFromString(f *testing.F) {
+	f.Add("$scrypt$ln=4,r=1,p=1$c2FsdA$a2V5")
+	f.Add("$scrypt$ln=63,r=1,p=1$$")
+	f.Add("$scrypt$r=1,ln=4,p=1$c2FsdA$a2V5")
+	f.Add("$scrypt$v=1$ln=4,r=1,p=1$c2FsdA$a2V5")
+
+	f.Fuzz(func(t *testing.T, encodedPassword string) {
+		salt, N, r, p, key, err := GetScryptParametersFromString(encodedPassword)
+		if err != nil {
+			return
+		}
+
+		// strict base64 and canonical integers make the string canonical too
+		if encoded := GenerateScryptPasswordString(salt, N, r, p, key); encoded != encodedPassword {
+			t.Fatalf("round trip of %q gave %q", encodedPassword, encoded)
+		}
+	})
+}
+
+// FuzzGetArgon2ParametersFromString checks the Argon2 PHC parser never panics
+// and that the parsed parameters survive a GenerateArgon2PasswordString round trip
+func FuzzGetArgon2ParametersFromString(f *testing.F) {
+	f.Add("$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$a2V5a2V5")
+	f.Add("$argon2i$v=19$m=65536,t=2,p=4$$")
+	f.Add("$argon2d$v=16$m=64,t=1,p=1$c29tZXNhbHQ$a2V5a2V5")
+	f.Add("$argon2id$v=19$m=64,t=1,p=1,keyid=abc$c29tZXNhbHQ$a2V5a2V5")
+
+	f.Fuzz(func(t *testing.T, encodedPassword string) {
+		variant, salt, passes, memory, parallelism, key, err := GetArgon2ParametersFromString(encodedPassword)
+		if err != nil {
+			return
+		}
+
+		// strict base64 and canonical integers make the string canonical too
+		if encoded := GenerateArgon2PasswordString(variant, salt, passes, memory, parallelism, key); encoded != encodedPassword {
+			t.Fatalf("round trip of %q gave %q", encodedPassword, encoded)
+		}
+	})
+}
+
+// FuzzGetKDFParametersFromString checks the registry PHC parser never panics
+// and that resolved strings survive a GenerateKDFPasswordString round trip
+func FuzzGetKDFParametersFromString(f *testing.F) {
+	f.Add("$pbkdf2-sha256$i=1000$c2FsdA$a2V5")
+	f.Add("$pbkdf1-sha1$i=1$$")
+	f.Add("$scrypt$ln=4,r=1,p=1$c2FsdA$a2V5")
+	f.Add("$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$a2V5a2V5")
+	f.Add("$pbkdf2-legacy-sha3-512$i=01$c2FsdA$a2V5")
+
+	f.Fuzz(func(t *testing.T, encodedPassword string) {
+		kdf, salt, key, err := GetKDFParametersFromString(encodedPassword)
+		if err != nil {
+			return
+		}
+
+		if encoded := GenerateKDFPasswordString(kdf, salt, key); encoded != encodedPassword {
+			t.Fatalf("round trip of %q gave %q", encodedPassword, encoded)
+		}
+	})
+}
+
+// FuzzParseEnvelopeHeader checks the envelope parser never panics
+// and that a parsed header is written back as the same bytes
+func FuzzParseEnvelopeHeader(f *testing.F) {
+	envelope, _ := SealWithOptions("password", []byte("plaintext"), nil, SealOptions{Iterations: 1})
+	f.Add(envelope)
+	f.Add([]byte("PBKE\x01\x01\x05\x00\x00\x00\x01\x00\x01\x00"))
+
+	f.Fuzz(func(t *testing.T, envelope []byte) {
+		header, length, err := parseEnvelopeHeader(envelopeMagic, envelope)
+		if err != nil {
+			return
+		}
+
+		if encoded := header.bytes(envelopeMagic); !bytes.Equal(encoded, envelope[:length]) {
+			t.Fatalf("round trip of %x gave %x", envelope[:length], encoded)
+		}
+	})
+}
+
+// FuzzParseKeySlot checks the key slot parser never panics
+// and that a parsed slot is serialized back to the same string
+func FuzzParseKeySlot(f *testing.F) {
+	f.Add("aes-kw:pbkdf2-sha256:c2FsdA==:1000:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+	f.Add("aes-gcm:pbkdf2-sha3-512::1:")
+	f.Add("aes-kwp:pbkdf2-blake2b-256:c2FsdA==:600000:AA==")
+
+	f.Fuzz(func(t *testing.T, encoded string) {
+		slot, err := ParseKeySlot(encoded)
+		if err != nil {
+			return
+		}
+
+		if slot.String() != encoded {
+			t.Fatalf("round trip of %q gave %q", encoded, slot.String())
+		}
+	})
+}
+
+// FuzzKeyContainerUnmarshalBinary checks the key container decoder never panics
+// and that a decoded container encodes to the same slots
+func FuzzKeyContainerUnmarshalBinary(f *testing.F) {
+	container, _ := NewKeyContainer([]byte("0123456789abcdef"), KeyContainerOptions{Slots: 2, Stripes: 2})
+	container.AddSlot([]byte("0123456789abcdef"), "user", "password", KeySlotOptions{Iterations: 1})
+	encoded, _ := container.MarshalBinary()
+	f.Add(encoded)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c := &KeyContainer{}
+		if err := c.UnmarshalBinary(data); err != nil {
+			return
+		}
+
+		// only the random padding can change
+		encoded, err := c.MarshalBinary()
+		if err != nil {
+			if len(c.ListSlots()) != 0 {
+				t.Fatalf("encoding a decoded container failed: %s", err.Error())
+			}
+			return
+		}
+
+		again := &KeyContainer{}
+		if err := again.UnmarshalBinary(encoded); err != nil || len(encoded) != len(data) {
+			t.Fatalf("re-encoded container of %d bytes gave %d bytes, %v", len(data), len(encoded), err)
+		}
+
+		for i, info := range c.ListSlots() {
+			if again.ListSlots()[i] != info || !bytes.Equal(again.slots[info.Index].WrappedKey, c.slots[info.Index].WrappedKey) {
+				t.Fatalf("slot %d changed", info.Index)
+			}
+		}
+	})
+}
+
+// FuzzParsePasswordHash checks the PasswordHash parser never panics
+// and that a parsed hash is marshaled back to the same string
+func FuzzParsePasswordHash(f *testing.F) {
+	f.Add("c2FsdA==:1000:a2V5")
+	f.Add("$pbkdf2-sha256$i=1000$c2FsdA$a2V5")
+	f.Add("$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$a2V5a2V5")
+
+	f.Fuzz(func(t *testing.T, encodedPassword string) {
+		h, err := ParsePasswordHash(encodedPassword)
+		if err != nil {
+			return
+		}
+
+		if text, _ := h.MarshalText(); string(text) != encodedPassword || strings.Contains(h.String(), encodedPassword) {
+			t.Fatalf("round trip of %q gave %q", encodedPassword, text)
+		}
+	})
+}
+
+// FuzzConvertPasswordHash checks the format parsers never panic
+// and that a string converted to PHC and back is unchanged
+func FuzzConvertPasswordHash(f *testing.F) {
+	f.Add("pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=")
+	f.Add("$pbkdf2$1000$.vv8/f7/ACsvfg$2n/VUdBFMlL/eAvug7d15brthi8")
+	f.Add("c2FsdA==:1000:a2V5")
+
+	f.Fuzz(func(t *testing.T, encodedPassword string) {
+		options := ConvertOptions{Hash: crypto.SHA256}
+
+		for _, format := range []string{FormatColon, FormatDjango, FormatPasslib} {
+			phc, err := ConvertPasswordHash(encodedPassword, format, FormatPHC, options)
+			if err != nil {
+				continue
+			}
+
+			if back, err := ConvertPasswordHash(phc, FormatPHC, format, options); err != nil || back != encodedPassword {
+				t.Fatalf("%s string %q converted back to %q, %v", format, encodedPassword, back, err)
+			}
+		}
+	})
+}
+
+// FuzzDetectPasswordFormat checks the format recognizers and parsers never panic
+// and that a parsed string is recognized by one format only
+func FuzzDetectPasswordFormat(f *testing.F) {
+	f.Add("pbkdf2_sha256$1000$seasalt$YIWkt6M1JFXrHg5s0jZjBSc7C2Cz6QvchSJ0h8Y+i7c=")
+	f.Add("$pbkdf2-sha512$1000$.vv8/f7/ACsvfg$2n/VUdBFMlL/eAvug7d15brthi8")
+	f.Add("$pbkdf2-sha256$i=1000$c2FsdA$a2V5")
+	f.Add("c2FsdA==:1000:a2V5")
+
+	f.Fuzz(func(t *testing.T, encodedPassword string) {
+		format, err := DetectPasswordFormat(encodedPassword)
+		if err != nil {
+			return
+		}
+
+		if _, _, _, err := format.Parse(encodedPassword); err != nil {
+			return
+		}
+
+		for _, name := range []string{FormatColon, FormatPHC, FormatDjango, FormatPasslib} {
+			other, _ := LookupPasswordFormat(name)
+			if name != format.Name() && other.Recognize(encodedPassword) {
+				t.Fatalf("%s string %q is recognized as %s too", format.Name(), encodedPassword, name)
+			}
+		}
+	})
+}
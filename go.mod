@@ -1,3 +1,3 @@
-module github.com/giovanibageston/pbkdf
+module github.com/giovanibageston/pbkdf/v2
 
 go 1.21.4
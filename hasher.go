@@ -0,0 +1,350 @@
+package pbkdf
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// default parameters used by NewHasher
+const (
+	// DefaultSaltLength is the default salt length in bytes
+	DefaultSaltLength int64 = 16
+	// DefaultIterationCount is the default iteration count
+	DefaultIterationCount int64 = 600000
+	// DefaultKeyLength is the default derived key length in bytes
+	DefaultKeyLength int64 = 32
+)
+
+// default Argon2id parameters, the first OWASP recommendation(19 MiB of memory, 2 passes, no parallelism)
+const (
+	// DefaultArgon2Passes is the default number of Argon2 passes
+	DefaultArgon2Passes int64 = 2
+	// DefaultArgon2Memory is the default Argon2 memory size in KiB
+	DefaultArgon2Memory int64 = 19456
+	// DefaultArgon2Parallelism is the default Argon2 parallelism
+	DefaultArgon2Parallelism int64 = 1
+)
+
+// PasswordValidator is implemented by anything that can reject a password before it is hashed
+// ValidatePassword must return nil if the password is acceptable
+type PasswordValidator interface {
+	ValidatePassword(password string) error
+}
+
+// PasswordValidatorFunc adapts an ordinary function to the PasswordValidator interface
+type PasswordValidatorFunc func(password string) error
+
+// ValidatePassword calls f(password)
+func (f PasswordValidatorFunc) ValidatePassword(password string) error {
+	return f(password)
+}
+
+// Hasher encodes and verifies passwords with a fixed set of parameters
+// A Hasher is created with NewHasher and configured with HasherOption values
+// it is safe for concurrent use once created
+type Hasher struct {
+	hash           crypto.Hash
+	kdf            PBKDF
+	saltLength     int64
+	iterationCount int64
+	keyLength      int64
+	algorithm      KDF
+	policy         *PasswordPolicy
+	validators     []PasswordValidator
+	legacyFallback bool
+}
+
+// HasherOption configures a Hasher
+type HasherOption func(h *Hasher) error
+
+// NewHasher creates a Hasher
+// Without options it uses PBKDF2 with SHA-256, DefaultSaltLength, DefaultIterationCount and DefaultKeyLength
+// The options parameter is the list of options applied in order
+func NewHasher(options ...HasherOption) (*Hasher, error) {
+	// create the hasher with the default parameters
+	h := &Hasher{
+		hash:           crypto.SHA256,
+		kdf:            PBKDF2,
+		saltLength:     DefaultSaltLength,
+		iterationCount: DefaultIterationCount,
+		keyLength:      DefaultKeyLength,
+	}
+
+	// apply the options
+	for _, option := range options {
+		if err := option(h); err != nil {
+			return nil, fmt.Errorf("error in NewHasher function while applying option: %s", err.Error())
+		}
+	}
+
+	// return the hasher
+	return h, nil
+}
+
+// WithHash sets the hash function used by the Hasher
+// The hash parameter must be available(linked into the binary)
+func WithHash(hash crypto.Hash) HasherOption {
+	return func(h *Hasher) error {
+		if !hash.Available() {
+			return fmt.Errorf("hash function %d is not available", hash)
+		}
+
+		h.hash = hash
+		return nil
+	}
+}
+
+// WithKDF sets the key derivation function used by the Hasher
+// The kdf parameter must have the PBKDF signature
+func WithKDF(kdf PBKDF) HasherOption {
+	return func(h *Hasher) error {
+		if kdf == nil {
+			return errors.New("kdf must not be nil")
+		}
+
+		h.kdf = kdf
+		return nil
+	}
+}
+
+// WithLegacyPBKDF2Fallback makes Verify try PBKDF2Legacy when a salt:iterationCount:hashedPassword string does not match with the kdf of the Hasher
+// it verifies the passwords encoded before PBKDF2 used HMAC, use VerifyAndRehash to replace them on the next successful login
+// a wrong password then costs two derivations
+func WithLegacyPBKDF2Fallback() HasherOption {
+	return func(h *Hasher) error {
+		h.legacyFallback = true
+		return nil
+	}
+}
+
+// WithSaltLength sets the salt length in bytes
+func WithSaltLength(saltLength int64) HasherOption {
+	return func(h *Hasher) error {
+		if saltLength <= 0 {
+			return errors.New("salt length must be positive")
+		}
+
+		h.saltLength = saltLength
+		return nil
+	}
+}
+
+// WithIterationCount sets the iteration count
+func WithIterationCount(iterationCount int64) HasherOption {
+	return func(h *Hasher) error {
+		if iterationCount <= 0 {
+			return errors.New("iteration count must be positive")
+		}
+
+		h.iterationCount = iterationCount
+		return nil
+	}
+}
+
+// WithKeyLength sets the derived key length in bytes
+func WithKeyLength(keyLength int64) HasherOption {
+	return func(h *Hasher) error {
+		if keyLength <= 0 {
+			return errors.New("key length must be positive")
+		}
+
+		h.keyLength = keyLength
+		return nil
+	}
+}
+
+// WithAlgorithm makes the Hasher encode passwords with a registered KDF in the PHC string format instead of PBKDF
+// The kdf parameter is the algorithm and its parameters(for example PBKDF2Parameters or ScryptParameters)
+// the salt and key lengths are still set by WithSaltLength and WithKeyLength
+// the hash, kdf and iteration count are still used to verify passwords encoded before the migration
+func WithAlgorithm(kdf KDF) HasherOption {
+	return func(h *Hasher) error {
+		if err := checkKDF(kdf); err != nil {
+			return err
+		}
+
+		h.algorithm = kdf
+		return nil
+	}
+}
+
+// WithArgon2 makes the Hasher encode passwords with Argon2 in the PHC string format instead of PBKDF
+// The variant parameter is the Argon2 variant
+// The t, m and p parameters are the number of passes, the memory in KiB and the parallelism
+// the salt and key lengths are still set by WithSaltLength and WithKeyLength, the salt must be at least 8 bytes
+// the hash, kdf and iteration count are still used to verify passwords encoded before the migration
+func WithArgon2(variant Argon2Variant, t, m, p int64) HasherOption {
+	return func(h *Hasher) error {
+		// check the parameters
+		switch {
+		case variant != Argon2d && variant != Argon2i && variant != Argon2id:
+			return fmt.Errorf("unknown Argon2 variant %d", variant)
+		case t < 1 || t > 1<<32-1:
+			return errors.New("Argon2 passes must be between 1 and 2^32-1")
+		case p < 1 || p > 1<<24-1:
+			return errors.New("Argon2 parallelism must be between 1 and 2^24-1")
+		case m < 8*p || m*1024 > DefaultArgon2MaxMemory:
+			return fmt.Errorf("Argon2 memory must be between 8 * p KiB and %d KiB", DefaultArgon2MaxMemory/1024)
+		}
+
+		h.algorithm = Argon2Parameters{Variant: variant, Passes: t, Memory: m, Parallelism: p}
+		return nil
+	}
+}
+
+// WithArgon2id makes the Hasher encode passwords with Argon2id, see WithArgon2
+// use DefaultArgon2Passes, DefaultArgon2Memory and DefaultArgon2Parallelism for the recommended parameters
+func WithArgon2id(t, m, p int64) HasherOption {
+	return WithArgon2(Argon2id, t, m, p)
+}
+
+// WithPasswordValidator adds a validator that is run by Hash before the password is hashed
+// validators are run in the order they were added and the first error is returned
+func WithPasswordValidator(validator PasswordValidator) HasherOption {
+	return func(h *Hasher) error {
+		if validator == nil {
+			return errors.New("password validator must not be nil")
+		}
+
+		h.validators = append(h.validators, validator)
+		return nil
+	}
+}
+
+// WithPasswordPolicy sets the policy checked by Hash before the validators
+// use HashWithContext to check per-user context words like the username
+func WithPasswordPolicy(policy *PasswordPolicy) HasherOption {
+	return func(h *Hasher) error {
+		if policy == nil {
+			return errors.New("password policy must not be nil")
+		}
+
+		h.policy = policy
+		return nil
+	}
+}
+
+// ValidatePassword runs the policy and every validator configured on the Hasher
+// The password parameter is the candidate password
+// nil is returned if all validators accept the password
+func (h *Hasher) ValidatePassword(password string) error {
+	return h.ValidatePasswordWithContext(password)
+}
+
+// ValidatePasswordWithContext is like ValidatePassword with per-user context words for the policy
+func (h *Hasher) ValidatePasswordWithContext(password string, contextWords ...string) error {
+	// check the policy first, it reports every violation at once
+	if h.policy != nil {
+		if err := h.policy.ValidatePasswordWithContext(password, contextWords...); err != nil {
+			return err
+		}
+	}
+
+	// run the validators
+	for _, validator := range h.validators {
+		if err := validator.ValidatePassword(password); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Hash validates and encodes a password
+// The encoded password is returned in the format: salt:iterationCount:hashedPassword(salt and hashedPassword are base64 encoded)
+// or in the PHC string format($argon2id$v=19$m=..,t=..,p=..$salt$hash, ...) if the Hasher uses WithAlgorithm or WithArgon2
+// The password parameter is the password to be encoded
+// if the policy or a validator rejects the password its error is returned unchanged so it can be inspected with errors.Is/errors.As
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.HashWithContext(password)
+}
+
+// HashWithContext is like Hash with per-user context words(username, email address, ...) for the policy
+func (h *Hasher) HashWithContext(password string, contextWords ...string) (string, error) {
+	// run the pre-hash validation step
+	if err := h.ValidatePasswordWithContext(password, contextWords...); err != nil {
+		return "", err
+	}
+
+	// encode the password
+	return h.encode(password)
+}
+
+// encode encodes a password with the parameters of the Hasher without validating it
+func (h *Hasher) encode(password string) (string, error) {
+	if h.algorithm != nil {
+		return EncodePasswordKDF(h.algorithm, password, h.saltLength, h.keyLength)
+	}
+
+	return EncodePassword(h.hash, password, h.saltLength, h.iterationCount, h.keyLength, h.kdf)
+}
+
+// Verify checks if a password matches an encoded password
+// The password parameter is the password to be checked
+// The encodedPassword parameter is the encoded password
+// PHC strings are verified with the registered KDF they name, other strings with the hash and kdf of the Hasher
+// so a Hasher migrated to another algorithm keeps verifying the passwords encoded before
+// validators are not run on verification
+// with WithLegacyPBKDF2Fallback the strings that do not match are verified again with PBKDF2Legacy
+func (h *Hasher) Verify(password, encodedPassword string) (bool, error) {
+	match, _, err := h.verify(password, encodedPassword)
+	return match, err
+}
+
+// VerifyAndRehash checks if a password matches an encoded password and returns the string to store instead when it needs a rehash
+// The password parameter is the password to be checked
+// The encodedPassword parameter is the encoded password
+// the new string is empty unless the password matches and the string was created with other parameters(see NeedsRehash)
+// or matched with the PBKDF2Legacy fallback, validators are not run because the password is already in use
+func (h *Hasher) VerifyAndRehash(password, encodedPassword string) (bool, string, error) {
+	match, legacy, err := h.verify(password, encodedPassword)
+	if err != nil || !match || !legacy && !h.NeedsRehash(encodedPassword) {
+		return match, "", err
+	}
+
+	// encode the password with the parameters of the Hasher
+	rehashed, err := h.encode(password)
+	if err != nil {
+		return true, "", fmt.Errorf("error in Hasher.VerifyAndRehash method while encoding password: %w", err)
+	}
+
+	return true, rehashed, nil
+}
+
+// verify checks a password and reports whether it matched with the PBKDF2Legacy fallback
+func (h *Hasher) verify(password, encodedPassword string) (bool, bool, error) {
+	if strings.HasPrefix(encodedPassword, "$") {
+		match, err := VerifyPasswordKDF(password, encodedPassword)
+		return match, false, err
+	}
+
+	match, err := VerifyPassword(h.hash, password, encodedPassword, h.kdf)
+	if err != nil || match || !h.legacyFallback {
+		return match, false, err
+	}
+
+	// passwords encoded before PBKDF2 used HMAC
+	match, err = VerifyPassword(h.hash, password, encodedPassword, PBKDF2Legacy)
+	return match, match, err
+}
+
+// NeedsRehash reports whether an encoded password was created with other parameters than the ones of the Hasher
+// it should be called after a successful Verify, the password is then hashed again with Hash and stored
+// The encodedPassword parameter is the encoded password
+// strings that can not be parsed need a rehash too
+func (h *Hasher) NeedsRehash(encodedPassword string) bool {
+	// the Hasher uses a KDF
+	if h.algorithm != nil {
+		kdf, salt, key, err := GetKDFParametersFromString(encodedPassword)
+		return err != nil || kdf.Name() != h.algorithm.Name() || !equalKDFParams(kdf.Params(), h.algorithm.Params()) ||
+			int64(len(salt)) != h.saltLength || int64(len(key)) != h.keyLength
+	}
+
+	// the Hasher uses PBKDF, the hash and kdf are not stored in the string and can not be checked
+	salt, iterationCount, key, err := GetPasswordParametersFromString(encodedPassword)
+	return err != nil || strings.HasPrefix(encodedPassword, "$") || iterationCount != h.iterationCount ||
+		int64(len(salt)) != h.saltLength || int64(len(key)) != h.keyLength
+}
@@ -0,0 +1,186 @@
+package pbkdf
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// ErrPasswordReused is returned by PasswordHistory.ValidatePassword
+// when the password matches one of the previous passwords
+var ErrPasswordReused = errors.New("password matches a previously used password")
+
+// PasswordVerifier checks a password against an encoded password
+// Hasher implements this interface
+type PasswordVerifier interface {
+	Verify(password, encodedPassword string) (bool, error)
+}
+
+// PasswordVerifierFunc adapts an ordinary function to the PasswordVerifier interface
+type PasswordVerifierFunc func(password, encodedPassword string) (bool, error)
+
+// Verify calls f(password, encodedPassword)
+func (f PasswordVerifierFunc) Verify(password, encodedPassword string) (bool, error) {
+	return f(password, encodedPassword)
+}
+
+// PasswordVerifiers combines several verifiers into one
+// The verifiers parameter is the list of verifiers tried for every encoded password
+// the password matches if any verifier matches it, an error is only returned if every verifier failed
+// use it when a history holds hashes created with different hashes or kdfs
+func PasswordVerifiers(verifiers ...PasswordVerifier) PasswordVerifier {
+	return PasswordVerifierFunc(func(password, encodedPassword string) (bool, error) {
+		var lastErr error
+		matched, anyOK := false, false
+
+		// try every verifier, without stopping at the first match
+		for _, verifier := range verifiers {
+			valid, err := verifier.Verify(password, encodedPassword)
+
+			// remember the error, another verifier may understand the encoded password
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			matched = matched || valid
+			anyOK = true
+		}
+
+		// every verifier failed
+		if !anyOK {
+			return false, lastErr
+		}
+
+		return matched, nil
+	})
+}
+
+// PasswordHistory holds the encoded hashes of a user's previous passwords to prevent reuse
+// It is not safe for concurrent modification
+type PasswordHistory struct {
+	// Size is the number of previous passwords kept, Add and Trim drop older entries
+	Size int
+	// Concurrency bounds the number of verifications running at the same time
+	// zero means runtime.GOMAXPROCS(0)
+	Concurrency int
+	// Verifier checks a password against one entry
+	Verifier PasswordVerifier
+	// Entries are the encoded hashes, most recent first
+	Entries []string
+}
+
+// NewPasswordHistory creates a password history
+// The size parameter is the number of previous passwords kept
+// The verifier parameter checks a password against one entry(a Hasher or PasswordVerifiers for mixed entries)
+// The entries parameter holds the existing encoded hashes, most recent first, it is trimmed to size
+func NewPasswordHistory(size int, verifier PasswordVerifier, entries ...string) (*PasswordHistory, error) {
+	// check the size
+	if size < 0 {
+		return nil, errors.New("error in NewPasswordHistory function: size must not be negative")
+	}
+
+	// check the verifier
+	if verifier == nil {
+		return nil, errors.New("error in NewPasswordHistory function: verifier must not be nil")
+	}
+
+	// create the history
+	h := &PasswordHistory{Size: size, Verifier: verifier, Entries: append([]string{}, entries...)}
+	h.Trim()
+
+	return h, nil
+}
+
+// Add records a new encoded hash as the most recent entry and trims the history to Size
+// The encodedPassword parameter is the encoded hash of the new password
+func (h *PasswordHistory) Add(encodedPassword string) {
+	h.Entries = append([]string{encodedPassword}, h.Entries...)
+	h.Trim()
+}
+
+// Trim drops the oldest entries so at most Size entries are kept
+func (h *PasswordHistory) Trim() {
+	if len(h.Entries) > h.Size {
+		// clear the dropped entries so they don't stay reachable through the backing array
+		for i := h.Size; i < len(h.Entries); i++ {
+			h.Entries[i] = ""
+		}
+
+		h.Entries = h.Entries[:h.Size]
+	}
+}
+
+// Contains checks if a password matches any entry of the history
+// The password parameter is the candidate password
+// every entry is always verified, so neither the result nor the time taken reveal which entry matched
+// an error is returned only if no entry matched and at least one entry could not be verified
+func (h *PasswordHistory) Contains(password string) (bool, error) {
+	// check the verifier
+	if h.Verifier == nil {
+		return false, errors.New("error in PasswordHistory.Contains method: verifier must not be nil")
+	}
+
+	// bound the concurrency
+	concurrency := h.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	// verify the entries with at most concurrency goroutines
+	matches := make([]bool, len(h.Entries))
+	errs := make([]error, len(h.Entries))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, entry := range h.Entries {
+		semaphore <- struct{}{}
+		wg.Add(1)
+
+		go func(i int, entry string) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			matches[i], errs[i] = h.Verifier.Verify(password, entry)
+		}(i, entry)
+	}
+
+	wg.Wait()
+
+	// combine the results without branching on the position of the match
+	matched, failed := false, 0
+	for i := range matches {
+		matched = matched || (matches[i] && errs[i] == nil)
+		if errs[i] != nil {
+			failed++
+		}
+	}
+
+	// report entries that could not be verified, without saying which ones
+	if !matched && failed > 0 {
+		return false, fmt.Errorf("error in PasswordHistory.Contains method: %d of %d entries could not be verified", failed, len(h.Entries))
+	}
+
+	return matched, nil
+}
+
+// ValidatePassword implements the PasswordValidator interface
+// it returns an error wrapping ErrPasswordReused if the password matches any entry
+func (h *PasswordHistory) ValidatePassword(password string) error {
+	// check the history
+	reused, err := h.Contains(password)
+
+	// check if an error occurred
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		return fmt.Errorf("error in PasswordHistory.ValidatePassword method: %w", ErrPasswordReused)
+	}
+
+	return nil
+}
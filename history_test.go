@@ -0,0 +1,66 @@
+package pbkdf
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// tests the PasswordHistory with entries created by different hashers
+func TestPasswordHistory(t *testing.T) {
+	// entries created with PBKDF1/SHA-1 and PBKDF2/SHA-512
+	oldHasher, err := NewHasher(WithKDF(PBKDF1), WithHash(crypto.SHA1), WithIterationCount(1000), WithKeyLength(20))
+	if err != nil {
+		t.Fatalf("error in TestPasswordHistory function while creating hasher: %s", err.Error())
+	}
+
+	newHasher, err := NewHasher(WithHash(crypto.SHA512), WithIterationCount(1000))
+	if err != nil {
+		t.Fatalf("error in TestPasswordHistory function while creating hasher: %s", err.Error())
+	}
+
+	history, err := NewPasswordHistory(3, PasswordVerifiers(newHasher, oldHasher))
+	if err != nil {
+		t.Fatalf("error in TestPasswordHistory function while creating history: %s", err.Error())
+	}
+	history.Concurrency = 2
+
+	// add 5 passwords, only the last 3 are kept
+	for i := 0; i < 5; i++ {
+		hasher := newHasher
+		if i%2 == 0 {
+			hasher = oldHasher
+		}
+
+		encoded, err := hasher.Hash(fmt.Sprintf("password-%d", i))
+		if err != nil {
+			t.Fatalf("error in TestPasswordHistory function while hashing: %s", err.Error())
+		}
+
+		history.Add(encoded)
+	}
+
+	if len(history.Entries) != 3 {
+		t.Fatalf("error in TestPasswordHistory function: history has %d entries, want 3", len(history.Entries))
+	}
+
+	// the last 3 passwords are rejected, the older ones are allowed again
+	for i := 0; i < 5; i++ {
+		err := history.ValidatePassword(fmt.Sprintf("password-%d", i))
+		if reused := errors.Is(err, ErrPasswordReused); reused != (i >= 2) || !reused && err != nil {
+			t.Errorf("error in TestPasswordHistory function: password-%d reused=%v(%v)", i, reused, err)
+		}
+	}
+
+	// a password never used is not reported even though PBKDF1 can't derive the 32 byte keys of the PBKDF2 entries
+	if found, err := history.Contains("never used"); err != nil || found {
+		t.Errorf("error in TestPasswordHistory function: unused password gave %v, %v", found, err)
+	}
+
+	// unparseable entries are reported when nothing matched
+	history.Add("not an encoded password")
+	if _, err := history.Contains("something else"); err == nil {
+		t.Errorf("error in TestPasswordHistory function: broken entry was not reported")
+	}
+}
@@ -0,0 +1,130 @@
+package pbkdf
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"fmt"
+)
+
+// HKDFExtract is the extract step of HKDF
+// It is based on the RFC5869(https://datatracker.ietf.org/doc/html/rfc5869)
+// hash: the hash function to be used(can be any crypto.Hash)
+// salt: the optional salt(nil or empty is replaced by hLen zero bytes)
+// IKM: the input keying material
+// returns: PRK, the pseudorandom key of hLen bytes
+// when compliance mode is enabled the hash must be approved
+func HKDFExtract(hash crypto.Hash, salt, IKM []byte) ([]byte, error) {
+	// check the hash
+	if err := checkApprovedHash(hash); err != nil {
+		return nil, fmt.Errorf("error in HKDFExtract function: %w", err)
+	}
+
+	// PRK = HMAC-Hash(salt, IKM), a missing salt is a string of hLen zeros
+	if len(salt) == 0 {
+		salt = make([]byte, hash.Size())
+	}
+
+	mac := hmac.New(hash.New, salt)
+	mac.Write(IKM)
+
+	return mac.Sum(nil), nil
+}
+
+// HKDFExpand is the expand step of HKDF
+// It is based on the RFC5869(https://datatracker.ietf.org/doc/html/rfc5869)
+// hash: the hash function to be used(can be any crypto.Hash)
+// PRK: the pseudorandom key, at least hLen bytes(the output of HKDFExtract or of another KDF like PBKDF2)
+// info: the optional context and application specific information
+// L: the byte length of the output keying material, at most 255 * hLen
+// returns: OKM, the output keying material
+func HKDFExpand(hash crypto.Hash, PRK, info []byte, L int64) ([]byte, error) {
+	// check the hash
+	if err := checkApprovedHash(hash); err != nil {
+		return nil, fmt.Errorf("error in HKDFExpand function: %w", err)
+	}
+
+	// check the parameters
+	hLen := int64(hash.Size())
+	if int64(len(PRK)) < hLen {
+		return nil, fmt.Errorf("error in HKDFExpand function: PRK must be at least %d bytes", hLen)
+	}
+
+	if L < 0 || L > 255*hLen {
+		return nil, fmt.Errorf("error in HKDFExpand function: output length must be between 0 and %d", 255*hLen)
+	}
+
+	// T(i) = HMAC-Hash(PRK, T(i-1) | info | i), OKM is the first L bytes of T(1) | T(2) | ...
+	OKM := make([]byte, 0, L+hLen)
+	mac := hmac.New(hash.New, PRK)
+	var T []byte
+
+	for i := 1; int64(len(OKM)) < L; i++ {
+		mac.Reset()
+		mac.Write(T)
+		mac.Write(info)
+		mac.Write([]byte{byte(i)})
+		T = mac.Sum(T[:0])
+		OKM = append(OKM, T...)
+	}
+
+	return OKM[:L], nil
+}
+
+// HKDF is the one-shot HKDF, HKDFExtract followed by HKDFExpand
+// hash: the hash function to be used(can be any crypto.Hash)
+// IKM: the input keying material
+// salt: the optional salt
+// info: the optional context and application specific information
+// L: the byte length of the output keying material, at most 255 * hLen
+func HKDF(hash crypto.Hash, IKM, salt, info []byte, L int64) ([]byte, error) {
+	// extract
+	PRK, err := HKDFExtract(hash, salt, IKM)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in HKDF function while extracting: %w", err)
+	}
+
+	// expand
+	OKM, err := HKDFExpand(hash, PRK, info, L)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in HKDF function while expanding: %w", err)
+	}
+
+	return OKM, nil
+}
+
+// PBKDF2HKDF derives one key per info from a password: password -> PBKDF2 -> HKDF-Expand(info)
+// PBKDF2 runs once and its hLen byte output is the HKDF pseudorandom key, so extra keys are cheap
+// hash: the hash function of both PBKDF2 and HKDF
+// P: the password(as a byte slice)
+// S: the salt(as a byte slice)
+// c: the iteration count
+// dkLen: the byte length of every derived key, at most 255 * hLen
+// infos: the context of every key(for example "encryption" and "authentication"), one key is returned per info in the same order
+func PBKDF2HKDF(hash crypto.Hash, P []byte, S []byte, c int64, dkLen int64, infos ...[]byte) ([][]byte, error) {
+	// check the hash
+	if !hash.Available() {
+		return nil, fmt.Errorf("error in PBKDF2HKDF function: hash function %d is not available", hash)
+	}
+
+	// PRK = PBKDF2(P, S, c, hLen)
+	PRK, err := PBKDF2(hash, P, S, c, int64(hash.Size()))
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in PBKDF2HKDF function while running PBKDF2: %w", err)
+	}
+
+	// expand one key per info
+	keys := make([][]byte, len(infos))
+	for i, info := range infos {
+		if keys[i], err = HKDFExpand(hash, PRK, info, dkLen); err != nil {
+			return nil, fmt.Errorf("error in PBKDF2HKDF function while expanding: %w", err)
+		}
+	}
+
+	return keys, nil
+}
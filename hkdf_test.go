@@ -0,0 +1,48 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+// tests the password -> PBKDF2 -> HKDF-Expand pipeline and the HKDF limits
+func TestPBKDF2HKDF(t *testing.T) {
+	keys, err := PBKDF2HKDF(crypto.SHA256, []byte("password"), []byte("salt"), 1000, 32, []byte("encryption"), []byte("authentication"))
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("error in TestPBKDF2HKDF function while deriving keys: %v", err)
+	}
+
+	// the keys are HKDF-Expand of the PBKDF2 output
+	PRK, _ := PBKDF2(crypto.SHA256, []byte("password"), []byte("salt"), 1000, 32)
+	for i, info := range []string{"encryption", "authentication"} {
+		expected, _ := HKDFExpand(crypto.SHA256, PRK, []byte(info), 32)
+		if !bytes.Equal(keys[i], expected) {
+			t.Errorf("error in TestPBKDF2HKDF function: key %q is %x, want %x", info, keys[i], expected)
+		}
+	}
+
+	if bytes.Equal(keys[0], keys[1]) {
+		t.Errorf("error in TestPBKDF2HKDF function: keys with different infos are equal")
+	}
+
+	// the output is limited to 255 blocks and the PRK to at least one block
+	if _, err := HKDFExpand(crypto.SHA256, PRK, nil, 255*32+1); err == nil {
+		t.Errorf("error in TestPBKDF2HKDF function: output longer than 255 blocks was accepted")
+	}
+
+	if _, err := HKDFExpand(crypto.SHA256, PRK[:16], nil, 32); err == nil {
+		t.Errorf("error in TestPBKDF2HKDF function: short PRK was accepted")
+	}
+
+	// only approved hashes are accepted in compliance mode
+	if err := EnableComplianceMode(DefaultCompliancePolicy()); err != nil {
+		t.Fatalf("error in TestPBKDF2HKDF function while enabling compliance mode: %s", err.Error())
+	}
+	defer DisableComplianceMode()
+
+	if _, err := HKDF(crypto.MD5, []byte("key"), nil, nil, 16); !errors.Is(err, ErrNotCompliant) {
+		t.Errorf("error in TestPBKDF2HKDF function: MD5 was accepted in compliance mode(%v)", err)
+	}
+}
@@ -0,0 +1,310 @@
+package pbkdf
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// KBKDFMode selects the NIST SP 800-108 mode of iteration
+type KBKDFMode int
+
+// KBKDF modes of iteration
+const (
+	// KBKDFCounter computes K(i) = PRF(KI, [i] || fixed input)
+	KBKDFCounter KBKDFMode = iota
+	// KBKDFFeedback computes K(i) = PRF(KI, K(i-1) || [i] || fixed input) with K(0) = IV
+	KBKDFFeedback
+	// KBKDFDoublePipeline computes A(i) = PRF(KI, A(i-1)) with A(0) = fixed input and K(i) = PRF(KI, A(i) || [i] || fixed input)
+	KBKDFDoublePipeline
+)
+
+// String returns the name of the mode(counter, feedback or double-pipeline)
+func (m KBKDFMode) String() string {
+	switch m {
+	case KBKDFCounter:
+		return "counter"
+	case KBKDFFeedback:
+		return "feedback"
+	case KBKDFDoublePipeline:
+		return "double-pipeline"
+	}
+
+	return fmt.Sprintf("KBKDFMode(%d)", int(m))
+}
+
+// KBKDFCounterPosition selects where the counter [i] is placed in the PRF input
+// the names used by the NIST CAVP vectors are given for each position
+type KBKDFCounterPosition int
+
+// KBKDF counter positions
+const (
+	// KBKDFCounterBeforeFixed places the counter just before the fixed input(BEFORE_FIXED, AFTER_ITER in feedback and double-pipeline)
+	KBKDFCounterBeforeFixed KBKDFCounterPosition = iota
+	// KBKDFCounterAfterFixed places the counter after the fixed input(AFTER_FIXED)
+	KBKDFCounterAfterFixed
+	// KBKDFCounterMiddleFixed places the counter inside the fixed input, CounterOffset bytes from its start(MIDDLE_FIXED)
+	KBKDFCounterMiddleFixed
+	// KBKDFCounterBeforeIteration places the counter before K(i-1) or A(i), only in feedback and double-pipeline(BEFORE_ITER)
+	KBKDFCounterBeforeIteration
+)
+
+// default widths in bits of the counter and of the length field
+const (
+	// DefaultKBKDFCounterWidth is the counter width used when CounterWidth is zero
+	DefaultKBKDFCounterWidth int64 = 32
+	// DefaultKBKDFLengthWidth is the width of [L] used by KBKDFWithLabel when LengthWidth is zero
+	DefaultKBKDFLengthWidth int64 = 32
+)
+
+// KBKDFParameters holds the configuration of KBKDF and KBKDFWithLabel
+// the zero value with a Hash is counter mode with HMAC, a 32 bit counter before the fixed input
+// and the fixed input Label || 0x00 || Context || [L] with a 32 bit L
+type KBKDFParameters struct {
+	// Mode is the mode of iteration
+	Mode KBKDFMode
+	// Hash is the hash function of the HMAC PRF, it is used when PRF is nil
+	Hash crypto.Hash
+	// PRF is any other PRF(for example CMACPRF(aes.NewCipher)), it is rejected in compliance mode
+	PRF PRF
+	// CounterWidth is the width of the counter in bits(8, 16, 24 or 32), zero means DefaultKBKDFCounterWidth
+	CounterWidth int64
+	// OmitCounter removes the counter, only in feedback and double-pipeline modes
+	OmitCounter bool
+	// CounterPosition is the position of the counter in the PRF input
+	CounterPosition KBKDFCounterPosition
+	// CounterOffset is the offset in bytes of the counter in the fixed input when CounterPosition is KBKDFCounterMiddleFixed
+	CounterOffset int64
+	// IV is K(0) in feedback mode, it may be empty
+	IV []byte
+	// LengthWidth is the width of [L] in bits(8, 16, 24 or 32), zero means DefaultKBKDFLengthWidth
+	LengthWidth int64
+	// OmitLength removes [L] from the fixed input built by KBKDFWithLabel
+	OmitLength bool
+	// OmitSeparator removes the 0x00 byte between Label and Context in the fixed input built by KBKDFWithLabel
+	OmitSeparator bool
+}
+
+// KBKDF is the key-based key derivation function of NIST SP 800-108
+// It is based on the NIST SP 800-108r1(https://csrc.nist.gov/pubs/sp/800/108/r1/upd1/final)
+// KI: the key derivation key(for example a master key derived by PBKDF2)
+// fixedInput: the fixed input data as it is given to the PRF, KBKDFWithLabel builds it from a label and a context
+// dkLen: the byte length of the derived key KO
+// parameters: the mode, the PRF and the counter encoding
+// when compliance mode is enabled the PRF must be HMAC with an approved hash
+func KBKDF(KI []byte, fixedInput []byte, dkLen int64, parameters KBKDFParameters) ([]byte, error) {
+	// create the PRF keyed with KI
+	PRF, err := parameters.newPRF(KI)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in KBKDF function: %w", err)
+	}
+
+	// check the parameters
+	if parameters.Mode < KBKDFCounter || parameters.Mode > KBKDFDoublePipeline {
+		return nil, fmt.Errorf("error in KBKDF function: unknown mode %d", parameters.Mode)
+	}
+
+	h := int64(PRF.Size())
+	if h <= 0 {
+		return nil, errors.New("error in KBKDF function: PRF output size must be positive")
+	}
+
+	if dkLen < 0 {
+		return nil, errors.New("error in KBKDF function: derived key length must not be negative")
+	}
+
+	// number of PRF blocks, n = ceil(L / h)
+	n := (dkLen + h - 1) / h
+
+	counterWidth, err := parameters.counterWidth()
+	if err != nil {
+		return nil, fmt.Errorf("error in KBKDF function: %s", err.Error())
+	}
+
+	// n must fit in the counter and must not exceed 2^32 - 1
+	if n > int64(1)<<32-1 || (counterWidth > 0 && n > int64(1)<<counterWidth-1) {
+		return nil, errors.New("error in KBKDF function: derived key too long")
+	}
+
+	// check the counter position
+	switch parameters.CounterPosition {
+	case KBKDFCounterBeforeFixed, KBKDFCounterAfterFixed:
+	case KBKDFCounterMiddleFixed:
+		if parameters.CounterOffset < 0 || parameters.CounterOffset > int64(len(fixedInput)) {
+			return nil, fmt.Errorf("error in KBKDF function: counter offset must be between 0 and %d", len(fixedInput))
+		}
+	case KBKDFCounterBeforeIteration:
+		if parameters.Mode == KBKDFCounter {
+			return nil, errors.New("error in KBKDF function: counter mode has no iteration variable to place the counter before")
+		}
+	default:
+		return nil, fmt.Errorf("error in KBKDF function: unknown counter position %d", parameters.CounterPosition)
+	}
+
+	if parameters.Mode != KBKDFFeedback && len(parameters.IV) > 0 {
+		return nil, fmt.Errorf("error in KBKDF function: %s mode does not use an IV", parameters.Mode)
+	}
+
+	// split the fixed input around the counter
+	before, after := fixedInput, []byte(nil)
+	switch parameters.CounterPosition {
+	case KBKDFCounterBeforeFixed, KBKDFCounterBeforeIteration:
+		before, after = nil, fixedInput
+	case KBKDFCounterMiddleFixed:
+		before, after = fixedInput[:parameters.CounterOffset], fixedInput[parameters.CounterOffset:]
+	}
+
+	// KO = K(1) || K(2) || ... || K(n), truncated to dkLen bytes
+	KO := make([]byte, 0, n*h)
+
+	// the iteration variable, K(i-1) in feedback mode and A(i) in double-pipeline mode
+	var iteration []byte
+	switch parameters.Mode {
+	case KBKDFFeedback:
+		iteration = parameters.IV
+	case KBKDFDoublePipeline:
+		iteration = fixedInput
+	}
+
+	for i := int64(1); i <= n; i++ {
+		counter := ConvertUnsignedIntegerToByteSlice(uint64(i), int(counterWidth/8), false)
+
+		// A(i) = PRF(KI, A(i-1))
+		if parameters.Mode == KBKDFDoublePipeline {
+			PRF.Reset()
+			PRF.Write(iteration)
+			iteration = PRF.Sum(nil)
+		}
+
+		// K(i) = PRF(KI, iteration variable and fixed input with the counter in its position)
+		PRF.Reset()
+		if parameters.CounterPosition == KBKDFCounterBeforeIteration {
+			PRF.Write(counter)
+		}
+		PRF.Write(iteration)
+		PRF.Write(before)
+		if parameters.CounterPosition != KBKDFCounterBeforeIteration {
+			PRF.Write(counter)
+		}
+		PRF.Write(after)
+
+		start := len(KO)
+		KO = PRF.Sum(KO)
+
+		// check the PRF output, a custom PRF may not match its Size
+		if int64(len(KO)-start) != h {
+			return nil, fmt.Errorf("error in KBKDF function: PRF returned %d bytes instead of %d", len(KO)-start, h)
+		}
+
+		// K(i) is the next iteration variable in feedback mode
+		if parameters.Mode == KBKDFFeedback {
+			iteration = KO[start:]
+		}
+	}
+
+	return KO[:dkLen], nil
+}
+
+// KBKDFWithLabel is KBKDF with the fixed input encoded as Label || 0x00 || Context || [L]
+// [L] is the length of the derived key in bits as a big-endian integer of LengthWidth bits
+// the separator and [L] can be removed with OmitSeparator and OmitLength to match other encodings
+// KI: the key derivation key
+// label: the purpose of the derived key
+// context: the information related to the derived key(for example the identities of the parties and a nonce)
+// dkLen: the byte length of the derived key
+// parameters: the mode, the PRF, the counter encoding and the fixed input encoding
+func KBKDFWithLabel(KI, label, context []byte, dkLen int64, parameters KBKDFParameters) ([]byte, error) {
+	// build the fixed input
+	fixedInput := make([]byte, 0, len(label)+1+len(context)+4)
+	fixedInput = append(fixedInput, label...)
+	if !parameters.OmitSeparator {
+		fixedInput = append(fixedInput, 0x00)
+	}
+	fixedInput = append(fixedInput, context...)
+
+	if !parameters.OmitLength {
+		lengthWidth := parameters.LengthWidth
+		if lengthWidth == 0 {
+			lengthWidth = DefaultKBKDFLengthWidth
+		}
+
+		// check the width and that L fits in it
+		if !validKBKDFWidth(lengthWidth) {
+			return nil, errors.New("error in KBKDFWithLabel function: length width must be 8, 16, 24 or 32 bits")
+		}
+
+		if dkLen < 0 || dkLen*8 > int64(1)<<lengthWidth-1 {
+			return nil, fmt.Errorf("error in KBKDFWithLabel function: derived key length does not fit in %d bits", lengthWidth)
+		}
+
+		fixedInput = append(fixedInput, ConvertUnsignedIntegerToByteSlice(uint64(dkLen*8), int(lengthWidth/8), false)...)
+	}
+
+	// derive the key
+	KO, err := KBKDF(KI, fixedInput, dkLen, parameters)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in KBKDFWithLabel function: %w", err)
+	}
+
+	return KO, nil
+}
+
+// newPRF creates the PRF of the parameters keyed with KI
+func (p KBKDFParameters) newPRF(KI []byte) (hash.Hash, error) {
+	// a custom PRF can not be checked
+	if p.PRF != nil {
+		if currentCompliancePolicy() != nil {
+			return nil, fmt.Errorf("%w: the PRF can not be checked, use HMAC with an approved hash", ErrNotCompliant)
+		}
+
+		PRF := p.PRF(KI)
+		if PRF == nil {
+			return nil, errors.New("PRF rejected the key")
+		}
+
+		return PRF, nil
+	}
+
+	// HMAC with the hash
+	if err := checkApprovedHash(p.Hash); err != nil {
+		return nil, err
+	}
+
+	return hmac.New(p.Hash.New, KI), nil
+}
+
+// counterWidth returns the counter width in bits, zero when the counter is omitted
+func (p KBKDFParameters) counterWidth() (int64, error) {
+	if p.OmitCounter {
+		if p.Mode == KBKDFCounter {
+			return 0, errors.New("counter mode requires a counter")
+		}
+
+		if p.CounterPosition != KBKDFCounterBeforeFixed {
+			return 0, errors.New("the counter position must not be set when the counter is omitted")
+		}
+
+		return 0, nil
+	}
+
+	if p.CounterWidth == 0 {
+		return DefaultKBKDFCounterWidth, nil
+	}
+
+	if !validKBKDFWidth(p.CounterWidth) {
+		return 0, errors.New("counter width must be 8, 16, 24 or 32 bits")
+	}
+
+	return p.CounterWidth, nil
+}
+
+// validKBKDFWidth reports whether width is a whole number of bytes between 8 and 32 bits
+func validKBKDFWidth(width int64) bool {
+	return width == 8 || width == 16 || width == 24 || width == 32
+}
@@ -0,0 +1,95 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// tests the Label || 0x00 || Context || [L] encoding against the OpenSSL 3 KBKDF
+func TestKBKDFWithLabel(t *testing.T) {
+	KI, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	tests := []struct {
+		name       string
+		parameters KBKDFParameters
+		expected   string
+	}{
+		{"counter HMAC-SHA256", KBKDFParameters{Hash: crypto.SHA256}, "46cbcad197c3f1a8366abd1f4756c99f2d1cd843e21e00f4d5b80bcde9e4789ce25088a99c51c15bfe88"},
+		{"counter CMAC-AES128", KBKDFParameters{PRF: CMACPRF(aes.NewCipher)}, "1f1d75c243366a6ca7f2aebaa33a76bb4f35af82d949a29ee20637d72dc7d0a72c42363090d0023211d5"},
+		{"counter without separator and L", KBKDFParameters{Hash: crypto.SHA256, OmitSeparator: true, OmitLength: true}, "df41a71bd88e25170eb4a51f4c998df069a9de973b857f320bf658a2497c2eb443d7bd1274af0687c5f7"},
+		{"feedback HMAC-SHA256", KBKDFParameters{Mode: KBKDFFeedback, Hash: crypto.SHA256, IV: bytes.Repeat(KI, 2)}, "d2c683e719d3bda4a71b3034be9a62dd566e04a81a0b64dc4b4fc58ffc7bbcd46d2fc190e9c6a9b0d7d7"},
+		{"feedback CMAC-AES128", KBKDFParameters{Mode: KBKDFFeedback, PRF: CMACPRF(aes.NewCipher), IV: KI}, "7cfbae7a2837a0cbc7579bdc43041b9d83d8d3c752bdcf50048ab10d6b2e60afa70c58b41e125a5eacc5"},
+	}
+
+	for _, test := range tests {
+		KO, err := KBKDFWithLabel(KI, []byte("label"), []byte("context"), 42, test.parameters)
+		if err != nil {
+			t.Errorf("error in TestKBKDFWithLabel function: %s failed: %s", test.name, err.Error())
+		} else if hex.EncodeToString(KO) != test.expected {
+			t.Errorf("error in TestKBKDFWithLabel function: %s gave %x, want %s", test.name, KO, test.expected)
+		}
+	}
+}
+
+// tests the KBKDF parameter checks and compliance mode
+func TestKBKDFParameters(t *testing.T) {
+	KI := make([]byte, 16)
+
+	invalid := map[string]KBKDFParameters{
+		"no counter in counter mode":          {Hash: crypto.SHA256, OmitCounter: true},
+		"counter width":                       {Hash: crypto.SHA256, CounterWidth: 12},
+		"before iteration in counter mode":    {Hash: crypto.SHA256, CounterPosition: KBKDFCounterBeforeIteration},
+		"counter offset":                      {Hash: crypto.SHA256, CounterPosition: KBKDFCounterMiddleFixed, CounterOffset: 9},
+		"IV in double-pipeline mode":          {Mode: KBKDFDoublePipeline, Hash: crypto.SHA256, IV: KI},
+		"unknown mode":                        {Mode: KBKDFMode(3), Hash: crypto.SHA256},
+		"key rejected by the PRF":             {PRF: CMACPRF(aes.NewCipher)},
+		"output longer than an 8 bit counter": {Hash: crypto.SHA256, CounterWidth: 8},
+	}
+
+	for name, parameters := range invalid {
+		dkLen := int64(32)
+		key := KI
+		switch name {
+		case "output longer than an 8 bit counter":
+			dkLen = 256 * 32
+		case "key rejected by the PRF":
+			key = KI[:10]
+		}
+
+		if _, err := KBKDF(key, []byte("fixed"), dkLen, parameters); err == nil {
+			t.Errorf("error in TestKBKDFParameters function: %s was accepted", name)
+		}
+	}
+
+	// an 8 bit counter allows 255 blocks
+	if KO, err := KBKDF(KI, []byte("fixed"), 255*32, KBKDFParameters{Hash: crypto.SHA256, CounterWidth: 8}); err != nil || len(KO) != 255*32 {
+		t.Errorf("error in TestKBKDFParameters function: 255 blocks with an 8 bit counter failed(%v)", err)
+	}
+
+	// L must fit in its width
+	if _, err := KBKDFWithLabel(KI, nil, nil, 32, KBKDFParameters{Hash: crypto.SHA256, LengthWidth: 8}); err == nil {
+		t.Errorf("error in TestKBKDFParameters function: 256 bits were encoded in an 8 bit L")
+	}
+
+	// only HMAC with an approved hash is accepted in compliance mode
+	if err := EnableComplianceMode(DefaultCompliancePolicy()); err != nil {
+		t.Fatalf("error in TestKBKDFParameters function while enabling compliance mode: %s", err.Error())
+	}
+	defer DisableComplianceMode()
+
+	if _, err := KBKDF(KI, nil, 32, KBKDFParameters{PRF: CMACPRF(aes.NewCipher)}); !errors.Is(err, ErrNotCompliant) {
+		t.Errorf("error in TestKBKDFParameters function: a custom PRF was accepted in compliance mode(%v)", err)
+	}
+
+	if _, err := KBKDF(KI, nil, 32, KBKDFParameters{Hash: crypto.MD5}); !errors.Is(err, ErrNotCompliant) {
+		t.Errorf("error in TestKBKDFParameters function: MD5 was accepted in compliance mode(%v)", err)
+	}
+
+	if _, err := KBKDF(KI, nil, 32, KBKDFParameters{Hash: crypto.SHA256}); err != nil {
+		t.Errorf("error in TestKBKDFParameters function: HMAC-SHA256 was refused in compliance mode: %s", err.Error())
+	}
+}
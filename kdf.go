@@ -0,0 +1,502 @@
+package pbkdf
+
+import (
+	"crypto"
+	_ "crypto/md5"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ErrUnknownKDF is returned when a name is not in the KDF registry
+var ErrUnknownKDF = errors.New("unknown key derivation function")
+
+// KDFParam is a named integer parameter of a KDF, the name is the one used in PHC strings
+type KDFParam struct {
+	Name  string
+	Value int64
+}
+
+// KDF is a password-based key derivation function together with its parameters
+// unlike the PBKDF function type it can describe any parameter set(iterations, memory, parallelism, ...)
+// the parameter structs of this package(PBKDF2Parameters, ScryptParameters, Argon2Parameters, ...) implement it
+type KDF interface {
+	// Name returns the registry name of the algorithm(for example pbkdf2-sha256), it is the PHC identifier
+	Name() string
+	// Params returns the parameters in the order they are written in PHC strings
+	// a parameter named v is written as the PHC version
+	Params() []KDFParam
+	// DeriveKey derives a key of keyLength bytes from the password and salt
+	DeriveKey(password, salt []byte, keyLength int64) ([]byte, error)
+}
+
+// KDFFactory creates a KDF from the parameters decoded from a PHC string
+// it must reject missing, unknown and invalid parameters
+type KDFFactory func(params map[string]int64) (KDF, error)
+
+// registry of KDF factories by name
+var kdfRegistry = struct {
+	sync.RWMutex
+	factories map[string]KDFFactory
+}{factories: make(map[string]KDFFactory)}
+
+// RegisterKDF adds an algorithm to the KDF registry so strings using its name can be verified
+// third-party packages usually call it from an init function
+// The name parameter must be a valid PHC identifier(lowercase letters, digits and -, at most 32 characters)
+// The factory parameter creates the KDF from its parameters
+// registering a name twice is an error
+func RegisterKDF(name string, factory KDFFactory) error {
+	// check the parameters
+	if !isPHCName(name) {
+		return fmt.Errorf("error in RegisterKDF function: invalid name %q", name)
+	}
+
+	if factory == nil {
+		return errors.New("error in RegisterKDF function: factory must not be nil")
+	}
+
+	kdfRegistry.Lock()
+	defer kdfRegistry.Unlock()
+
+	// check the name is free
+	if _, ok := kdfRegistry.factories[name]; ok {
+		return fmt.Errorf("error in RegisterKDF function: %q is already registered", name)
+	}
+
+	kdfRegistry.factories[name] = factory
+	return nil
+}
+
+// NewKDF creates the registered KDF name with the given parameters
+// the error wraps ErrUnknownKDF if the name is not registered
+func NewKDF(name string, params map[string]int64) (KDF, error) {
+	// get the factory
+	kdfRegistry.RLock()
+	factory, ok := kdfRegistry.factories[name]
+	kdfRegistry.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("error in NewKDF function: %w %q", ErrUnknownKDF, name)
+	}
+
+	// create the KDF
+	kdf, err := factory(params)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in NewKDF function while creating %s: %s", name, err.Error())
+	}
+
+	return kdf, nil
+}
+
+// RegisteredKDFs returns the sorted names of the registered algorithms
+func RegisteredKDFs() []string {
+	kdfRegistry.RLock()
+	defer kdfRegistry.RUnlock()
+
+	names := make([]string, 0, len(kdfRegistry.factories))
+	for name := range kdfRegistry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// hash functions usable in registry names, in crypto.Hash order
+var kdfHashNames = []struct {
+	hash crypto.Hash
+	name string
+}{
+	{crypto.MD5, "md5"},
+	{crypto.SHA1, "sha1"},
+	{crypto.SHA224, "sha224"},
+	{crypto.SHA256, "sha256"},
+	{crypto.SHA384, "sha384"},
+	{crypto.SHA512, "sha512"},
+	{crypto.SHA3_224, "sha3-224"},
+	{crypto.SHA3_256, "sha3-256"},
+	{crypto.SHA3_384, "sha3-384"},
+	{crypto.SHA3_512, "sha3-512"},
+	{crypto.SHA512_224, "sha512-224"},
+	{crypto.SHA512_256, "sha512-256"},
+	{crypto.BLAKE2b_256, "blake2b-256"},
+	{crypto.BLAKE2b_384, "blake2b-384"},
+	{crypto.BLAKE2b_512, "blake2b-512"},
+}
+
+// kdfHashName returns the name of a hash function in registry names
+func kdfHashName(hash crypto.Hash) string {
+	for _, h := range kdfHashNames {
+		if h.hash == hash {
+			return h.name
+		}
+	}
+
+	return fmt.Sprintf("hash%d", int(hash))
+}
+
+// register the algorithms of this package
+// PBKDF1 only with the hashes allowed by RFC8018, PBKDF2 and PBKDF2Legacy with every hash except MD5
+func init() {
+	for _, h := range kdfHashNames {
+		hash := h.hash
+
+		if hash == crypto.MD5 || hash == crypto.SHA1 {
+			RegisterKDF("pbkdf1-"+h.name, func(params map[string]int64) (KDF, error) {
+				values, err := kdfParams(params, "i")
+				return PBKDF1Parameters{Hash: hash, Iterations: values[0]}, err
+			})
+		}
+
+		if hash != crypto.MD5 {
+			RegisterKDF("pbkdf2-"+h.name, func(params map[string]int64) (KDF, error) {
+				values, err := kdfParams(params, "i")
+				return PBKDF2Parameters{Hash: hash, Iterations: values[0]}, err
+			})
+
+			RegisterKDF("pbkdf2-legacy-"+h.name, func(params map[string]int64) (KDF, error) {
+				values, err := kdfParams(params, "i")
+				return PBKDF2LegacyParameters{Hash: hash, Iterations: values[0]}, err
+			})
+		}
+	}
+
+	RegisterKDF("scrypt", func(params map[string]int64) (KDF, error) {
+		values, err := kdfParams(params, "ln", "r", "p")
+		if err == nil && (values[0] < 1 || values[0] > 62) {
+			err = errors.New("ln must be between 1 and 62")
+		}
+
+		return ScryptParameters{N: int64(1) << (values[0] & 63), R: values[1], P: values[2]}, err
+	})
+
+	for _, variant := range []Argon2Variant{Argon2d, Argon2i, Argon2id} {
+		variant := variant
+
+		RegisterKDF(variant.String(), func(params map[string]int64) (KDF, error) {
+			values, err := kdfParams(params, "v", "m", "t", "p")
+			if err == nil && values[0] != Argon2Version {
+				err = fmt.Errorf("only version %d is supported", Argon2Version)
+			}
+
+			return Argon2Parameters{Variant: variant, Memory: values[1], Passes: values[2], Parallelism: values[3]}, err
+		})
+	}
+}
+
+// kdfParams returns the values of the named parameters
+// every name must be present and no other parameter is allowed, values must be positive
+func kdfParams(params map[string]int64, names ...string) ([]int64, error) {
+	values := make([]int64, len(names))
+
+	// check the count
+	if len(params) != len(names) {
+		return values, fmt.Errorf("parameters must be %v", names)
+	}
+
+	// get the values
+	for i, name := range names {
+		value, ok := params[name]
+		if !ok {
+			return values, fmt.Errorf("missing parameter %s", name)
+		}
+
+		if value < 1 {
+			return values, fmt.Errorf("parameter %s must be positive", name)
+		}
+
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// PBKDF2Parameters are the parameters of PBKDF2, registered as pbkdf2-<hash>(pbkdf2-sha256, pbkdf2-sha512, ...)
+type PBKDF2Parameters struct {
+	Hash       crypto.Hash
+	Iterations int64
+}
+
+// Name returns pbkdf2-<hash>
+func (p PBKDF2Parameters) Name() string {
+	return "pbkdf2-" + kdfHashName(p.Hash)
+}
+
+// Params returns the iteration count as i
+func (p PBKDF2Parameters) Params() []KDFParam {
+	return []KDFParam{{"i", p.Iterations}}
+}
+
+// DeriveKey calls PBKDF2
+func (p PBKDF2Parameters) DeriveKey(password, salt []byte, keyLength int64) ([]byte, error) {
+	return PBKDF2(p.Hash, password, salt, p.Iterations, keyLength)
+}
+
+// PBKDF2LegacyParameters are the parameters of PBKDF2Legacy, registered as pbkdf2-legacy-<hash>
+type PBKDF2LegacyParameters struct {
+	Hash       crypto.Hash
+	Iterations int64
+}
+
+// Name returns pbkdf2-legacy-<hash>
+func (p PBKDF2LegacyParameters) Name() string {
+	return "pbkdf2-legacy-" + kdfHashName(p.Hash)
+}
+
+// Params returns the iteration count as i
+func (p PBKDF2LegacyParameters) Params() []KDFParam {
+	return []KDFParam{{"i", p.Iterations}}
+}
+
+// DeriveKey calls PBKDF2Legacy
+func (p PBKDF2LegacyParameters) DeriveKey(password, salt []byte, keyLength int64) ([]byte, error) {
+	return PBKDF2Legacy(p.Hash, password, salt, p.Iterations, keyLength)
+}
+
+// PBKDF1Parameters are the parameters of PBKDF1, registered as pbkdf1-md5 and pbkdf1-sha1
+type PBKDF1Parameters struct {
+	Hash       crypto.Hash
+	Iterations int64
+}
+
+// Name returns pbkdf1-<hash>
+func (p PBKDF1Parameters) Name() string {
+	return "pbkdf1-" + kdfHashName(p.Hash)
+}
+
+// Params returns the iteration count as i
+func (p PBKDF1Parameters) Params() []KDFParam {
+	return []KDFParam{{"i", p.Iterations}}
+}
+
+// DeriveKey calls PBKDF1
+func (p PBKDF1Parameters) DeriveKey(password, salt []byte, keyLength int64) ([]byte, error) {
+	return PBKDF1(p.Hash, password, salt, p.Iterations, keyLength)
+}
+
+// ScryptParameters are the parameters of scrypt, registered as scrypt
+type ScryptParameters struct {
+	N, R, P int64
+}
+
+// Name returns scrypt
+func (p ScryptParameters) Name() string {
+	return "scrypt"
+}
+
+// Params returns log2(N) as ln, r and p
+func (p ScryptParameters) Params() []KDFParam {
+	return []KDFParam{{"ln", int64(bits.Len64(uint64(p.N)) - 1)}, {"r", p.R}, {"p", p.P}}
+}
+
+// DeriveKey calls Scrypt
+func (p ScryptParameters) DeriveKey(password, salt []byte, keyLength int64) ([]byte, error) {
+	return Scrypt(password, salt, p.N, p.R, p.P, keyLength)
+}
+
+// Argon2Parameters are the parameters of Argon2, registered as argon2d, argon2i and argon2id
+type Argon2Parameters struct {
+	Variant     Argon2Variant
+	Passes      int64
+	Memory      int64
+	Parallelism int64
+}
+
+// Name returns the name of the variant
+func (p Argon2Parameters) Name() string {
+	return p.Variant.String()
+}
+
+// Params returns the version as v, the memory as m, the passes as t and the parallelism as p
+func (p Argon2Parameters) Params() []KDFParam {
+	return []KDFParam{{"v", Argon2Version}, {"m", p.Memory}, {"t", p.Passes}, {"p", p.Parallelism}}
+}
+
+// DeriveKey calls Argon2
+func (p Argon2Parameters) DeriveKey(password, salt []byte, keyLength int64) ([]byte, error) {
+	return Argon2(p.Variant, password, salt, p.Passes, p.Memory, p.Parallelism, keyLength)
+}
+
+// EncodePasswordKDF encodes a password with any KDF
+// The encoded password is returned in the PHC string format: $name$param=value,...$salt$hash(salt and hash are base64 encoded without padding)
+// The kdf parameter is the algorithm and its parameters, its name must be registered
+// The saltLength parameter is the length of the salt in bytes
+// The keyLength parameter is the length of the derived key in bytes
+func EncodePasswordKDF(kdf KDF, password string, saltLength, keyLength int64) (string, error) {
+	// check the KDF can be resolved when the password is verified
+	if err := checkKDF(kdf); err != nil {
+		return "", fmt.Errorf("error in EncodePasswordKDF function: %s", err.Error())
+	}
+
+	// generate a salt
+	saltAsBytes, err := GenerateRandomSequence(int(saltLength))
+
+	// check if an error occurred
+	if err != nil {
+		return "", fmt.Errorf("error in EncodePasswordKDF function while generating salt: %s", err.Error())
+	}
+
+	// encode the password
+	encodedPassword, err := kdf.DeriveKey([]byte(password), saltAsBytes, keyLength)
+
+	// check if an error occurred
+	if err != nil {
+		return "", fmt.Errorf("error in EncodePasswordKDF function while encoding password: %s", err.Error())
+	}
+
+	// return the encoded password
+	return GenerateKDFPasswordString(kdf, saltAsBytes, encodedPassword), nil
+}
+
+// VerifyPasswordKDF checks if a password matches a PHC string of any registered algorithm
+// the algorithm and its parameters are read from the string, the caller doesn't pass them
+// The password parameter is the password to be checked
+// The encodedPassword parameter is the encoded password
+// the error wraps ErrUnknownKDF if the algorithm is not registered
+func VerifyPasswordKDF(password, encodedPassword string) (bool, error) {
+	// get the password parameters
+	kdf, saltAsBytes, passwordHash, err := GetKDFParametersFromString(encodedPassword)
+
+	// check if an error occurred
+	if err != nil {
+		return false, fmt.Errorf("error in VerifyPasswordKDF function while getting password parameters: %w", err)
+	}
+
+	// encode the password
+	passwordHash2, err := kdf.DeriveKey([]byte(password), saltAsBytes, int64(len(passwordHash)))
+
+	// check if an error occurred
+	if err != nil {
+		return false, fmt.Errorf("error in VerifyPasswordKDF function while encoding password: %s", err.Error())
+	}
+
+	// compare the hashes in constant time
+	return subtle.ConstantTimeCompare(passwordHash, passwordHash2) == 1, nil
+}
+
+// GenerateKDFPasswordString generates a PHC string from a KDF, a salt and a derived key
+// The kdf parameter is the algorithm and its parameters
+// The salt parameter is the salt as a byte slice
+// The encodedPassword parameter is the derived key as a byte slice
+func GenerateKDFPasswordString(kdf KDF, salt []byte, encodedPassword []byte) string {
+	phc := &phcString{
+		id:   kdf.Name(),
+		salt: append([]byte{}, salt...),
+		hash: append([]byte{}, encodedPassword...),
+	}
+
+	// a leading v parameter is the version
+	params := kdf.Params()
+	if len(params) > 0 && params[0].Name == "v" {
+		phc.hasVersion, phc.version = true, params[0].Value
+		params = params[1:]
+	}
+
+	for _, param := range params {
+		phc.params = append(phc.params, phcParam{param.Name, strconv.FormatInt(param.Value, 10)})
+	}
+
+	return phc.String()
+}
+
+// GetKDFParametersFromString resolves a PHC string to a registered KDF
+// the string must be in the format: $name$param=value,...$salt$hash and use the parameters of the algorithm in their order
+// the KDF, salt and derived key are returned in this order
+// the error wraps ErrUnknownKDF if the algorithm is not registered
+func GetKDFParametersFromString(encodedPassword string) (KDF, []byte, []byte, error) {
+	// parse the PHC string
+	phc, err := parsePHCString(encodedPassword)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error in GetKDFParametersFromString function: %s", err.Error())
+	}
+
+	// check the format, an empty hash would match every password
+	if phc.salt == nil || len(phc.hash) == 0 {
+		return nil, nil, nil, errors.New("error in GetKDFParametersFromString function: encodedPassword must be in the format $name$param=value,...$salt$hash with a non-empty hash")
+	}
+
+	// decode the parameters, the version is the parameter v
+	var parsed []KDFParam
+	if phc.hasVersion {
+		parsed = append(parsed, KDFParam{"v", phc.version})
+	}
+
+	for _, param := range phc.params {
+		value, err := phc.intParam(param.name)
+
+		// check if an error occurred
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error in GetKDFParametersFromString function: %s", err.Error())
+		}
+
+		parsed = append(parsed, KDFParam{param.name, value})
+	}
+
+	params := make(map[string]int64, len(parsed))
+	for _, param := range parsed {
+		params[param.Name] = param.Value
+	}
+
+	// create the KDF
+	kdf, err := NewKDF(phc.id, params)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error in GetKDFParametersFromString function: %w", err)
+	}
+
+	// the parameters must be written like the KDF writes them so every string has a single form
+	if !equalKDFParams(kdf.Params(), parsed) {
+		return nil, nil, nil, fmt.Errorf("error in GetKDFParametersFromString function: parameters of %s are not in canonical form", phc.id)
+	}
+
+	return kdf, phc.salt, phc.hash, nil
+}
+
+// checkKDF checks that the name and parameters of a KDF resolve to an equal KDF through the registry
+func checkKDF(kdf KDF) error {
+	if kdf == nil {
+		return errors.New("kdf must not be nil")
+	}
+
+	// resolve the KDF
+	params := make(map[string]int64)
+	for _, param := range kdf.Params() {
+		params[param.Name] = param.Value
+	}
+
+	resolved, err := NewKDF(kdf.Name(), params)
+	if err != nil {
+		return err
+	}
+
+	// compare the parameters
+	if !equalKDFParams(resolved.Params(), kdf.Params()) {
+		return fmt.Errorf("parameters of %s can not be stored in a PHC string", kdf.Name())
+	}
+
+	return nil
+}
+
+// equalKDFParams reports whether two parameter lists are equal
+func equalKDFParams(a, b []KDFParam) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,138 @@
+package pbkdf
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// testKDF is a third-party KDF used to test the registry, it is n rounds of SHA-256 over salt and password
+type testKDF struct {
+	rounds int64
+}
+
+func (k testKDF) Name() string { return "test-sha256" }
+
+func (k testKDF) Params() []KDFParam { return []KDFParam{{"n", k.rounds}} }
+
+func (k testKDF) DeriveKey(password, salt []byte, keyLength int64) ([]byte, error) {
+	sum := sha256.Sum256(append(append([]byte{}, salt...), password...))
+	for i := int64(1); i < k.rounds; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+
+	if keyLength < 0 || keyLength > int64(len(sum)) {
+		return nil, fmt.Errorf("test-sha256 derives keys of at most %d bytes", len(sum))
+	}
+
+	return sum[:keyLength], nil
+}
+
+// tests the KDF registry with the algorithms of this package and a third-party algorithm
+func TestKDFRegistry(t *testing.T) {
+	kdfs := []KDF{
+		PBKDF2Parameters{Hash: crypto.SHA256, Iterations: 1000},
+		PBKDF2Parameters{Hash: crypto.SHA512, Iterations: 1000},
+		PBKDF2LegacyParameters{Hash: crypto.SHA256, Iterations: 1000},
+		PBKDF1Parameters{Hash: crypto.SHA1, Iterations: 1000},
+		ScryptParameters{N: 1024, R: 8, P: 1},
+		Argon2Parameters{Variant: Argon2id, Passes: 1, Memory: 64, Parallelism: 1},
+	}
+
+	for _, kdf := range kdfs {
+		encoded, err := EncodePasswordKDF(kdf, "password", 16, 16)
+		if err != nil {
+			t.Fatalf("error in TestKDFRegistry function while encoding password with %s: %s", kdf.Name(), err.Error())
+		}
+
+		// the string resolves to the same algorithm and parameters
+		resolved, _, _, err := GetKDFParametersFromString(encoded)
+		if err != nil || resolved != kdf {
+			t.Errorf("error in TestKDFRegistry function: %q resolved to %v(%v)", encoded, resolved, err)
+		}
+
+		if valid, err := VerifyPasswordKDF("password", encoded); err != nil || !valid {
+			t.Errorf("error in TestKDFRegistry function: %q is not valid(%v)", encoded, err)
+		}
+
+		if valid, err := VerifyPasswordKDF("Password", encoded); err != nil || valid {
+			t.Errorf("error in TestKDFRegistry function: wrong password is valid for %q(%v)", encoded, err)
+		}
+	}
+
+	// strings of the algorithm specific encoders resolve too
+	scrypt, _ := EncodePasswordScrypt("password", 16, 16, 1, 1, 32)
+	argon2, _ := EncodePasswordArgon2(Argon2i, "password", 16, 1, 64, 1, 32)
+	for _, encoded := range []string{scrypt, argon2} {
+		if valid, err := VerifyPasswordKDF("password", encoded); err != nil || !valid {
+			t.Errorf("error in TestKDFRegistry function: %q is not valid(%v)", encoded, err)
+		}
+	}
+
+	// unknown algorithms and parameters are refused
+	if _, err := VerifyPasswordKDF("password", "$md4$i=1$c2FsdA$a2V5"); !errors.Is(err, ErrUnknownKDF) {
+		t.Errorf("error in TestKDFRegistry function: unknown algorithm error is %v", err)
+	}
+
+	for _, encoded := range []string{
+		"$pbkdf2-sha256$c2FsdA$a2V5",
+		"$pbkdf2-sha256$i=1,j=2$c2FsdA$a2V5",
+		"$pbkdf2-sha256$i=0$c2FsdA$a2V5",
+		"$scrypt$r=8,ln=4,p=1$c2FsdA$a2V5",
+		"$argon2id$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$pbkdf2-sha256$i=1$c2FsdA$",
+		"$pbkdf2-sha256$i=1$c2FsdA",
+	} {
+		if _, _, _, err := GetKDFParametersFromString(encoded); err == nil || errors.Is(err, ErrUnknownKDF) {
+			t.Errorf("error in TestKDFRegistry function: %q gave %v", encoded, err)
+		}
+	}
+
+	// a string with an empty hash does not verify any password
+	if valid, err := VerifyPasswordKDF("anything", "$pbkdf2-sha256$i=1$c2FsdA$"); err == nil || valid {
+		t.Errorf("error in TestKDFRegistry function: empty hash gave %v(%v)", valid, err)
+	}
+
+	// third-party algorithms are registered once, the registry outlives a test run with -count
+	factory := func(params map[string]int64) (KDF, error) {
+		values, err := kdfParams(params, "n")
+		return testKDF{rounds: values[0]}, err
+	}
+
+	if _, err := NewKDF("test-sha256", map[string]int64{"n": 1}); errors.Is(err, ErrUnknownKDF) {
+		if err := RegisterKDF("test-sha256", factory); err != nil {
+			t.Fatalf("error in TestKDFRegistry function while registering: %s", err.Error())
+		}
+	}
+	if err := RegisterKDF("test-sha256", factory); err == nil {
+		t.Errorf("error in TestKDFRegistry function: duplicate name was registered")
+	}
+	if err := RegisterKDF("Test_SHA256", factory); err == nil {
+		t.Errorf("error in TestKDFRegistry function: invalid name was registered")
+	}
+
+	hasher, err := NewHasher(WithAlgorithm(testKDF{rounds: 3}))
+	if err != nil {
+		t.Fatalf("error in TestKDFRegistry function while creating hasher: %s", err.Error())
+	}
+
+	encoded, err := hasher.Hash("password")
+	if err != nil {
+		t.Fatalf("error in TestKDFRegistry function while hashing password: %s", err.Error())
+	}
+
+	if valid, err := VerifyPasswordKDF("password", encoded); err != nil || !valid {
+		t.Errorf("error in TestKDFRegistry function: %q is not valid(%v)", encoded, err)
+	}
+
+	if _, err := EncodePasswordKDF(testKDF{rounds: 3}, "password", 16, 64); err == nil {
+		t.Errorf("error in TestKDFRegistry function: test-sha256 derived a 64 byte key")
+	}
+
+	// a KDF that is not registered can not be used by a Hasher
+	if _, err := NewHasher(WithAlgorithm(PBKDF1Parameters{Hash: crypto.SHA512, Iterations: 1})); err == nil {
+		t.Errorf("error in TestKDFRegistry function: unregistered KDF was accepted")
+	}
+}
@@ -0,0 +1,443 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// key container constants
+const (
+	// DefaultKeyContainerSlots is the default number of slots, the same as LUKS1
+	DefaultKeyContainerSlots = 8
+	// DefaultAFStripes is the default number of anti-forensic stripes, the same as LUKS
+	DefaultAFStripes = 4000
+	// KeyContainerVersion is the version of the binary format written by MarshalBinary
+	KeyContainerVersion = 1
+	// MaxKeySlotNameLength is the maximum length of a slot name in bytes
+	MaxKeySlotNameLength = 64
+	// maximum salt length of a slot in bytes
+	keyContainerMaxSaltLength = 64
+	// iteration count of the master key digest, the master key is random so it does not need stretching
+	keyContainerDigestIterations = 1000
+	// length of the master key digest and of its salt
+	keyContainerDigestLength = 32
+	// hash function of the anti-forensic splitter and of the master key digest
+	keyContainerHash = crypto.SHA256
+	// room for the nonce and tag of KeyWrapAESGCM and the padding and integrity value of KeyWrapAESKWP
+	keyContainerWrapOverhead = 28
+)
+
+// keyContainerMagic starts every binary key container
+var keyContainerMagic = []byte("PBKC")
+
+// KeyContainerOptions holds the optional parameters of NewKeyContainer, zero values select the defaults
+type KeyContainerOptions struct {
+	// Slots is the number of slots, at most 255, zero means DefaultKeyContainerSlots
+	Slots int
+	// Stripes is the number of anti-forensic stripes the master key is split into, zero means DefaultAFStripes
+	Stripes int
+}
+
+// KeySlotInfo describes an active slot of a KeyContainer
+type KeySlotInfo struct {
+	// Index is the position of the slot
+	Index int
+	// Name is the name given to AddSlot(for example "admin" or "recovery")
+	Name string
+	// Hash is the PBKDF2 hash function
+	Hash crypto.Hash
+	// Iterations is the PBKDF2 iteration count
+	Iterations int64
+	// Wrap is the key wrap algorithm
+	Wrap KeyWrapAlgorithm
+}
+
+// KeyContainer holds up to a fixed number of key slots that each unlock the same master key
+// It follows the design of LUKS1: every slot wraps the master key split with AFSplit under its own password and PBKDF2 parameters,
+// and a PBKDF2 digest of the master key recognizes the right one
+// the binary format gives every slot an area of fixed size at a fixed offset, so rewriting the file after RemoveSlot
+// overwrites the removed slot with random bytes, and losing part of the split material is enough to destroy the master key
+// a KeyContainer must not be used by several goroutines at once
+type KeyContainer struct {
+	stripes      int
+	keyLength    int
+	digestSalt   []byte
+	digest       []byte
+	names        []string
+	slots        []*KeySlot
+	slotAreaSize int
+}
+
+// NewKeyContainer creates a container for a master key, it has no slots yet
+// masterKey: the master key, usually a random key from GenerateRandomSequence
+// options: the optional parameters
+// AddSlot must be called at least once before the container is stored
+func NewKeyContainer(masterKey []byte, options KeyContainerOptions) (*KeyContainer, error) {
+	// apply the defaults
+	if options.Slots == 0 {
+		options.Slots = DefaultKeyContainerSlots
+	}
+
+	if options.Stripes == 0 {
+		options.Stripes = DefaultAFStripes
+	}
+
+	// check the parameters
+	switch {
+	case options.Slots < 0 || options.Slots > 255:
+		return nil, errors.New("error in NewKeyContainer function: slots must be between 1 and 255")
+	case options.Stripes < 0 || int64(options.Stripes) > int64(^uint32(0)):
+		return nil, errors.New("error in NewKeyContainer function: stripes must fit in 32 bits")
+	case len(masterKey) == 0 || len(masterKey) > 1024:
+		return nil, errors.New("error in NewKeyContainer function: master key must be from 1 to 1024 bytes long")
+	}
+
+	// digest of the master key
+	digestSalt, err := GenerateRandomSequence(keyContainerDigestLength)
+	if err != nil {
+		return nil, fmt.Errorf("error in NewKeyContainer function while generating salt: %s", err.Error())
+	}
+
+	c := &KeyContainer{
+		stripes:    options.Stripes,
+		keyLength:  len(masterKey),
+		digestSalt: digestSalt,
+		names:      make([]string, options.Slots),
+		slots:      make([]*KeySlot, options.Slots),
+	}
+	c.slotAreaSize = c.areaSize()
+
+	if c.digest, err = c.masterKeyDigest(masterKey); err != nil {
+		return nil, fmt.Errorf("error in NewKeyContainer function: %w", err)
+	}
+
+	return c, nil
+}
+
+// AddSlot adds a slot unlocking the master key with password and returns its index, the first free one
+// masterKey: the master key, returned by Unlock or given to NewKeyContainer, it is checked against the digest
+// name: a name for the slot(for example "user", "recovery" or "admin"), at most MaxKeySlotNameLength bytes
+// password: the password of the slot, recovery keys are passwords too
+// options: the PBKDF2 parameters and key wrap algorithm of the slot, they can differ between slots
+func (c *KeyContainer) AddSlot(masterKey []byte, name, password string, options KeySlotOptions) (int, error) {
+	// check the master key
+	digest, err := c.masterKeyDigest(masterKey)
+	if err != nil {
+		return 0, fmt.Errorf("error in KeyContainer.AddSlot function: %w", err)
+	}
+
+	if len(masterKey) != c.keyLength || subtle.ConstantTimeCompare(digest, c.digest) != 1 {
+		return 0, errors.New("error in KeyContainer.AddSlot function: master key does not match the container")
+	}
+
+	// check the parameters fit in the slot area
+	if len(name) > MaxKeySlotNameLength {
+		return 0, fmt.Errorf("error in KeyContainer.AddSlot function: name must be at most %d bytes", MaxKeySlotNameLength)
+	}
+
+	if options.SaltLength > keyContainerMaxSaltLength {
+		return 0, fmt.Errorf("error in KeyContainer.AddSlot function: salt must be at most %d bytes", keyContainerMaxSaltLength)
+	}
+
+	if options.Iterations > int64(^uint32(0)) || options.Hash > 255 {
+		return 0, errors.New("error in KeyContainer.AddSlot function: iteration count must fit in 32 bits and the hash function in 8 bits")
+	}
+
+	// find a free slot
+	index := -1
+	for i := len(c.slots) - 1; i >= 0; i-- {
+		if c.slots[i] == nil {
+			index = i
+		}
+	}
+
+	if index < 0 {
+		return 0, errors.New("error in KeyContainer.AddSlot function: all slots are used")
+	}
+
+	// split the master key and wrap the material
+	material, err := AFSplit(keyContainerHash, masterKey, c.stripes)
+	if err != nil {
+		return 0, fmt.Errorf("error in KeyContainer.AddSlot function: %w", err)
+	}
+	defer wipe(material)
+
+	slot, err := NewKeySlot(password, material, options)
+	if err != nil {
+		return 0, fmt.Errorf("error in KeyContainer.AddSlot function: %w", err)
+	}
+
+	c.names[index], c.slots[index] = name, slot
+	return index, nil
+}
+
+// RemoveSlot removes the slot at index, the last active slot can not be removed
+// the wrapped material and the salt are overwritten with random bytes in memory,
+// the slot area is filled with random bytes by the next MarshalBinary
+func (c *KeyContainer) RemoveSlot(index int) error {
+	// check the index
+	if index < 0 || index >= len(c.slots) || c.slots[index] == nil {
+		return fmt.Errorf("error in KeyContainer.RemoveSlot function: slot %d is not active", index)
+	}
+
+	if len(c.ListSlots()) == 1 {
+		return errors.New("error in KeyContainer.RemoveSlot function: the last slot can not be removed")
+	}
+
+	// overwrite the key material
+	slot := c.slots[index]
+	for _, b := range [][]byte{slot.WrappedKey, slot.Salt} {
+		random, err := GenerateRandomSequence(len(b))
+		if err != nil {
+			return fmt.Errorf("error in KeyContainer.RemoveSlot function while overwriting: %s", err.Error())
+		}
+
+		copy(b, random)
+	}
+
+	c.names[index], c.slots[index] = "", nil
+	return nil
+}
+
+// ListSlots returns the active slots in index order
+func (c *KeyContainer) ListSlots() []KeySlotInfo {
+	infos := make([]KeySlotInfo, 0, len(c.slots))
+
+	for i, slot := range c.slots {
+		if slot != nil {
+			infos = append(infos, KeySlotInfo{Index: i, Name: c.names[i], Hash: slot.Hash, Iterations: slot.Iterations, Wrap: slot.Wrap})
+		}
+	}
+
+	return infos
+}
+
+// Unlock returns the master key and the index of the slot the password unlocks
+// every active slot is tried, even after a match, so the time depends on the parameters of the slots and not on which one matches
+// the error wraps ErrWrongPassword when no slot matches
+func (c *KeyContainer) Unlock(password string) ([]byte, int, error) {
+	var masterKey []byte
+	index := -1
+
+	for i, slot := range c.slots {
+		if slot == nil {
+			continue
+		}
+
+		// a wrong password fails the integrity check of the slot
+		material, err := slot.Unlock(password)
+		if err != nil && !errors.Is(err, ErrWrongPassword) {
+			return nil, 0, fmt.Errorf("error in KeyContainer.Unlock function: %w", err)
+		}
+
+		// merge and check the digest of every slot the same way
+		if err != nil || len(material) != c.keyLength*c.stripes {
+			material = make([]byte, c.keyLength*c.stripes)
+		}
+
+		candidate, err := AFMerge(keyContainerHash, material, c.stripes)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error in KeyContainer.Unlock function: %w", err)
+		}
+		wipe(material)
+
+		digest, err := c.masterKeyDigest(candidate)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error in KeyContainer.Unlock function: %w", err)
+		}
+
+		if subtle.ConstantTimeCompare(digest, c.digest) == 1 && index < 0 {
+			masterKey, index = candidate, i
+		}
+	}
+
+	if index < 0 {
+		return nil, 0, fmt.Errorf("error in KeyContainer.Unlock function: %w", ErrWrongPassword)
+	}
+
+	return masterKey, index, nil
+}
+
+// MarshalBinary encodes the container
+// the format is "PBKC" || version || slots || stripes(32 bit) || key length(16 bit) || digest salt || digest || slot areas,
+// every slot area has the same size and is active(1) || name length || name || hash || iterations(32 bit) ||
+// salt length || salt || wrap || wrapped key length(32 bit) || wrapped key, padded with random bytes,
+// inactive areas are a zero byte followed by random bytes, the integers are big-endian
+func (c *KeyContainer) MarshalBinary() ([]byte, error) {
+	if len(c.ListSlots()) == 0 {
+		return nil, errors.New("error in KeyContainer.MarshalBinary function: container has no slot")
+	}
+
+	encoded := make([]byte, 0, 12+2*keyContainerDigestLength+len(c.slots)*c.slotAreaSize)
+	encoded = append(encoded, keyContainerMagic...)
+	encoded = append(encoded, KeyContainerVersion, byte(len(c.slots)))
+	encoded = append(encoded, ConvertUnsignedIntegerToByteSlice(uint64(c.stripes), 4, false)...)
+	encoded = append(encoded, ConvertUnsignedIntegerToByteSlice(uint64(c.keyLength), 2, false)...)
+	encoded = append(encoded, c.digestSalt...)
+	encoded = append(encoded, c.digest...)
+
+	for i, slot := range c.slots {
+		// fill the area with random bytes, the slot is written over them
+		area, err := GenerateRandomSequence(c.slotAreaSize)
+		if err != nil {
+			return nil, fmt.Errorf("error in KeyContainer.MarshalBinary function while generating padding: %s", err.Error())
+		}
+
+		area[0] = 0
+		if slot != nil {
+			fields := []byte{1, byte(len(c.names[i]))}
+			fields = append(fields, c.names[i]...)
+			fields = append(fields, byte(slot.Hash))
+			fields = append(fields, ConvertUnsignedIntegerToByteSlice(uint64(slot.Iterations), 4, false)...)
+			fields = append(fields, byte(len(slot.Salt)))
+			fields = append(fields, slot.Salt...)
+			fields = append(fields, byte(slot.Wrap))
+			fields = append(fields, ConvertUnsignedIntegerToByteSlice(uint64(len(slot.WrappedKey)), 4, false)...)
+			fields = append(fields, slot.WrappedKey...)
+			copy(area, fields)
+		}
+
+		encoded = append(encoded, area...)
+	}
+
+	return encoded, nil
+}
+
+// UnmarshalBinary decodes a container encoded by MarshalBinary
+func (c *KeyContainer) UnmarshalBinary(data []byte) error {
+	header := 12 + 2*keyContainerDigestLength
+
+	// check the header
+	if len(data) < header || !bytes.Equal(data[:4], keyContainerMagic) {
+		return errors.New("error in KeyContainer.UnmarshalBinary function: not a key container")
+	}
+
+	if data[4] != KeyContainerVersion {
+		return fmt.Errorf("error in KeyContainer.UnmarshalBinary function: unsupported version %d", data[4])
+	}
+
+	decoded := &KeyContainer{
+		stripes:    int(ConvertSliceToUnsignedInteger(data[6:10], false)),
+		keyLength:  int(ConvertSliceToUnsignedInteger(data[10:12], false)),
+		digestSalt: append([]byte(nil), data[12:12+keyContainerDigestLength]...),
+		digest:     append([]byte(nil), data[12+keyContainerDigestLength:header]...),
+		names:      make([]string, data[5]),
+		slots:      make([]*KeySlot, data[5]),
+	}
+
+	if decoded.stripes < 1 || decoded.keyLength < 1 || decoded.keyLength > 1024 || len(decoded.slots) == 0 {
+		return errors.New("error in KeyContainer.UnmarshalBinary function: invalid parameters")
+	}
+
+	decoded.slotAreaSize = decoded.areaSize()
+	if int64(len(data)) != int64(header)+int64(len(decoded.slots))*int64(decoded.slotAreaSize) {
+		return errors.New("error in KeyContainer.UnmarshalBinary function: invalid length")
+	}
+
+	// decode the active slots
+	for i := range decoded.slots {
+		area := data[header+i*decoded.slotAreaSize : header+(i+1)*decoded.slotAreaSize]
+
+		switch area[0] {
+		case 0:
+			continue
+		case 1:
+		default:
+			return fmt.Errorf("error in KeyContainer.UnmarshalBinary function: invalid state of slot %d", i)
+		}
+
+		name, err := decoded.decodeSlot(i, area)
+		if err != nil {
+			return fmt.Errorf("error in KeyContainer.UnmarshalBinary function while decoding slot %d: %s", i, err.Error())
+		}
+		decoded.names[i] = name
+	}
+
+	*c = *decoded
+	return nil
+}
+
+// decodeSlot decodes the active slot area of index i and returns its name
+func (c *KeyContainer) decodeSlot(i int, area []byte) (string, error) {
+	offset := 1
+
+	// field reads the next n bytes of the area
+	field := func(n int) ([]byte, error) {
+		if offset+n > len(area) {
+			return nil, errors.New("slot area is truncated")
+		}
+
+		offset += n
+		return area[offset-n : offset], nil
+	}
+
+	// name
+	length, err := field(1)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := field(int(length[0]))
+	if err != nil || len(name) > MaxKeySlotNameLength {
+		return "", errors.New("invalid name")
+	}
+
+	// PBKDF2 parameters
+	params, err := field(5)
+	if err != nil {
+		return "", err
+	}
+
+	slot := &KeySlot{Hash: crypto.Hash(params[0]), Iterations: int64(ConvertSliceToUnsignedInteger(params[1:], false))}
+
+	if length, err = field(1); err != nil {
+		return "", err
+	}
+
+	salt, err := field(int(length[0]))
+	if err != nil || len(salt) > keyContainerMaxSaltLength {
+		return "", errors.New("invalid salt")
+	}
+	slot.Salt = append([]byte(nil), salt...)
+
+	// wrapped key
+	wrap, err := field(5)
+	if err != nil {
+		return "", err
+	}
+
+	slot.Wrap = KeyWrapAlgorithm(wrap[0])
+	if _, ok := keyWrapNames[slot.Wrap]; !ok {
+		return "", fmt.Errorf("unknown key wrap algorithm %d", wrap[0])
+	}
+
+	wrapped, err := field(int(ConvertSliceToUnsignedInteger(wrap[1:], false)))
+	if err != nil {
+		return "", err
+	}
+	slot.WrappedKey = append([]byte(nil), wrapped...)
+
+	c.slots[i] = slot
+	return string(name), nil
+}
+
+// areaSize returns the size of a slot area, it holds the largest slot any key wrap algorithm produces
+func (c *KeyContainer) areaSize() int {
+	material := c.keyLength * c.stripes
+	return 1 + 1 + MaxKeySlotNameLength + 5 + 1 + keyContainerMaxSaltLength + 5 + (material+7)/8*8 + keyContainerWrapOverhead
+}
+
+// masterKeyDigest returns PBKDF2(SHA-256, masterKey, digestSalt, 1000, 32)
+func (c *KeyContainer) masterKeyDigest(masterKey []byte) ([]byte, error) {
+	return PBKDF2(keyContainerHash, masterKey, c.digestSalt, keyContainerDigestIterations, keyContainerDigestLength)
+}
+
+// wipe overwrites b with zeros
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
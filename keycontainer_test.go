@@ -0,0 +1,139 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+// tests that every slot of a container unlocks the master key and that removed slots are gone
+func TestKeyContainer(t *testing.T) {
+	masterKey, _ := GenerateRandomSequence(32)
+
+	container, err := NewKeyContainer(masterKey, KeyContainerOptions{Slots: 3, Stripes: 100})
+	if err != nil {
+		t.Fatalf("error in TestKeyContainer function while creating the container: %s", err.Error())
+	}
+
+	if _, err := container.MarshalBinary(); err == nil {
+		t.Errorf("error in TestKeyContainer function: container without slots was encoded")
+	}
+
+	// slots with their own parameters
+	slots := []struct {
+		name, password string
+		options        KeySlotOptions
+	}{
+		{"user", "user password", KeySlotOptions{Iterations: 1000}},
+		{"recovery", "recovery key", KeySlotOptions{Hash: crypto.SHA512, Iterations: 2000, Wrap: KeyWrapAESGCM}},
+		{"admin", "admin password", KeySlotOptions{Hash: crypto.SHA1, Iterations: 1500, SaltLength: 32, Wrap: KeyWrapAESKWP}},
+	}
+
+	for i, slot := range slots {
+		index, err := container.AddSlot(masterKey, slot.name, slot.password, slot.options)
+		if err != nil || index != i {
+			t.Fatalf("error in TestKeyContainer function while adding slot %s: %d, %v", slot.name, index, err)
+		}
+	}
+
+	if _, err := container.AddSlot(masterKey, "extra", "password", KeySlotOptions{Iterations: 1000}); err == nil {
+		t.Errorf("error in TestKeyContainer function: slot was added to a full container")
+	}
+
+	infos := container.ListSlots()
+	if len(infos) != 3 || infos[1].Name != "recovery" || infos[1].Hash != crypto.SHA512 || infos[1].Iterations != 2000 || infos[1].Wrap != KeyWrapAESGCM {
+		t.Errorf("error in TestKeyContainer function: unexpected slots %+v", infos)
+	}
+
+	// encode, decode and unlock with every password
+	encoded, err := container.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error in TestKeyContainer function while encoding: %s", err.Error())
+	}
+
+	decoded := &KeyContainer{}
+	if err := decoded.UnmarshalBinary(encoded); err != nil {
+		t.Fatalf("error in TestKeyContainer function while decoding: %s", err.Error())
+	}
+
+	for i, slot := range slots {
+		unlocked, index, err := decoded.Unlock(slot.password)
+		if err != nil || index != i || !bytes.Equal(unlocked, masterKey) {
+			t.Errorf("error in TestKeyContainer function: %s password unlocked slot %d to %x, %v", slot.name, index, unlocked, err)
+		}
+	}
+
+	if _, _, err := decoded.Unlock("wrong password"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("error in TestKeyContainer function: wrong password gave %v", err)
+	}
+
+	// a wrong master key can not add slots
+	if err := decoded.RemoveSlot(2); err != nil {
+		t.Fatalf("error in TestKeyContainer function while removing a slot: %s", err.Error())
+	}
+
+	if _, err := decoded.AddSlot(make([]byte, 32), "admin", "admin password", KeySlotOptions{Iterations: 1000}); err == nil {
+		t.Errorf("error in TestKeyContainer function: slot was added with a wrong master key")
+	}
+
+	// the removed slot is overwritten at the same offset
+	reencoded, err := decoded.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error in TestKeyContainer function while encoding: %s", err.Error())
+	}
+
+	area := len(encoded) - 2*decoded.slotAreaSize
+	if len(reencoded) != len(encoded) || bytes.Equal(reencoded[area:area+decoded.slotAreaSize], encoded[area:area+decoded.slotAreaSize]) ||
+		reencoded[len(encoded)-decoded.slotAreaSize] != 0 {
+		t.Errorf("error in TestKeyContainer function: removed slot area was not overwritten in place")
+	}
+
+	if err := decoded.UnmarshalBinary(reencoded); err != nil {
+		t.Fatalf("error in TestKeyContainer function while decoding: %s", err.Error())
+	}
+
+	if _, _, err := decoded.Unlock("admin password"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("error in TestKeyContainer function: removed slot still unlocks: %v", err)
+	}
+
+	// the last slot can not be removed
+	decoded.RemoveSlot(0)
+	if err := decoded.RemoveSlot(1); err == nil || len(decoded.ListSlots()) != 1 {
+		t.Errorf("error in TestKeyContainer function: last slot was removed")
+	}
+
+	// invalid encodings
+	for _, invalid := range [][]byte{nil, encoded[:100], encoded[:len(encoded)-1], append([]byte("PBKX"), encoded[4:]...)} {
+		if err := decoded.UnmarshalBinary(invalid); err == nil {
+			t.Errorf("error in TestKeyContainer function: invalid encoding of %d bytes was decoded", len(invalid))
+		}
+	}
+}
+
+// tests that AFMerge recovers the data and needs every stripe
+func TestAFSplit(t *testing.T) {
+	data := []byte("0123456789abcdef0123456789abcdef0123456789")
+
+	for _, stripes := range []int{1, 2, 3, 4000} {
+		material, err := AFSplit(crypto.SHA256, data, stripes)
+		if err != nil || len(material) != stripes*len(data) {
+			t.Fatalf("error in TestAFSplit function while splitting into %d stripes: %v", stripes, err)
+		}
+
+		merged, err := AFMerge(crypto.SHA256, material, stripes)
+		if err != nil || !bytes.Equal(merged, data) {
+			t.Errorf("error in TestAFSplit function: %d stripes merged to %q, %v", stripes, merged, err)
+		}
+
+		// changing any stripe changes the result
+		material[0] ^= 1
+		if merged, _ := AFMerge(crypto.SHA256, material, stripes); bytes.Equal(merged, data) {
+			t.Errorf("error in TestAFSplit function: modified first stripe of %d merged to the data", stripes)
+		}
+	}
+
+	if _, err := AFMerge(crypto.SHA256, data, 4); err == nil {
+		t.Errorf("error in TestAFSplit function: material of a wrong length was merged")
+	}
+}
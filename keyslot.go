@@ -0,0 +1,289 @@
+package pbkdf
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KeyWrapAlgorithm selects how a key slot wraps the data key with the key encryption key
+type KeyWrapAlgorithm byte
+
+// key wrap algorithms, the KEK is always an AES-256 key
+const (
+	// KeyWrapAESKW is AES Key Wrap(RFC3394), the data key must be a multiple of 8 bytes and at least 16 bytes long
+	KeyWrapAESKW KeyWrapAlgorithm = 1
+	// KeyWrapAESKWP is AES Key Wrap with Padding(RFC5649), the data key can have any length
+	KeyWrapAESKWP KeyWrapAlgorithm = 2
+	// KeyWrapAESGCM is AES-256-GCM with a random nonce, the slot parameters are authenticated as additional data
+	KeyWrapAESGCM KeyWrapAlgorithm = 3
+)
+
+// names of the key wrap algorithms in serialized slots
+var keyWrapNames = map[KeyWrapAlgorithm]string{
+	KeyWrapAESKW:  "aes-kw",
+	KeyWrapAESKWP: "aes-kwp",
+	KeyWrapAESGCM: "aes-gcm",
+}
+
+// String returns the name of the algorithm in serialized slots(aes-kw, aes-kwp or aes-gcm)
+func (a KeyWrapAlgorithm) String() string {
+	if name, ok := keyWrapNames[a]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("KeyWrapAlgorithm(%d)", byte(a))
+}
+
+// length of the key encryption key derived with PBKDF2
+const keySlotKEKLength = 32
+
+// KeySlotOptions holds the optional parameters of NewKeySlot, zero values select the defaults
+type KeySlotOptions struct {
+	// Hash is the PBKDF2 hash function, zero means SHA-256
+	Hash crypto.Hash
+	// Iterations is the PBKDF2 iteration count, zero means DefaultIterationCount
+	Iterations int64
+	// SaltLength is the salt length in bytes, zero means DefaultSaltLength
+	SaltLength int64
+	// Wrap is the key wrap algorithm, zero means KeyWrapAESKW
+	Wrap KeyWrapAlgorithm
+}
+
+// KeySlot holds a data key wrapped with a key encryption key derived from a password
+// the data encrypted under the data key never changes when the password does, only the slot is rewrapped
+type KeySlot struct {
+	// Hash is the PBKDF2 hash function
+	Hash crypto.Hash
+	// Iterations is the PBKDF2 iteration count
+	Iterations int64
+	// Salt is the PBKDF2 salt
+	Salt []byte
+	// Wrap is the key wrap algorithm
+	Wrap KeyWrapAlgorithm
+	// WrappedKey is the wrapped data key, for KeyWrapAESGCM it is nonce || ciphertext || tag
+	WrappedKey []byte
+}
+
+// NewKeySlot wraps a data key with a key encryption key derived from the password
+// password: the password
+// dataKey: the data key, usually a random key from GenerateRandomSequence
+// options: the optional parameters, the zero value gives PBKDF2 with SHA-256, DefaultIterationCount, DefaultSaltLength and AES Key Wrap
+// the KEK is PBKDF2(hash, password, salt, iterations, 32), an AES-256 key
+func NewKeySlot(password string, dataKey []byte, options KeySlotOptions) (*KeySlot, error) {
+	// apply the defaults
+	if options.Hash == 0 {
+		options.Hash = crypto.SHA256
+	}
+
+	if options.Iterations == 0 {
+		options.Iterations = DefaultIterationCount
+	}
+
+	if options.SaltLength == 0 {
+		options.SaltLength = DefaultSaltLength
+	}
+
+	if options.Wrap == 0 {
+		options.Wrap = KeyWrapAESKW
+	}
+
+	if _, ok := keyWrapNames[options.Wrap]; !ok {
+		return nil, fmt.Errorf("error in NewKeySlot function: unknown key wrap algorithm %d", byte(options.Wrap))
+	}
+
+	// generate the salt
+	salt, err := GenerateRandomSequence(int(options.SaltLength))
+	if err != nil {
+		return nil, fmt.Errorf("error in NewKeySlot function while generating salt: %s", err.Error())
+	}
+
+	slot := &KeySlot{Hash: options.Hash, Iterations: options.Iterations, Salt: salt, Wrap: options.Wrap}
+
+	// derive the KEK and wrap the data key
+	if err := slot.wrap(password, dataKey); err != nil {
+		return nil, fmt.Errorf("error in NewKeySlot function: %w", err)
+	}
+
+	return slot, nil
+}
+
+// Unlock unwraps the data key with the password
+// the error wraps ErrWrongPassword when the password is wrong,
+// a modified slot can not be told apart from a wrong password since the integrity check covers both
+func (s *KeySlot) Unlock(password string) ([]byte, error) {
+	block, err := s.kek(password)
+	if err != nil {
+		return nil, fmt.Errorf("error in KeySlot.Unlock function: %w", err)
+	}
+
+	// unwrap the data key
+	var dataKey []byte
+	switch s.Wrap {
+	case KeyWrapAESKW:
+		dataKey, err = KeyUnwrap(block, s.WrappedKey)
+	case KeyWrapAESKWP:
+		dataKey, err = KeyUnwrapPad(block, s.WrappedKey)
+	case KeyWrapAESGCM:
+		dataKey, err = s.openGCM(block)
+	default:
+		return nil, fmt.Errorf("error in KeySlot.Unlock function: unknown key wrap algorithm %d", byte(s.Wrap))
+	}
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in KeySlot.Unlock function: %w", ErrWrongPassword)
+	}
+
+	return dataKey, nil
+}
+
+// ChangePassword returns a new slot holding the same data key under a new password
+// the new slot has the same hash, iteration count, salt length and key wrap algorithm and a new salt,
+// the error wraps ErrWrongPassword when oldPassword is wrong
+func (s *KeySlot) ChangePassword(oldPassword, newPassword string) (*KeySlot, error) {
+	return s.Rewrap(oldPassword, newPassword, KeySlotOptions{Hash: s.Hash, Iterations: s.Iterations, SaltLength: int64(len(s.Salt)), Wrap: s.Wrap})
+}
+
+// Rewrap returns a new slot holding the same data key under a new password and new parameters
+// it is ChangePassword with a choice of parameters, for example to raise the iteration count
+func (s *KeySlot) Rewrap(oldPassword, newPassword string, options KeySlotOptions) (*KeySlot, error) {
+	dataKey, err := s.Unlock(oldPassword)
+	if err != nil {
+		return nil, fmt.Errorf("error in KeySlot.Rewrap function: %w", err)
+	}
+
+	slot, err := NewKeySlot(newPassword, dataKey, options)
+	if err != nil {
+		return nil, fmt.Errorf("error in KeySlot.Rewrap function: %w", err)
+	}
+
+	return slot, nil
+}
+
+// String returns the serialized slot in the format: wrap:kdf:salt:iterationCount:wrappedKey
+// wrap is the key wrap algorithm(aes-kw, aes-kwp or aes-gcm), kdf the registry name of PBKDF2(pbkdf2-sha256, ...),
+// salt and wrappedKey are base64 encoded as in GeneratePasswordString
+func (s *KeySlot) String() string {
+	return s.params() + ":" + base64.StdEncoding.EncodeToString(s.WrappedKey)
+}
+
+// ParseKeySlot parses a slot serialized by KeySlot.String
+// The encoded parameter is the serialized slot
+func ParseKeySlot(encoded string) (*KeySlot, error) {
+	// split the fields
+	fields := strings.Split(encoded, ":")
+	if len(fields) != 5 {
+		return nil, errors.New("error in ParseKeySlot function: slot must contain the key wrap algorithm, KDF, salt, iteration count and wrapped key separated by colons")
+	}
+
+	slot := &KeySlot{}
+
+	// key wrap algorithm
+	for wrap, name := range keyWrapNames {
+		if name == fields[0] {
+			slot.Wrap = wrap
+		}
+	}
+
+	if slot.Wrap == 0 {
+		return nil, fmt.Errorf("error in ParseKeySlot function: unknown key wrap algorithm %q", fields[0])
+	}
+
+	// PBKDF2 hash
+	for _, h := range kdfHashNames {
+		if "pbkdf2-"+h.name == fields[1] && h.hash != crypto.MD5 {
+			slot.Hash = h.hash
+		}
+	}
+
+	if slot.Hash == 0 {
+		return nil, fmt.Errorf("error in ParseKeySlot function: unknown KDF %q", fields[1])
+	}
+
+	// salt, iteration count and wrapped key
+	var err error
+	if slot.Salt, err = base64.StdEncoding.Strict().DecodeString(fields[2]); err != nil {
+		return nil, fmt.Errorf("error in ParseKeySlot function while decoding salt: %s", err.Error())
+	}
+
+	if slot.Iterations, err = strconv.ParseInt(fields[3], 10, 64); err != nil || slot.Iterations <= 0 || strconv.FormatInt(slot.Iterations, 10) != fields[3] {
+		return nil, fmt.Errorf("error in ParseKeySlot function: invalid iteration count %q", fields[3])
+	}
+
+	if slot.WrappedKey, err = base64.StdEncoding.Strict().DecodeString(fields[4]); err != nil {
+		return nil, fmt.Errorf("error in ParseKeySlot function while decoding wrapped key: %s", err.Error())
+	}
+
+	return slot, nil
+}
+
+// params returns the serialized slot without the wrapped key, it is the additional data of KeyWrapAESGCM
+func (s *KeySlot) params() string {
+	return fmt.Sprintf("%s:pbkdf2-%s:%s:%d", s.Wrap, kdfHashName(s.Hash), base64.StdEncoding.EncodeToString(s.Salt), s.Iterations)
+}
+
+// kek derives the key encryption key as an AES-256 block cipher
+func (s *KeySlot) kek(password string) (cipher.Block, error) {
+	KEK, err := PBKDF2(s.Hash, []byte(password), s.Salt, s.Iterations, keySlotKEKLength)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("deriving key encryption key: %w", err)
+	}
+
+	return aes.NewCipher(KEK)
+}
+
+// wrap derives the key encryption key and sets WrappedKey
+func (s *KeySlot) wrap(password string, dataKey []byte) error {
+	block, err := s.kek(password)
+	if err != nil {
+		return err
+	}
+
+	switch s.Wrap {
+	case KeyWrapAESKW:
+		s.WrappedKey, err = KeyWrap(block, dataKey)
+	case KeyWrapAESKWP:
+		s.WrappedKey, err = KeyWrapPad(block, dataKey)
+	case KeyWrapAESGCM:
+		s.WrappedKey, err = s.sealGCM(block, dataKey)
+	}
+
+	return err
+}
+
+// sealGCM encrypts the data key with AES-GCM, the nonce is random and prepended
+func (s *KeySlot) sealGCM(block cipher.Block, dataKey []byte) ([]byte, error) {
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := GenerateRandomSequence(aead.NonceSize())
+	if err != nil {
+		return nil, fmt.Errorf("generating nonce: %s", err.Error())
+	}
+
+	return aead.Seal(nonce, nonce, dataKey, []byte(s.params())), nil
+}
+
+// openGCM decrypts the data key encrypted by sealGCM
+func (s *KeySlot) openGCM(block cipher.Block) ([]byte, error) {
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.WrappedKey) < aead.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+
+	return aead.Open(nil, s.WrappedKey[:aead.NonceSize()], s.WrappedKey[aead.NonceSize():], []byte(s.params()))
+}
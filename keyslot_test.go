@@ -0,0 +1,139 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// tests that a key slot unlocks with its password only and survives serialization and password changes
+func TestKeySlot(t *testing.T) {
+	dataKey, _ := GenerateRandomSequence(32)
+
+	for _, wrap := range []KeyWrapAlgorithm{KeyWrapAESKW, KeyWrapAESKWP, KeyWrapAESGCM} {
+		slot, err := NewKeySlot("password", dataKey, KeySlotOptions{Hash: crypto.SHA512, Iterations: 1000, Wrap: wrap})
+		if err != nil {
+			t.Fatalf("error in TestKeySlot function while creating a %s slot: %s", wrap, err.Error())
+		}
+
+		// serialization round trip
+		encoded := slot.String()
+		if !strings.HasPrefix(encoded, wrap.String()+":pbkdf2-sha512:") || !strings.Contains(encoded, ":1000:") {
+			t.Errorf("error in TestKeySlot function: unexpected serialized slot %q", encoded)
+		}
+
+		parsed, err := ParseKeySlot(encoded)
+		if err != nil {
+			t.Fatalf("error in TestKeySlot function while parsing %q: %s", encoded, err.Error())
+		}
+
+		if unlocked, err := parsed.Unlock("password"); err != nil || !bytes.Equal(unlocked, dataKey) {
+			t.Errorf("error in TestKeySlot function: %s slot unlocked to %x, %v", wrap, unlocked, err)
+		}
+
+		if _, err := parsed.Unlock("wrong password"); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("error in TestKeySlot function: wrong password on a %s slot gave %v", wrap, err)
+		}
+
+		// a password change keeps the data key and the parameters
+		changed, err := slot.ChangePassword("password", "new password")
+		if err != nil {
+			t.Fatalf("error in TestKeySlot function while changing the password: %s", err.Error())
+		}
+
+		if unlocked, err := changed.Unlock("new password"); err != nil || !bytes.Equal(unlocked, dataKey) {
+			t.Errorf("error in TestKeySlot function: changed %s slot unlocked to %x, %v", wrap, unlocked, err)
+		}
+
+		if changed.Hash != slot.Hash || changed.Iterations != slot.Iterations || changed.Wrap != slot.Wrap || bytes.Equal(changed.Salt, slot.Salt) {
+			t.Errorf("error in TestKeySlot function: password change gave parameters %+v", changed)
+		}
+
+		if _, err := changed.Unlock("password"); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("error in TestKeySlot function: old password still unlocks the %s slot", wrap)
+		}
+
+		if _, err := slot.ChangePassword("wrong password", "new password"); !errors.Is(err, ErrWrongPassword) {
+			t.Errorf("error in TestKeySlot function: password change with a wrong password gave %v", err)
+		}
+	}
+
+	// the GCM slot authenticates its parameters
+	slot, _ := NewKeySlot("password", dataKey, KeySlotOptions{Iterations: 1000, Wrap: KeyWrapAESGCM})
+	slot.Wrap = KeyWrapAESKWP
+	if _, err := slot.Unlock("password"); err == nil {
+		t.Errorf("error in TestKeySlot function: modified slot was unlocked")
+	}
+
+	// AES Key Wrap needs a multiple of 8 bytes, AES Key Wrap with Padding does not
+	if _, err := NewKeySlot("password", dataKey[:20], KeySlotOptions{Iterations: 1000}); err == nil {
+		t.Errorf("error in TestKeySlot function: 20 byte key was wrapped with AES Key Wrap")
+	}
+
+	if _, err := NewKeySlot("password", dataKey[:20], KeySlotOptions{Iterations: 1000, Wrap: KeyWrapAESKWP}); err != nil {
+		t.Errorf("error in TestKeySlot function while wrapping a 20 byte key with padding: %s", err.Error())
+	}
+
+	// invalid serialized slots
+	invalid := []string{
+		"",
+		"aes-kw:pbkdf2-sha256:c2FsdA==:1000",
+		"aes-xx:pbkdf2-sha256:c2FsdA==:1000:AAAA",
+		"aes-kw:pbkdf2-md5:c2FsdA==:1000:AAAA",
+		"aes-kw:pbkdf2-sha256:c2FsdA=:1000:AAAA",
+		"aes-kw:pbkdf2-sha256:c2FsdA==:01000:AAAA",
+		"aes-kw:pbkdf2-sha256:c2FsdA==:0:AAAA",
+	}
+
+	for _, encoded := range invalid {
+		if _, err := ParseKeySlot(encoded); err == nil {
+			t.Errorf("error in TestKeySlot function: %q was parsed", encoded)
+		}
+	}
+}
+
+// tests that modified wrapped keys fail the integrity check
+func TestKeyUnwrap(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 32))
+
+	for _, length := range []int{1, 7, 8, 9, 16, 20, 32} {
+		key := bytes.Repeat([]byte{0x5A}, length)
+
+		wrapped, err := KeyWrapPad(block, key)
+		if err != nil {
+			t.Fatalf("error in TestKeyUnwrap function while wrapping %d bytes: %s", length, err.Error())
+		}
+
+		for i := range wrapped {
+			modified := append([]byte(nil), wrapped...)
+			modified[i] ^= 0x80
+
+			if _, err := KeyUnwrapPad(block, modified); err == nil {
+				t.Errorf("error in TestKeyUnwrap function: modified byte %d of a %d byte key was unwrapped", i, length)
+			}
+		}
+
+		// KW and KWP do not unwrap each other
+		if length%8 == 0 && length >= 16 {
+			if _, err := KeyUnwrap(block, wrapped); err == nil {
+				t.Errorf("error in TestKeyUnwrap function: KWP output was unwrapped with KW")
+			}
+		}
+	}
+
+	// invalid lengths
+	if _, err := KeyWrap(block, make([]byte, 8)); err == nil {
+		t.Errorf("error in TestKeyUnwrap function: 8 byte key was wrapped with KW")
+	}
+
+	if _, err := KeyUnwrap(block, make([]byte, 20)); err == nil {
+		t.Errorf("error in TestKeyUnwrap function: 20 byte wrapped key was unwrapped")
+	}
+
+	if _, err := KeyWrapPad(block, nil); err == nil {
+		t.Errorf("error in TestKeyUnwrap function: empty key was wrapped with KWP")
+	}
+}
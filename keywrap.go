@@ -0,0 +1,188 @@
+package pbkdf
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// default initial values of the key wrap algorithms
+var (
+	// keyWrapIV is the default initial value of RFC3394
+	keyWrapIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+	// keyWrapPadIV is the constant part of the alternative initial value of RFC5649
+	keyWrapPadIV = []byte{0xA6, 0x59, 0x59, 0xA6}
+)
+
+// KeyWrap wraps a key with the AES Key Wrap algorithm(KW)
+// It is based on the RFC3394(https://datatracker.ietf.org/doc/html/rfc3394) and NIST SP 800-38F
+// block: the key encryption key as a 128 bit block cipher, usually aes.NewCipher(KEK)
+// key: the key to wrap, a multiple of 8 bytes and at least 16 bytes long(KeyWrapPad accepts any length)
+// returns: the wrapped key, 8 bytes longer than the key
+func KeyWrap(block cipher.Block, key []byte) ([]byte, error) {
+	// check the parameters
+	if block.BlockSize() != 16 {
+		return nil, errors.New("error in KeyWrap function: block size must be 16 bytes")
+	}
+
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, errors.New("error in KeyWrap function: key must be a multiple of 8 bytes and at least 16 bytes long")
+	}
+
+	return keyWrap(block, keyWrapIV, key), nil
+}
+
+// KeyUnwrap unwraps a key wrapped with KeyWrap
+// block: the key encryption key as a 128 bit block cipher
+// wrapped: the wrapped key
+// an error is returned when the integrity check fails(wrong key encryption key or modified wrapped key)
+func KeyUnwrap(block cipher.Block, wrapped []byte) ([]byte, error) {
+	// check the parameters
+	if block.BlockSize() != 16 {
+		return nil, errors.New("error in KeyUnwrap function: block size must be 16 bytes")
+	}
+
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, errors.New("error in KeyUnwrap function: wrapped key must be a multiple of 8 bytes and at least 24 bytes long")
+	}
+
+	A, key := keyUnwrap(block, wrapped)
+
+	// check the initial value
+	if subtle.ConstantTimeCompare(A, keyWrapIV) != 1 {
+		return nil, errors.New("error in KeyUnwrap function: integrity check failed")
+	}
+
+	return key, nil
+}
+
+// KeyWrapPad wraps a key of any length with the AES Key Wrap with Padding algorithm(KWP)
+// It is based on the RFC5649(https://datatracker.ietf.org/doc/html/rfc5649) and NIST SP 800-38F
+// block: the key encryption key as a 128 bit block cipher, usually aes.NewCipher(KEK)
+// key: the key to wrap, from 1 to 2^32 - 1 bytes
+// returns: the wrapped key, the key padded to a multiple of 8 bytes with zeros and 8 bytes longer
+func KeyWrapPad(block cipher.Block, key []byte) ([]byte, error) {
+	// check the parameters
+	if block.BlockSize() != 16 {
+		return nil, errors.New("error in KeyWrapPad function: block size must be 16 bytes")
+	}
+
+	if len(key) == 0 || int64(len(key)) > int64(^uint32(0)) {
+		return nil, errors.New("error in KeyWrapPad function: key must be from 1 to 2^32 - 1 bytes long")
+	}
+
+	// alternative initial value with the message length indicator
+	AIV := binary.BigEndian.AppendUint32(append([]byte(nil), keyWrapPadIV...), uint32(len(key)))
+
+	// pad with zeros
+	padded := make([]byte, (len(key)+7)/8*8)
+	copy(padded, key)
+
+	// a single block is encrypted directly
+	if len(padded) == 8 {
+		wrapped := append(AIV, padded...)
+		block.Encrypt(wrapped, wrapped)
+		return wrapped, nil
+	}
+
+	return keyWrap(block, AIV, padded), nil
+}
+
+// KeyUnwrapPad unwraps a key wrapped with KeyWrapPad
+// block: the key encryption key as a 128 bit block cipher
+// wrapped: the wrapped key
+// an error is returned when the integrity check fails(wrong key encryption key or modified wrapped key)
+func KeyUnwrapPad(block cipher.Block, wrapped []byte) ([]byte, error) {
+	// check the parameters
+	if block.BlockSize() != 16 {
+		return nil, errors.New("error in KeyUnwrapPad function: block size must be 16 bytes")
+	}
+
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, errors.New("error in KeyUnwrapPad function: wrapped key must be a multiple of 8 bytes and at least 16 bytes long")
+	}
+
+	// a single block is decrypted directly
+	var A, padded []byte
+	if len(wrapped) == 16 {
+		decrypted := make([]byte, 16)
+		block.Decrypt(decrypted, wrapped)
+		A, padded = decrypted[:8], decrypted[8:]
+	} else {
+		A, padded = keyUnwrap(block, wrapped)
+	}
+
+	// check the initial value, the message length indicator and the padding
+	length := int(binary.BigEndian.Uint32(A[4:]))
+	valid := subtle.ConstantTimeCompare(A[:4], keyWrapPadIV)
+	valid &= subtle.ConstantTimeLessOrEq(len(padded)-7, length) & subtle.ConstantTimeLessOrEq(length, len(padded))
+
+	if valid != 1 {
+		return nil, errors.New("error in KeyUnwrapPad function: integrity check failed")
+	}
+
+	var padding byte
+	for _, b := range padded[length:] {
+		padding |= b
+	}
+
+	if padding != 0 {
+		return nil, errors.New("error in KeyUnwrapPad function: integrity check failed")
+	}
+
+	return padded[:length], nil
+}
+
+// keyWrap runs the wrapping process of RFC3394 section 2.2.1 with the initial value IV
+func keyWrap(block cipher.Block, IV []byte, key []byte) []byte {
+	n := len(key) / 8
+
+	// C = A || R[1] || ... || R[n]
+	C := make([]byte, 8+len(key))
+	copy(C, IV)
+	copy(C[8:], key)
+
+	B := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			// B = AES(K, A || R[i]), A = MSB(64, B) ^ t, R[i] = LSB(64, B)
+			copy(B, C[:8])
+			copy(B[8:], C[8*i:8*i+8])
+			block.Encrypt(B, B)
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(C, binary.BigEndian.Uint64(B)^t)
+			copy(C[8*i:], B[8:])
+		}
+	}
+
+	return C
+}
+
+// keyUnwrap runs the unwrapping process of RFC3394 section 2.2.2 and returns the initial value and the key
+func keyUnwrap(block cipher.Block, wrapped []byte) ([]byte, []byte) {
+	n := len(wrapped)/8 - 1
+
+	// A = C[0], R[i] = C[i]
+	A := make([]byte, 8)
+	copy(A, wrapped)
+	R := make([]byte, 8*n)
+	copy(R, wrapped[8:])
+
+	B := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			// B = AES-1(K, (A ^ t) || R[i]), A = MSB(64, B), R[i] = LSB(64, B)
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(B, binary.BigEndian.Uint64(A)^t)
+			copy(B[8:], R[8*(i-1):8*i])
+			block.Decrypt(B, B)
+
+			copy(A, B[:8])
+			copy(R[8*(i-1):], B[8:])
+		}
+	}
+
+	return A, R
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package pbkdf
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the whole file into memory on platforms without mmap support
+// the returned function is a no-op
+func mapFile(f *os.File) ([]byte, func() error, error) {
+	// read the file
+	data, err := io.ReadAll(f)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package pbkdf
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mapFile maps the whole file read-only into memory
+// the returned function releases the mapping
+func mapFile(f *os.File) ([]byte, func() error, error) {
+	// get the file size
+	info, err := f.Stat()
+
+	// check if an error occurred
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// empty files cannot be mapped
+	if info.Size() == 0 {
+		return []byte{}, func() error { return nil }, nil
+	}
+
+	// check if the file fits in the address space
+	if int64(int(info.Size())) != info.Size() {
+		return nil, nil, fmt.Errorf("file too large to map: %d bytes", info.Size())
+	}
+
+	// map the file
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,201 @@
+package pbkdf
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PasswordHash is a validated encoded password, in the salt:iterationCount:hashedPassword format or a PHC string of a registered KDF
+// It can be used directly as a field of database models and API structures:
+// it implements encoding.TextMarshaler, encoding.TextUnmarshaler, json.Marshaler, json.Unmarshaler, sql.Scanner and driver.Valuer,
+// every decoding validates the string, and String and GoString are redacted so the hash does not leak into logs
+// the zero value is an empty hash, it is stored as NULL and encoded as null in JSON
+type PasswordHash struct {
+	encoded string
+	kdf     KDF
+	salt    []byte
+	key     []byte
+	iter    int64
+}
+
+// ParsePasswordHash validates an encoded password and returns it as a PasswordHash
+// The encodedPassword parameter is a string returned by Hasher.Hash, EncodePassword or EncodePasswordKDF
+// PHC strings must name a registered KDF, the error wraps ErrUnknownKDF otherwise
+func ParsePasswordHash(encodedPassword string) (PasswordHash, error) {
+	// PHC strings
+	if strings.HasPrefix(encodedPassword, "$") {
+		kdf, salt, key, err := GetKDFParametersFromString(encodedPassword)
+		if err != nil {
+			return PasswordHash{}, fmt.Errorf("error in ParsePasswordHash function: %w", err)
+		}
+
+		// an empty hash would match every password
+		if len(key) == 0 {
+			return PasswordHash{}, errors.New("error in ParsePasswordHash function: hash must not be empty")
+		}
+
+		// the iteration count is i for PBKDF1 and PBKDF2 and the number of passes t for Argon2
+		var iter int64
+		for _, param := range kdf.Params() {
+			if param.Name == "i" || param.Name == "t" {
+				iter = param.Value
+			}
+		}
+
+		return PasswordHash{encoded: encodedPassword, kdf: kdf, salt: salt, key: key, iter: iter}, nil
+	}
+
+	// salt:iterationCount:hashedPassword strings
+	salt, iter, key, err := GetPasswordParametersFromString(encodedPassword)
+	if err != nil {
+		return PasswordHash{}, fmt.Errorf("error in ParsePasswordHash function: %w", err)
+	}
+
+	if iter <= 0 || len(key) == 0 {
+		return PasswordHash{}, errors.New("error in ParsePasswordHash function: iteration count and hash must not be empty")
+	}
+
+	return PasswordHash{encoded: encodedPassword, salt: salt, key: key, iter: iter}, nil
+}
+
+// IsZero reports whether the hash is empty
+func (h PasswordHash) IsZero() bool {
+	return h.encoded == ""
+}
+
+// Encoded returns the encoded password, to be passed to Hasher.Verify or VerifyPasswordKDF
+func (h PasswordHash) Encoded() string {
+	return h.encoded
+}
+
+// Algorithm returns the registry name of the KDF(pbkdf2-sha256, argon2id, ...)
+// it is empty for the salt:iterationCount:hashedPassword format, which does not record the algorithm
+func (h PasswordHash) Algorithm() string {
+	if h.kdf == nil {
+		return ""
+	}
+
+	return h.kdf.Name()
+}
+
+// KDF returns the KDF and its parameters, nil for the salt:iterationCount:hashedPassword format
+func (h PasswordHash) KDF() KDF {
+	return h.kdf
+}
+
+// Salt returns a copy of the salt
+func (h PasswordHash) Salt() []byte {
+	return append([]byte(nil), h.salt...)
+}
+
+// Iterations returns the iteration count, the number of passes for Argon2 and zero for scrypt
+func (h PasswordHash) Iterations() int64 {
+	return h.iter
+}
+
+// KeyLength returns the length of the derived key in bytes
+func (h PasswordHash) KeyLength() int {
+	return len(h.key)
+}
+
+// String returns a redacted description with the algorithm and the iteration count, never the salt or the hash
+func (h PasswordHash) String() string {
+	if h.IsZero() {
+		return "PasswordHash(empty)"
+	}
+
+	algorithm := h.Algorithm()
+	if algorithm == "" {
+		algorithm = "salt:iterationCount:hash"
+	}
+
+	return fmt.Sprintf("PasswordHash(%s, %d iterations, redacted)", algorithm, h.iter)
+}
+
+// GoString is String, so %#v is redacted too
+func (h PasswordHash) GoString() string {
+	return h.String()
+}
+
+// MarshalText returns the encoded password
+func (h PasswordHash) MarshalText() ([]byte, error) {
+	return []byte(h.encoded), nil
+}
+
+// UnmarshalText validates and sets the encoded password, empty text gives the zero value
+func (h *PasswordHash) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*h = PasswordHash{}
+		return nil
+	}
+
+	parsed, err := ParsePasswordHash(string(text))
+	if err != nil {
+		return fmt.Errorf("error in PasswordHash.UnmarshalText function: %w", err)
+	}
+
+	*h = parsed
+	return nil
+}
+
+// MarshalJSON returns the encoded password as a JSON string, or null for the zero value
+func (h PasswordHash) MarshalJSON() ([]byte, error) {
+	if h.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(h.encoded)
+}
+
+// UnmarshalJSON validates and sets the encoded password from a JSON string, null gives the zero value
+func (h *PasswordHash) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*h = PasswordHash{}
+		return nil
+	}
+
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return fmt.Errorf("error in PasswordHash.UnmarshalJSON function: %s", err.Error())
+	}
+
+	if err := h.UnmarshalText([]byte(encoded)); err != nil {
+		return fmt.Errorf("error in PasswordHash.UnmarshalJSON function: %w", err)
+	}
+
+	return nil
+}
+
+// Scan validates and sets the encoded password read from a database, NULL gives the zero value
+// The src parameter must be a string, a byte slice or nil
+func (h *PasswordHash) Scan(src any) error {
+	var text []byte
+
+	switch v := src.(type) {
+	case nil:
+	case string:
+		text = []byte(v)
+	case []byte:
+		text = v
+	default:
+		return fmt.Errorf("error in PasswordHash.Scan function: can not scan %T", src)
+	}
+
+	if err := h.UnmarshalText(text); err != nil {
+		return fmt.Errorf("error in PasswordHash.Scan function: %w", err)
+	}
+
+	return nil
+}
+
+// Value returns the encoded password to be stored in a database, NULL for the zero value
+func (h PasswordHash) Value() (driver.Value, error) {
+	if h.IsZero() {
+		return nil, nil
+	}
+
+	return h.encoded, nil
+}
@@ -0,0 +1,145 @@
+package pbkdf
+
+import (
+	"crypto"
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// the interfaces PasswordHash must implement
+var (
+	_ encoding.TextMarshaler   = PasswordHash{}
+	_ encoding.TextUnmarshaler = &PasswordHash{}
+	_ json.Marshaler           = PasswordHash{}
+	_ json.Unmarshaler         = &PasswordHash{}
+	_ sql.Scanner              = &PasswordHash{}
+	_ driver.Valuer            = PasswordHash{}
+)
+
+// tests parsing, the exposed parameters and the redaction
+func TestParsePasswordHash(t *testing.T) {
+	legacy, _ := EncodePasswordPBKDF2(crypto.SHA256, "password", 16, 1000, 32)
+	phc, _ := EncodePasswordKDF(PBKDF2Parameters{Hash: crypto.SHA512, Iterations: 2000}, "password", 12, 24)
+	argon2, _ := EncodePasswordKDF(Argon2Parameters{Variant: Argon2id, Passes: 2, Memory: 64, Parallelism: 1}, "password", 8, 16)
+
+	tests := []struct {
+		encoded    string
+		algorithm  string
+		saltLength int
+		iterations int64
+		keyLength  int
+	}{
+		{legacy, "", 16, 1000, 32},
+		{phc, "pbkdf2-sha512", 12, 2000, 24},
+		{argon2, "argon2id", 8, 2, 16},
+	}
+
+	for _, test := range tests {
+		h, err := ParsePasswordHash(test.encoded)
+		if err != nil {
+			t.Fatalf("error in TestParsePasswordHash function while parsing %q: %s", test.encoded, err.Error())
+		}
+
+		if h.Encoded() != test.encoded || h.Algorithm() != test.algorithm || len(h.Salt()) != test.saltLength ||
+			h.Iterations() != test.iterations || h.KeyLength() != test.keyLength || (h.KDF() == nil) != (test.algorithm == "") {
+			t.Errorf("error in TestParsePasswordHash function: unexpected parameters of %q", test.encoded)
+		}
+
+		// the hash is never printed
+		key := test.encoded[strings.LastIndexAny(test.encoded, ":$")+1:]
+		for _, printed := range []string{h.String(), fmt.Sprintf("%v %+v %#v %s", h, h, h, h), fmt.Sprint(struct{ Hash PasswordHash }{h})} {
+			if !strings.Contains(printed, "redacted") || strings.Contains(printed, key) {
+				t.Errorf("error in TestParsePasswordHash function: %q is not redacted", printed)
+			}
+		}
+	}
+
+	// invalid strings
+	for _, invalid := range []string{"", "abc", "c2FsdA==:0:a2V5", "c2FsdA==:1000:", "$pbkdf2-sha256$i=01$c2FsdA$a2V5", "$unknown$i=1$c2FsdA$a2V5", "$pbkdf2-sha256$i=1$c2FsdA$"} {
+		if _, err := ParsePasswordHash(invalid); err == nil {
+			t.Errorf("error in TestParsePasswordHash function: %q was parsed", invalid)
+		}
+	}
+
+	// strings with an empty hash are not loaded from the database or JSON either
+	var h PasswordHash
+	if err := h.Scan("$pbkdf2-sha256$i=1$c2FsdA$"); err == nil {
+		t.Errorf("error in TestParsePasswordHash function: empty hash was scanned")
+	}
+
+	if err := json.Unmarshal([]byte(`"$pbkdf2-sha256$i=1$c2FsdA$"`), &h); err == nil {
+		t.Errorf("error in TestParsePasswordHash function: empty hash was unmarshaled")
+	}
+
+	if _, err := ParsePasswordHash("$unknown$i=1$c2FsdA$a2V5"); !errors.Is(err, ErrUnknownKDF) {
+		t.Errorf("error in TestParsePasswordHash function: unknown KDF gave %v", err)
+	}
+}
+
+// tests the JSON, text and SQL round trips
+func TestPasswordHashEncoding(t *testing.T) {
+	encoded, _ := EncodePasswordKDF(PBKDF2Parameters{Hash: crypto.SHA256, Iterations: 1000}, "password", 16, 32)
+	h, _ := ParsePasswordHash(encoded)
+
+	// JSON with an empty and a set hash
+	type user struct {
+		Name     string       `json:"name"`
+		Password PasswordHash `json:"password"`
+	}
+
+	data, err := json.Marshal([]user{{"empty", PasswordHash{}}, {"alice", h}})
+	if err != nil || !strings.Contains(string(data), `"password":null`) || !strings.Contains(string(data), encoded) {
+		t.Fatalf("error in TestPasswordHashEncoding function: JSON encoding gave %s, %v", data, err)
+	}
+
+	var users []user
+	if err := json.Unmarshal(data, &users); err != nil || !users[0].Password.IsZero() || users[1].Password.Encoded() != encoded {
+		t.Errorf("error in TestPasswordHashEncoding function: JSON decoding gave %+v, %v", users, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"password":"c2FsdA==:0:a2V5"}`), &users[0]); err == nil {
+		t.Errorf("error in TestPasswordHashEncoding function: invalid JSON hash was decoded")
+	}
+
+	// text
+	text, _ := h.MarshalText()
+	var fromText PasswordHash
+	if err := fromText.UnmarshalText(text); err != nil || fromText.Encoded() != encoded {
+		t.Errorf("error in TestPasswordHashEncoding function: text round trip gave %v", err)
+	}
+
+	// SQL, NULL is the zero value
+	value, err := h.Value()
+	if err != nil || value != encoded {
+		t.Errorf("error in TestPasswordHashEncoding function: Value gave %v, %v", value, err)
+	}
+
+	if value, _ := (PasswordHash{}).Value(); value != nil {
+		t.Errorf("error in TestPasswordHashEncoding function: zero hash was stored as %v", value)
+	}
+
+	for _, src := range []any{encoded, []byte(encoded), nil} {
+		var scanned PasswordHash
+		if err := scanned.Scan(src); err != nil || src != nil && scanned.Encoded() != encoded || src == nil && !scanned.IsZero() {
+			t.Errorf("error in TestPasswordHashEncoding function: scanning %T gave %v", src, err)
+		}
+	}
+
+	for _, src := range []any{"not a hash", 42} {
+		var scanned PasswordHash
+		if err := scanned.Scan(src); err == nil {
+			t.Errorf("error in TestPasswordHashEncoding function: %v was scanned", src)
+		}
+	}
+
+	// the stored string verifies the password
+	if ok, err := VerifyPasswordKDF("password", h.Encoded()); err != nil || !ok {
+		t.Errorf("error in TestPasswordHashEncoding function: password did not verify, %v", err)
+	}
+}
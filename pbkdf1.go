@@ -10,8 +10,18 @@ import (
 
 // PBKDF1 is a function that implements the PBKDF1 algorithm
 // It is based on the RFC8018(https://datatracker.ietf.org/doc/html/rfc8018)
-// it implements the PBKDF function type
+// it implements the PBKDF function type, it is rejected in compliance mode
 func PBKDF1(hash crypto.Hash, P []byte, S []byte, c int64, dkLen int64) ([]byte, error) {
+	// PBKDF1 is not approved by SP 800-132
+	if currentCompliancePolicy() != nil {
+		return nil, fmt.Errorf("error in PBKDF1 function: %w: PBKDF1 is not approved, use PBKDF2", ErrNotCompliant)
+	}
+
+	// check if the hash is available
+	if !hash.Available() {
+		return nil, fmt.Errorf("error in PBKDF1 function: hash function %d is not available", hash)
+	}
+
 	// check the derived key length
 	if int64(hash.Size()) < dkLen {
 		return nil, errors.New("error in PBKDF1 function: derived key too long")
@@ -2,33 +2,102 @@ package pbkdf
 
 import (
 	"crypto"
-	"errors"
+	"crypto/hmac"
 	"fmt"
+	"hash"
 )
 
 // PBKDF2 is a function that implements the PBKDF2 algorithm
-// It is based on the RFC8018(https://datatracker.ietf.org/doc/html/rfc8018)
+// It is based on the RFC8018(https://datatracker.ietf.org/doc/html/rfc8018) and uses HMAC with the given hash as the PRF
 // it implements the PBKDF function type
+// when compliance mode is enabled the parameters are checked against NIST SP 800-132 first
 func PBKDF2(hash crypto.Hash, P []byte, S []byte, c int64, dkLen int64) ([]byte, error) {
-	// hash length
-	hLen := int64(hash.Size())
+	// check the parameters against the compliance policy
+	if err := checkPBKDF2Compliance(hash, S, c, dkLen); err != nil {
+		return nil, fmt.Errorf("error in PBKDF2 function: %w", err)
+	}
+
+	// check if the hash is available
+	if !hash.Available() {
+		return nil, fmt.Errorf("error in PBKDF2 function: hash function %d is not available", hash)
+	}
+
+	return pbkdf2("PBKDF2", S, c, dkLen, newHMACPRF(hash, P))
+}
+
+// PBKDF2Legacy is the PBKDF2 construction used by the first versions of this package
+// It uses hash(P || U) as the PRF instead of HMAC, so its output does not match RFC8018
+// it is only kept to verify passwords encoded before PBKDF2 was fixed, new passwords must use PBKDF2
+// it implements the PBKDF function type, it is rejected in compliance mode
+func PBKDF2Legacy(hash crypto.Hash, P []byte, S []byte, c int64, dkLen int64) ([]byte, error) {
+	// the legacy construction is not an approved PRF
+	if currentCompliancePolicy() != nil {
+		return nil, fmt.Errorf("error in PBKDF2Legacy function: %w: the legacy construction does not use HMAC", ErrNotCompliant)
+	}
+
+	// check if the hash is available
+	if !hash.Available() {
+		return nil, fmt.Errorf("error in PBKDF2Legacy function: hash function %d is not available", hash)
+	}
+
+	return pbkdf2("PBKDF2Legacy", S, c, dkLen, newLegacyPRF(hash, P))
+}
+
+// newHMACPRF returns a function creating HMAC keyed with the password
+func newHMACPRF(h crypto.Hash, P []byte) func() hash.Hash {
+	return func() hash.Hash { return hmac.New(h.New, P) }
+}
+
+// newLegacyPRF returns a function creating the PRF of PBKDF2Legacy
+func newLegacyPRF(h crypto.Hash, P []byte) func() hash.Hash {
+	return func() hash.Hash { return &legacyPRF{Hash: h.New(), P: P} }
+}
+
+// legacyPRF is the hash(P || U) PRF of PBKDF2Legacy
+// the password is written again every time the hash is reset
+type legacyPRF struct {
+	hash.Hash
+	P []byte
+}
+
+// Reset resets the hash and writes the password
+func (l *legacyPRF) Reset() {
+	l.Hash.Reset()
+	l.Hash.Write(l.P)
+}
+
+// pbkdf2 runs the PBKDF2 algorithm with the PRF returned by newPRF
+// The name parameter is the name of the calling function used in error messages
+// The newPRF parameter must return the PRF already keyed with the password, hLen is its output size
+func pbkdf2(name string, S []byte, c int64, dkLen int64, newPRF func() hash.Hash) ([]byte, error) {
+	// create PRF(pseudo-random function)
+	PRF := newPRF()
+	if PRF == nil {
+		return nil, fmt.Errorf("error in %s function: PRF rejected the password", name)
+	}
+
+	// PRF output length
+	hLen := int64(PRF.Size())
+	if hLen <= 0 {
+		return nil, fmt.Errorf("error in %s function: PRF output size must be positive", name)
+	}
 
 	// max key length
 	maxKeyLen := (int64(1<<32) - int64(1)) * hLen
 
 	// check if dkLen is less than maxKeyLen
 	if dkLen > maxKeyLen {
-		return nil, errors.New("error in PBKDF2 function: derived key too long")
+		return nil, fmt.Errorf("error in %s function: derived key too long", name)
 	}
 
 	// check if derived key length is negative
 	if dkLen < 0 {
-		return nil, errors.New("error in PBKDF2 function: derived key length must not be negative")
+		return nil, fmt.Errorf("error in %s function: derived key length must not be negative", name)
 	}
 
 	// check if iteration count is negative
 	if c <= 0 {
-		return nil, errors.New("error in PBKDF2 function: iteration count must not be negative")
+		return nil, fmt.Errorf("error in %s function: iteration count must not be negative", name)
 	}
 
 	// calculate parameters l and r
@@ -43,67 +112,20 @@ func PBKDF2(hash crypto.Hash, P []byte, S []byte, c int64, dkLen int64) ([]byte,
 	// make slice to hold the derived key(DK)
 	DK := make([]byte, dkLen)
 
-	// create PRF(pseudo-random function)
-	PRF := hash.New()
-
-	// F function => F(P, S, c, i), P, S, c are passed through closures
-	F := func(i int64) ([]byte, error) {
-		// start last iterations U as S + int32(i)[little endian]
-		lastU := make([]byte, len(S)+4)
-
-		// copy salt
-		copy(lastU, S)
-
-		// set int32(i) bytes
-		copy(lastU[len(S):], ConvertUnsignedIntegerToByteSlice(uint64(i), 4, false))
-
-		// create slice to hold result
-		result := make([]byte, hLen)
-
-		// iterate c(iteration count) times
-		for j := int64(0); j < c; j++ {
-			// write password
-			n, err := PRF.Write(P)
-
-			// handle errors/incomplete writes
-			if err != nil {
-				return nil, fmt.Errorf("error in PBKDF2 function while writing to PRF: %s", err.Error())
-			} else if n != len(P) {
-				return nil, fmt.Errorf("error in PBKDF2 function while writing to PRF: incomplete write to PRF")
-			}
-
-			// write last U
-			n, err = PRF.Write(lastU)
-
-			// handle errors/incomplete writes
-			if err != nil {
-				return nil, fmt.Errorf("error in PBKDF2 function while writing to PRF: %s", err.Error())
-			} else if n != len(lastU) {
-				return nil, fmt.Errorf("error in PBKDF2 function while writing to PRF: incomplete write to PRF")
-			}
-
-			// set lastU as the hash of P || lastU
-			lastU = PRF.Sum(nil)
-
-			// bitwise XOR the result with last U
-			for k := 0; k < len(result); k++ {
-				result[k] ^= lastU[k]
-			}
-
-			// reset PRF
-			PRF.Reset()
-		}
-
-		// return the result
-		return result, nil
+	// a zero length key has no blocks
+	if l == 0 {
+		return DK, nil
 	}
 
+	// start the PRF in the reset state
+	PRF.Reset()
+
 	// current index of the DK slice
 	currIndex := int64(0)
 	// iterate calling F l times
 	// if 1 <= i < l, append the result of F(P, S, c, i)
 	for i := int64(1); i < l; i++ {
-		f, err := F(i)
+		f, err := pbkdf2F(name, PRF, S, c, i)
 
 		if err != nil {
 			return nil, err
@@ -115,7 +137,7 @@ func PBKDF2(hash crypto.Hash, P []byte, S []byte, c int64, dkLen int64) ([]byte,
 		currIndex += hLen
 	}
 
-	f, err := F(l)
+	f, err := pbkdf2F(name, PRF, S, c, l)
 
 	if err != nil {
 		return nil, err
@@ -132,3 +154,55 @@ func PBKDF2(hash crypto.Hash, P []byte, S []byte, c int64, dkLen int64) ([]byte,
 	// return the derived key(DK)
 	return DK, nil
 }
+
+// pbkdf2F is the F function of PBKDF2, F(P, S, c, i) = U_1 ^ U_2 ^ ... ^ U_c
+// The PRF parameter is the PRF keyed with the password in the reset state, it is left in the reset state
+// The i parameter is the block index, starting from 1
+func pbkdf2F(name string, PRF hash.Hash, S []byte, c int64, i int64) ([]byte, error) {
+	// PRF output length
+	hLen := PRF.Size()
+
+	// start last iterations U as S + int32(i)[big endian]
+	lastU := make([]byte, len(S)+4)
+
+	// copy salt
+	copy(lastU, S)
+
+	// set int32(i) bytes
+	copy(lastU[len(S):], ConvertUnsignedIntegerToByteSlice(uint64(i), 4, false))
+
+	// create slice to hold result
+	result := make([]byte, hLen)
+
+	// iterate c(iteration count) times
+	for j := int64(0); j < c; j++ {
+		// write last U
+		n, err := PRF.Write(lastU)
+
+		// handle errors/incomplete writes
+		if err != nil {
+			return nil, fmt.Errorf("error in %s function while writing to PRF: %s", name, err.Error())
+		} else if n != len(lastU) {
+			return nil, fmt.Errorf("error in %s function while writing to PRF: incomplete write to PRF", name)
+		}
+
+		// set lastU as PRF(P, lastU)
+		lastU = PRF.Sum(lastU[:0])
+
+		// check the PRF output, a custom PRF may not match its Size
+		if len(lastU) != hLen {
+			return nil, fmt.Errorf("error in %s function: PRF returned %d bytes instead of %d", name, len(lastU), hLen)
+		}
+
+		// bitwise XOR the result with last U
+		for k := 0; k < len(result); k++ {
+			result[k] ^= lastU[k]
+		}
+
+		// reset PRF
+		PRF.Reset()
+	}
+
+	// return the result
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package pbkdf
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+)
+
+// pbkdf2MaxBlocks is the maximum number of PBKDF2 blocks, the block index is a 32 bit integer
+const pbkdf2MaxBlocks int64 = 1<<32 - 1
+
+// PBKDF2Reader is an io.Reader returning the PBKDF2 output as a stream
+// the blocks are computed when Read needs them, so reading n bytes costs the same as PBKDF2 with dkLen = n
+// the first n bytes read are equal to the output of PBKDF2 with dkLen = n
+// after (2^32 - 1) * hLen bytes Read returns io.EOF, a PBKDF2Reader must not be used by several goroutines at once
+type PBKDF2Reader struct {
+	prf   hash.Hash
+	S     []byte
+	c     int64
+	block int64
+	buf   []byte
+	err   error
+}
+
+// NewPBKDF2Reader creates a PBKDF2Reader
+// It is based on the RFC8018(https://datatracker.ietf.org/doc/html/rfc8018) and uses HMAC with the given hash as the PRF
+// hash: the hash function to be used(can be any crypto.Hash)
+// P: the password(as a byte slice)
+// S: the salt(as a byte slice), it is copied
+// c: the iteration count
+// when compliance mode is enabled the parameters are checked against NIST SP 800-132 first
+func NewPBKDF2Reader(hash crypto.Hash, P []byte, S []byte, c int64) (*PBKDF2Reader, error) {
+	// check the parameters against the compliance policy, keys of any length can be read from the stream
+	if err := checkPBKDF2Compliance(hash, S, c, math.MaxInt64); err != nil {
+		return nil, fmt.Errorf("error in NewPBKDF2Reader function: %w", err)
+	}
+
+	// check if the hash is available
+	if !hash.Available() {
+		return nil, fmt.Errorf("error in NewPBKDF2Reader function: hash function %d is not available", hash)
+	}
+
+	// check if iteration count is positive
+	if c <= 0 {
+		return nil, errors.New("error in NewPBKDF2Reader function: iteration count must be positive")
+	}
+
+	// create the PRF in the reset state
+	PRF := newHMACPRF(hash, P)()
+	PRF.Reset()
+
+	return &PBKDF2Reader{prf: PRF, S: append([]byte(nil), S...), c: c, block: 1}, nil
+}
+
+// Read fills p with the next bytes of the PBKDF2 output
+// it returns io.EOF once the (2^32 - 1) blocks have been read
+func (r *PBKDF2Reader) Read(p []byte) (int, error) {
+	n := 0
+
+	for n < len(p) {
+		// compute the next block when the current one has been read
+		if len(r.buf) == 0 {
+			if r.err != nil {
+				break
+			}
+
+			if r.block > pbkdf2MaxBlocks {
+				r.err = io.EOF
+				break
+			}
+
+			block, err := pbkdf2F("PBKDF2Reader.Read", r.prf, r.S, r.c, r.block)
+
+			// check if an error occurred
+			if err != nil {
+				r.err = err
+				break
+			}
+
+			r.block++
+			r.buf = block
+		}
+
+		// copy the unread bytes of the block
+		copied := copy(p[n:], r.buf)
+		r.buf = r.buf[copied:]
+		n += copied
+	}
+
+	// bytes read before an error are returned first
+	if n > 0 {
+		return n, nil
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	return 0, r.err
+}
@@ -0,0 +1,58 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// tests that the stream matches the one-shot output for any split of the reads
+func TestPBKDF2Reader(t *testing.T) {
+	expected, err := PBKDF2(crypto.SHA256, []byte("password"), []byte("salt"), 10, 200)
+	if err != nil {
+		t.Fatalf("error in TestPBKDF2Reader function while deriving key: %s", err.Error())
+	}
+
+	for _, size := range []int{1, 7, 32, 33, 200} {
+		reader, err := NewPBKDF2Reader(crypto.SHA256, []byte("password"), []byte("salt"), 10)
+		if err != nil {
+			t.Fatalf("error in TestPBKDF2Reader function while creating reader: %s", err.Error())
+		}
+
+		// read in chunks of size bytes
+		var stream []byte
+		chunk := make([]byte, size)
+		for len(stream) < len(expected) {
+			n, err := reader.Read(chunk[:min(size, len(expected)-len(stream))])
+			if err != nil {
+				t.Fatalf("error in TestPBKDF2Reader function while reading: %s", err.Error())
+			}
+
+			stream = append(stream, chunk[:n]...)
+		}
+
+		if !bytes.Equal(stream, expected) {
+			t.Errorf("error in TestPBKDF2Reader function: reads of %d bytes gave %x, want %x", size, stream, expected)
+		}
+	}
+
+	// the stream ends after 2^32 - 1 blocks
+	reader, _ := NewPBKDF2Reader(crypto.SHA256, []byte("password"), []byte("salt"), 1)
+	reader.block = pbkdf2MaxBlocks
+
+	if n, err := io.ReadFull(reader, make([]byte, 32)); n != 32 || err != nil {
+		t.Errorf("error in TestPBKDF2Reader function: last block gave %d bytes(%v)", n, err)
+	}
+
+	if n, err := reader.Read(make([]byte, 1)); n != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("error in TestPBKDF2Reader function: read after the last block gave %d bytes(%v)", n, err)
+	}
+
+	// invalid parameters
+	if _, err := NewPBKDF2Reader(crypto.SHA256, []byte("password"), []byte("salt"), 0); err == nil || !strings.Contains(err.Error(), "must be positive") {
+		t.Errorf("error in TestPBKDF2Reader function: zero iteration count gave %v", err)
+	}
+}
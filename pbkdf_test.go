@@ -2,6 +2,7 @@ package pbkdf
 
 import (
 	"crypto"
+	"fmt"
 	"testing"
 )
 
@@ -211,3 +212,68 @@ func BenchmarkCheckPasswordPBKDF2(b *testing.B) {
 		VerifyPasswordPBKDF2(crypto.SHA512, passwords[i], encodedPasswords[i])
 	}
 }
+
+// tests that PBKDF2 uses HMAC(RFC 6070) and that passwords of the hash(P || U) construction of earlier versions still verify with PBKDF2Legacy
+func TestPBKDF2Construction(t *testing.T) {
+	key, err := PBKDF2(crypto.SHA1, []byte("password"), []byte("salt"), 2, 20)
+	if err != nil || fmt.Sprintf("%x", key) != "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957" {
+		t.Errorf("error in TestPBKDF2Construction function: PBKDF2 gave %x, %v", key, err)
+	}
+
+	// a password encoded by an earlier version
+	legacy := "c2FsdA==:2:nar1Fck3qez3lS3S01tE0oMFuKg="
+
+	if ok, err := VerifyPasswordPBKDF2Legacy(crypto.SHA1, "password", legacy); err != nil || !ok {
+		t.Errorf("error in TestPBKDF2Construction function: legacy password does not verify with PBKDF2Legacy: %v", err)
+	}
+
+	if ok, err := VerifyPasswordPBKDF2(crypto.SHA1, "password", legacy); err != nil || ok {
+		t.Errorf("error in TestPBKDF2Construction function: legacy password verifies with PBKDF2: %v", err)
+	}
+}
+
+// tests the migration of the passwords encoded before PBKDF2 used HMAC
+func TestLegacyPBKDF2Fallback(t *testing.T) {
+	legacy := "c2FsdA==:2:nar1Fck3qez3lS3S01tE0oMFuKg="
+
+	hasher, _ := NewHasher(WithHash(crypto.SHA1), WithIterationCount(2), WithSaltLength(4), WithKeyLength(20))
+	if ok, err := hasher.Verify("password", legacy); err != nil || ok {
+		t.Errorf("error in TestLegacyPBKDF2Fallback function: legacy password verifies without the fallback: %v", err)
+	}
+
+	hasher, _ = NewHasher(WithHash(crypto.SHA1), WithIterationCount(2), WithSaltLength(4), WithKeyLength(20), WithLegacyPBKDF2Fallback())
+	if ok, err := hasher.Verify("password", legacy); err != nil || !ok {
+		t.Errorf("error in TestLegacyPBKDF2Fallback function: legacy password does not verify with the fallback: %v", err)
+	}
+
+	// the legacy string is replaced even though its parameters are the ones of the Hasher
+	ok, rehashed, err := hasher.VerifyAndRehash("password", legacy)
+	if err != nil || !ok || rehashed == "" {
+		t.Fatalf("error in TestLegacyPBKDF2Fallback function: VerifyAndRehash gave %v, %q, %v", ok, rehashed, err)
+	}
+
+	if ok, err := VerifyPasswordPBKDF2(crypto.SHA1, "password", rehashed); err != nil || !ok {
+		t.Errorf("error in TestLegacyPBKDF2Fallback function: rehashed password does not verify with PBKDF2: %v", err)
+	}
+
+	if ok, again, err := hasher.VerifyAndRehash("password", rehashed); err != nil || !ok || again != "" {
+		t.Errorf("error in TestLegacyPBKDF2Fallback function: rehashed password gave %v, %q, %v", ok, again, err)
+	}
+
+	if ok, rehashed, err := hasher.VerifyAndRehash("wrong password", legacy); err != nil || ok || rehashed != "" {
+		t.Errorf("error in TestLegacyPBKDF2Fallback function: wrong password gave %v, %q, %v", ok, rehashed, err)
+	}
+}
+
+// tests that a string with an empty hash does not verify any password
+func TestVerifyPasswordEmptyHash(t *testing.T) {
+	hasher, _ := NewHasher()
+
+	if ok, err := VerifyPasswordPBKDF2(crypto.SHA256, "anything", "c2FsdA==:1:"); err == nil || ok {
+		t.Errorf("error in TestVerifyPasswordEmptyHash function: VerifyPasswordPBKDF2 gave %v, %v", ok, err)
+	}
+
+	if ok, err := hasher.Verify("anything", "c2FsdA==:1:"); err == nil || ok {
+		t.Errorf("error in TestVerifyPasswordEmptyHash function: Hasher.Verify gave %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,204 @@
+package pbkdf
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// phcEncoding is the base64 variant of the PHC string format, standard alphabet without padding
+// it is strict so every value has a single encoding
+var phcEncoding = base64.RawStdEncoding.Strict()
+
+// phcParam is a name=value parameter of a PHC string
+type phcParam struct {
+	name  string
+	value string
+}
+
+// phcString is a password hash in the PHC string format
+// $<id>[$v=<version>][$<param>=<value>(,<param>=<value>)*][$<salt>[$<hash>]]
+// salt and hash are base64 encoded without padding
+type phcString struct {
+	id         string
+	hasVersion bool
+	version    int64
+	params     []phcParam
+	salt       []byte
+	hash       []byte
+}
+
+// parsePHCString parses a PHC string
+func parsePHCString(s string) (*phcString, error) {
+	// check the leading separator
+	if !strings.HasPrefix(s, "$") {
+		return nil, errors.New("PHC string must start with $")
+	}
+
+	// split the fields
+	fields := strings.Split(s[1:], "$")
+	p := &phcString{id: fields[0]}
+	fields = fields[1:]
+
+	// check the identifier
+	if !isPHCName(p.id) {
+		return nil, fmt.Errorf("invalid PHC identifier %q", p.id)
+	}
+
+	// optional version
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "v=") && !strings.Contains(fields[0], ",") {
+		version, err := strconv.ParseInt(fields[0][2:], 10, 64)
+
+		// check if an error occurred
+		if err != nil || version < 0 || strconv.FormatInt(version, 10) != fields[0][2:] {
+			return nil, fmt.Errorf("invalid PHC version %q", fields[0])
+		}
+
+		p.hasVersion, p.version = true, version
+		fields = fields[1:]
+	}
+
+	// optional parameters
+	if len(fields) > 0 && strings.Contains(fields[0], "=") {
+		for _, param := range strings.Split(fields[0], ",") {
+			name, value, found := strings.Cut(param, "=")
+
+			// check the parameter
+			if !found || !isPHCName(name) || value == "" || strings.ContainsAny(value, "$,=") {
+				return nil, fmt.Errorf("invalid PHC parameter %q", param)
+			}
+
+			p.params = append(p.params, phcParam{name: name, value: value})
+		}
+
+		fields = fields[1:]
+	}
+
+	// optional salt and hash
+	if len(fields) > 2 {
+		return nil, errors.New("too many fields in PHC string")
+	}
+
+	if len(fields) > 0 {
+		salt, err := phcEncoding.DecodeString(fields[0])
+
+		// check if an error occurred
+		if err != nil {
+			return nil, fmt.Errorf("invalid PHC salt: %s", err.Error())
+		}
+
+		p.salt = salt
+	}
+
+	if len(fields) > 1 {
+		hash, err := phcEncoding.DecodeString(fields[1])
+
+		// check if an error occurred
+		if err != nil {
+			return nil, fmt.Errorf("invalid PHC hash: %s", err.Error())
+		}
+
+		p.hash = hash
+	}
+
+	// return the parsed string
+	return p, nil
+}
+
+// String formats the PHC string
+func (p *phcString) String() string {
+	var b strings.Builder
+
+	b.WriteString("$" + p.id)
+
+	// version
+	if p.hasVersion {
+		b.WriteString("$v=" + strconv.FormatInt(p.version, 10))
+	}
+
+	// parameters
+	for i, param := range p.params {
+		if i == 0 {
+			b.WriteString("$")
+		} else {
+			b.WriteString(",")
+		}
+
+		b.WriteString(param.name + "=" + param.value)
+	}
+
+	// salt and hash
+	if p.salt != nil {
+		b.WriteString("$" + phcEncoding.EncodeToString(p.salt))
+
+		if p.hash != nil {
+			b.WriteString("$" + phcEncoding.EncodeToString(p.hash))
+		}
+	}
+
+	return b.String()
+}
+
+// param returns the value of a parameter and whether it is present
+func (p *phcString) param(name string) (string, bool) {
+	for _, param := range p.params {
+		if param.name == name {
+			return param.value, true
+		}
+	}
+
+	return "", false
+}
+
+// intParam returns a required integer parameter
+func (p *phcString) intParam(name string) (int64, error) {
+	// get the parameter
+	value, ok := p.param(name)
+	if !ok {
+		return 0, fmt.Errorf("missing PHC parameter %q", name)
+	}
+
+	// parse the value, only the canonical decimal form is accepted
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != value {
+		return 0, fmt.Errorf("invalid PHC parameter %s=%s", name, value)
+	}
+
+	return n, nil
+}
+
+// checkParams checks that the PHC string only has the given parameters, in that order
+func (p *phcString) checkParams(names ...string) error {
+	// check the count
+	if len(p.params) != len(names) {
+		return fmt.Errorf("PHC string must have the parameters %s", strings.Join(names, ","))
+	}
+
+	// check the names
+	for i, param := range p.params {
+		if param.name != names[i] {
+			return fmt.Errorf("PHC string must have the parameters %s", strings.Join(names, ","))
+		}
+	}
+
+	return nil
+}
+
+// isPHCName reports whether s is a valid PHC identifier or parameter name
+func isPHCName(s string) bool {
+	// check the length
+	if len(s) == 0 || len(s) > 32 {
+		return false
+	}
+
+	// check the characters
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+
+	return true
+}
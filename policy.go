@@ -0,0 +1,309 @@
+package pbkdf
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordViolationCode identifies a rule broken by a password
+// codes are stable strings meant to be used as keys for localized messages
+type PasswordViolationCode string
+
+// violation codes returned by PasswordPolicy
+const (
+	ViolationInvalidEncoding        PasswordViolationCode = "password.invalid_encoding"
+	ViolationTooShort               PasswordViolationCode = "password.too_short"
+	ViolationTooLong                PasswordViolationCode = "password.too_long"
+	ViolationBlocklisted            PasswordViolationCode = "password.blocklisted"
+	ViolationContextWord            PasswordViolationCode = "password.context_word"
+	ViolationRepeatedCharacters     PasswordViolationCode = "password.repeated_characters"
+	ViolationSequentialCharacters   PasswordViolationCode = "password.sequential_characters"
+	ViolationMissingLowercase       PasswordViolationCode = "password.missing_lowercase"
+	ViolationMissingUppercase       PasswordViolationCode = "password.missing_uppercase"
+	ViolationMissingDigit           PasswordViolationCode = "password.missing_digit"
+	ViolationMissingSymbol          PasswordViolationCode = "password.missing_symbol"
+	ViolationTooFewCharacterClasses PasswordViolationCode = "password.too_few_character_classes"
+)
+
+// PasswordViolation is a single rule broken by a password
+// Params holds the values needed to render a localized message(for example "min" for ViolationTooShort)
+// the password itself is never stored in a violation
+type PasswordViolation struct {
+	Code   PasswordViolationCode `json:"code"`
+	Params map[string]any        `json:"params,omitempty"`
+}
+
+// String returns an english description of the violation
+func (v PasswordViolation) String() string {
+	switch v.Code {
+	case ViolationInvalidEncoding:
+		return "password is not valid UTF-8"
+	case ViolationTooShort:
+		return fmt.Sprintf("password must have at least %v characters", v.Params["min"])
+	case ViolationTooLong:
+		return fmt.Sprintf("password must have at most %v characters", v.Params["max"])
+	case ViolationBlocklisted:
+		return "password is too common"
+	case ViolationContextWord:
+		return fmt.Sprintf("password must not contain %q", v.Params["word"])
+	case ViolationRepeatedCharacters:
+		return fmt.Sprintf("password must not repeat a character more than %v times in a row", v.Params["max"])
+	case ViolationSequentialCharacters:
+		return fmt.Sprintf("password must not contain more than %v sequential characters", v.Params["max"])
+	case ViolationMissingLowercase:
+		return "password must contain a lowercase letter"
+	case ViolationMissingUppercase:
+		return "password must contain an uppercase letter"
+	case ViolationMissingDigit:
+		return "password must contain a digit"
+	case ViolationMissingSymbol:
+		return "password must contain a symbol"
+	case ViolationTooFewCharacterClasses:
+		return fmt.Sprintf("password must contain at least %v of lowercase, uppercase, digits and symbols", v.Params["min"])
+	}
+
+	return string(v.Code)
+}
+
+// PasswordPolicyError is returned when a password breaks one or more rules of a PasswordPolicy
+// all the violations are reported, not only the first one
+type PasswordPolicyError struct {
+	Violations []PasswordViolation `json:"violations"`
+}
+
+// Error implements the error interface
+func (e *PasswordPolicyError) Error() string {
+	// collect the messages
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.String()
+	}
+
+	return "password rejected by policy: " + strings.Join(messages, "; ")
+}
+
+// Has reports whether the error contains a violation with the given code
+func (e *PasswordPolicyError) Has(code PasswordViolationCode) bool {
+	for _, v := range e.Violations {
+		if v.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PasswordPolicy validates a candidate password before it is hashed
+// It follows NIST SP 800-63B section 5.1.1.2, lengths are counted in Unicode code points
+// A zero value field disables the corresponding rule
+// callers that accept non ASCII passwords should normalize them(NFKC) before checking and hashing
+type PasswordPolicy struct {
+	// MinLength is the minimum number of code points
+	MinLength int
+	// MaxLength is the maximum number of code points
+	MaxLength int
+	// Blocklist holds commonly used or compromised passwords, matched case-insensitively
+	Blocklist []string
+	// ContextWords holds words specific to the service(service name, company name, ...)
+	// passwords containing any of them are rejected, per-user words are passed to CheckWithContext
+	ContextWords []string
+	// MaxRepeatedCharacters is the longest allowed run of the same character("aaaa" is a run of 4)
+	MaxRepeatedCharacters int
+	// MaxSequentialCharacters is the longest allowed run of consecutive characters("abcd" and "4321" are runs of 4)
+	MaxSequentialCharacters int
+
+	// composition rules, SP 800-63B recommends against them so they are disabled by default
+	RequireLowercase    bool
+	RequireUppercase    bool
+	RequireDigit        bool
+	RequireSymbol       bool
+	MinCharacterClasses int
+}
+
+// minimum length of a context word, shorter words match too many passwords
+const minContextWordLength = 3
+
+// DefaultPasswordPolicy returns a policy with the SP 800-63B recommendations
+// at least 8 and at most 64 characters, no more than 3 repeated or sequential characters and no composition rules
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:               8,
+		MaxLength:               64,
+		MaxRepeatedCharacters:   3,
+		MaxSequentialCharacters: 3,
+	}
+}
+
+// Check returns every violation of the policy
+// The password parameter is the candidate password
+// an empty slice means the password is acceptable
+func (p *PasswordPolicy) Check(password string) []PasswordViolation {
+	return p.CheckWithContext(password)
+}
+
+// CheckWithContext returns every violation of the policy
+// The password parameter is the candidate password
+// The contextWords parameter holds per-user words like the username or email address
+// words are also split on non alphanumeric characters("john.smith@example.com" checks john, smith and example)
+func (p *PasswordPolicy) CheckWithContext(password string, contextWords ...string) []PasswordViolation {
+	violations := []PasswordViolation{}
+
+	// the remaining rules work on code points
+	if !utf8.ValidString(password) {
+		return append(violations, PasswordViolation{Code: ViolationInvalidEncoding})
+	}
+
+	runes := []rune(password)
+
+	// length rules
+	if p.MinLength > 0 && len(runes) < p.MinLength {
+		violations = append(violations, PasswordViolation{Code: ViolationTooShort, Params: map[string]any{"min": p.MinLength}})
+	}
+
+	if p.MaxLength > 0 && len(runes) > p.MaxLength {
+		violations = append(violations, PasswordViolation{Code: ViolationTooLong, Params: map[string]any{"max": p.MaxLength}})
+	}
+
+	// blocklist rule
+	for _, blocked := range p.Blocklist {
+		if strings.EqualFold(password, blocked) {
+			violations = append(violations, PasswordViolation{Code: ViolationBlocklisted})
+			break
+		}
+	}
+
+	// context word rule
+	lowerPassword := strings.ToLower(password)
+	for _, word := range contextTokens(append(append([]string{}, p.ContextWords...), contextWords...)) {
+		if strings.Contains(lowerPassword, word) {
+			violations = append(violations, PasswordViolation{Code: ViolationContextWord, Params: map[string]any{"word": word}})
+		}
+	}
+
+	// repeated and sequential character rules
+	if p.MaxRepeatedCharacters > 0 && longestRun(runes, 0) > p.MaxRepeatedCharacters {
+		violations = append(violations, PasswordViolation{Code: ViolationRepeatedCharacters, Params: map[string]any{"max": p.MaxRepeatedCharacters}})
+	}
+
+	if p.MaxSequentialCharacters > 0 && max(longestRun(runes, 1), longestRun(runes, -1)) > p.MaxSequentialCharacters {
+		violations = append(violations, PasswordViolation{Code: ViolationSequentialCharacters, Params: map[string]any{"max": p.MaxSequentialCharacters}})
+	}
+
+	// composition rules
+	hasLower, hasUpper, hasDigit, hasSymbol := false, false, false, false
+	for _, r := range runes {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireLowercase && !hasLower {
+		violations = append(violations, PasswordViolation{Code: ViolationMissingLowercase})
+	}
+
+	if p.RequireUppercase && !hasUpper {
+		violations = append(violations, PasswordViolation{Code: ViolationMissingUppercase})
+	}
+
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{Code: ViolationMissingDigit})
+	}
+
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordViolation{Code: ViolationMissingSymbol})
+	}
+
+	if p.MinCharacterClasses > 0 {
+		// count the character classes present
+		classes := 0
+		for _, present := range []bool{hasLower, hasUpper, hasDigit, hasSymbol} {
+			if present {
+				classes++
+			}
+		}
+
+		if classes < p.MinCharacterClasses {
+			violations = append(violations, PasswordViolation{Code: ViolationTooFewCharacterClasses, Params: map[string]any{"min": p.MinCharacterClasses}})
+		}
+	}
+
+	// return the violations
+	return violations
+}
+
+// ValidatePassword implements the PasswordValidator interface
+// it returns a *PasswordPolicyError holding every violation or nil if the password is acceptable
+func (p *PasswordPolicy) ValidatePassword(password string) error {
+	return p.ValidatePasswordWithContext(password)
+}
+
+// ValidatePasswordWithContext is like ValidatePassword with per-user context words
+func (p *PasswordPolicy) ValidatePasswordWithContext(password string, contextWords ...string) error {
+	// check the password
+	violations := p.CheckWithContext(password, contextWords...)
+
+	// check if any rule was broken
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+// contextTokens lowercases the context words and splits them on non alphanumeric characters
+// tokens shorter than minContextWordLength are dropped
+func contextTokens(words []string) []string {
+	tokens := []string{}
+	seen := map[string]bool{}
+
+	// add adds a token once
+	add := func(token string) {
+		if utf8.RuneCountInString(token) >= minContextWordLength && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	for _, word := range words {
+		word = strings.ToLower(word)
+
+		// the whole word and its parts
+		add(word)
+		for _, part := range strings.FieldsFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			add(part)
+		}
+	}
+
+	return tokens
+}
+
+// longestRun returns the length of the longest run of runes where each rune is the previous one plus step
+// a step of 0 finds repeated characters, 1 and -1 find ascending and descending sequences
+// letters are compared case-insensitively
+func longestRun(runes []rune, step rune) int {
+	// check for empty input
+	if len(runes) == 0 {
+		return 0
+	}
+
+	longest, current := 1, 1
+	for i := 1; i < len(runes); i++ {
+		if unicode.ToLower(runes[i]) == unicode.ToLower(runes[i-1])+step {
+			current++
+			longest = max(longest, current)
+		} else {
+			current = 1
+		}
+	}
+
+	return longest
+}
@@ -0,0 +1,74 @@
+package pbkdf
+
+import (
+	"errors"
+	"testing"
+)
+
+// tests the PasswordPolicy rules and the Hasher integration
+func TestPasswordPolicy(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	policy.Blocklist = []string{"Password1", "qwertyuiop"}
+	policy.ContextWords = []string{"ExampleCorp"}
+
+	tests := []struct {
+		password string
+		context  []string
+		want     []PasswordViolationCode
+	}{
+		{"correct horse battery staple", nil, nil},
+		{"short", nil, []PasswordViolationCode{ViolationTooShort}},
+		// 7 code points but 14 bytes, length is counted in code points
+		{"пароль!", nil, []PasswordViolationCode{ViolationTooShort}},
+		{"пароль!!", nil, nil},
+		{"QWERTYUIOP", nil, []PasswordViolationCode{ViolationBlocklisted}},
+		{"i love examplecorp", nil, []PasswordViolationCode{ViolationContextWord}},
+		{"hunter2-john-xyz", []string{"john.smith@mail.test"}, []PasswordViolationCode{ViolationContextWord}},
+		{"zzzzz-secret", nil, []PasswordViolationCode{ViolationRepeatedCharacters}},
+		{"my-abcd-secret", nil, []PasswordViolationCode{ViolationSequentialCharacters}},
+		{"my-4321-secret", nil, []PasswordViolationCode{ViolationSequentialCharacters}},
+		{"aaaa", nil, []PasswordViolationCode{ViolationTooShort, ViolationRepeatedCharacters}},
+		{string([]byte{0xff, 0xfe, 'a', 'b', 'c', 'd', 'e', 'f'}), nil, []PasswordViolationCode{ViolationInvalidEncoding}},
+	}
+
+	for _, test := range tests {
+		violations := policy.CheckWithContext(test.password, test.context...)
+
+		// compare the codes
+		if len(violations) != len(test.want) {
+			t.Errorf("error in TestPasswordPolicy function: %q got %v, want %v", test.password, violations, test.want)
+			continue
+		}
+
+		for i, v := range violations {
+			if v.Code != test.want[i] {
+				t.Errorf("error in TestPasswordPolicy function: %q got %v, want %v", test.password, violations, test.want)
+			}
+		}
+	}
+
+	// composition rules are optional
+	policy = &PasswordPolicy{RequireUppercase: true, RequireDigit: true, MinCharacterClasses: 3}
+	if err := policy.ValidatePassword("lowercase only"); err == nil {
+		t.Errorf("error in TestPasswordPolicy function: composition rules were not applied")
+	} else if policyErr := (*PasswordPolicyError)(nil); !errors.As(err, &policyErr) || len(policyErr.Violations) != 3 {
+		t.Errorf("error in TestPasswordPolicy function: unexpected error %v", err)
+	}
+
+	// the hasher runs the policy in Hash
+	hasher, err := NewHasher(WithIterationCount(1000), WithPasswordPolicy(DefaultPasswordPolicy()))
+	if err != nil {
+		t.Fatalf("error in TestPasswordPolicy function while creating hasher: %s", err.Error())
+	}
+
+	var policyErr *PasswordPolicyError
+	if _, err := hasher.HashWithContext("alice-in-wonderland", "alice"); !errors.As(err, &policyErr) || !policyErr.Has(ViolationContextWord) {
+		t.Errorf("error in TestPasswordPolicy function: context word was not rejected: %v", err)
+	}
+
+	if encoded, err := hasher.Hash("alice-in-wonderland"); err != nil {
+		t.Errorf("error in TestPasswordPolicy function: valid password was rejected: %s", err.Error())
+	} else if valid, err := hasher.Verify("alice-in-wonderland", encoded); err != nil || !valid {
+		t.Errorf("error in TestPasswordPolicy function: encoded password is not valid")
+	}
+}
@@ -0,0 +1,45 @@
+package pbkdf
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// PRF creates the keyed pseudorandom function PBKDF2WithPRF runs on
+// The key parameter is the password
+// the returned hash.Hash computes PRF(key, data) with Write(data) and Sum, Reset must restore the keyed initial state
+// it returns nil for a key it can not use
+// HMACPRF, CMACPRF and AESCMACPRF128 return or implement this type
+type PRF func(key []byte) hash.Hash
+
+// HMACPRF returns the HMAC PRF of any hash function
+// The newHash parameter creates the hash, it does not need to be registered in the crypto package
+// so BLAKE2, KangarooTwelve or an in-house hash can be used
+func HMACPRF(newHash func() hash.Hash) PRF {
+	return func(key []byte) hash.Hash {
+		return hmac.New(newHash, key)
+	}
+}
+
+// PBKDF2WithPRF is PBKDF2 running on any PRF instead of HMAC with a crypto.Hash
+// It is based on the RFC8018(https://datatracker.ietf.org/doc/html/rfc8018), the block size of the derived key is the PRF output size
+// prf: the PRF constructor(for example HMACPRF(sha256.New) or AESCMACPRF128)
+// P: the password(as a byte slice), it is the PRF key
+// S: the salt(as a byte slice)
+// c: the iteration count
+// dkLen: the byte length of the derived key
+// the PRF can not be checked against NIST SP 800-132, it is rejected in compliance mode, use PBKDF2 instead
+func PBKDF2WithPRF(prf PRF, P []byte, S []byte, c int64, dkLen int64) ([]byte, error) {
+	// an arbitrary PRF is not an approved one
+	if currentCompliancePolicy() != nil {
+		return nil, fmt.Errorf("error in PBKDF2WithPRF function: %w: the PRF can not be checked, use PBKDF2", ErrNotCompliant)
+	}
+
+	if prf == nil {
+		return nil, errors.New("error in PBKDF2WithPRF function: prf must not be nil")
+	}
+
+	return pbkdf2("PBKDF2WithPRF", S, c, dkLen, func() hash.Hash { return prf(P) })
+}
@@ -0,0 +1,72 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"testing"
+)
+
+// tests PBKDF2WithPRF with HMAC of registered and unregistered hashes
+func TestPBKDF2WithPRF(t *testing.T) {
+	// HMAC of a registered hash gives the PBKDF2 output
+	expected, err := PBKDF2(crypto.SHA256, []byte("password"), []byte("salt"), 100, 50)
+	if err != nil {
+		t.Fatalf("error in TestPBKDF2WithPRF function while deriving key: %s", err.Error())
+	}
+
+	DK, err := PBKDF2WithPRF(HMACPRF(sha256.New), []byte("password"), []byte("salt"), 100, 50)
+	if err != nil || !bytes.Equal(DK, expected) {
+		t.Errorf("error in TestPBKDF2WithPRF function: got %x, want %x(%v)", DK, expected, err)
+	}
+
+	// a hash that is not registered in the crypto package, BLAKE2b-160
+	blake2b160 := func() hash.Hash {
+		h, _ := NewBLAKE2b(20, nil)
+		return h
+	}
+
+	DK, err = PBKDF2WithPRF(HMACPRF(blake2b160), []byte("password"), []byte("salt"), 100, 50)
+	if err != nil || len(DK) != 50 {
+		t.Errorf("error in TestPBKDF2WithPRF function: BLAKE2b-160 gave %x(%v)", DK, err)
+	}
+
+	// PBKDF2WithPRF is refused in compliance mode
+	if err := EnableComplianceMode(DefaultCompliancePolicy()); err != nil {
+		t.Fatalf("error in TestPBKDF2WithPRF function while enabling compliance mode: %s", err.Error())
+	}
+	defer DisableComplianceMode()
+
+	if _, err := PBKDF2WithPRF(AESCMACPRF128, []byte("password"), make([]byte, 16), 1000, 16); !errors.Is(err, ErrNotCompliant) {
+		t.Errorf("error in TestPBKDF2WithPRF function: PBKDF2WithPRF was accepted in compliance mode(%v)", err)
+	}
+}
+
+// tests that CMAC gives the same result for any split of the message and that Sum does not change the state
+func TestCMACStreaming(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 32))
+	message := bytes.Repeat([]byte("0123456789"), 10)
+
+	for length := 0; length <= len(message); length++ {
+		// one write
+		mac, _ := NewCMAC(block)
+		mac.Write(message[:length])
+		expected := mac.Sum(nil)
+
+		// byte by byte, with a Sum in the middle
+		mac.Reset()
+		for i, b := range message[:length] {
+			if i == length/2 {
+				mac.Sum(nil)
+			}
+			mac.Write([]byte{b})
+		}
+
+		if sum := mac.Sum(nil); !bytes.Equal(sum, expected) {
+			t.Errorf("error in TestCMACStreaming function: length %d gave %x, want %x", length, sum, expected)
+		}
+	}
+}
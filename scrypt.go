@@ -0,0 +1,307 @@
+package pbkdf
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// DefaultScryptMaxMemory is the memory limit used by Scrypt and VerifyPasswordScrypt(1 GiB)
+// it protects verification from encoded passwords with huge parameters
+const DefaultScryptMaxMemory int64 = 1 << 30
+
+// ScryptMemory returns the number of bytes of memory scrypt needs for the given parameters
+// The N parameter is the CPU/memory cost
+// The r parameter is the block size
+// The p parameter is the parallelization
+func ScryptMemory(N, r, p int64) int64 {
+	// V holds N blocks, B holds p blocks and XY two blocks of 128*r bytes
+	return 128 * r * (N + p + 2)
+}
+
+// Scrypt is a function that implements the scrypt algorithm
+// It is based on the RFC7914(https://datatracker.ietf.org/doc/html/rfc7914) and uses this package's PBKDF2 with HMAC-SHA-256
+// P: the password(as a byte slice)
+// S: the salt(as a byte slice)
+// N: the CPU/memory cost, a power of 2 greater than 1
+// r: the block size
+// p: the parallelization
+// dkLen: the byte length of the derived key
+// the memory is limited to DefaultScryptMaxMemory, use ScryptWithMemoryLimit to change it
+// scrypt is not approved by SP 800-132, it is rejected in compliance mode
+func Scrypt(P []byte, S []byte, N, r, p int64, dkLen int64) ([]byte, error) {
+	return ScryptWithMemoryLimit(P, S, N, r, p, dkLen, DefaultScryptMaxMemory)
+}
+
+// ScryptWithMemoryLimit is like Scrypt with an explicit memory limit
+// The maxMemory parameter is the maximum number of bytes the derivation may allocate(see ScryptMemory)
+func ScryptWithMemoryLimit(P []byte, S []byte, N, r, p int64, dkLen int64, maxMemory int64) ([]byte, error) {
+	// scrypt is not an approved algorithm
+	if currentCompliancePolicy() != nil {
+		return nil, fmt.Errorf("error in Scrypt function: %w: scrypt is not approved", ErrNotCompliant)
+	}
+
+	// check the parameters
+	if err := checkScryptParameters(N, r, p, dkLen, maxMemory); err != nil {
+		return nil, fmt.Errorf("error in Scrypt function: %s", err.Error())
+	}
+
+	// B = PBKDF2-HMAC-SHA256(P, S, 1, p * 128 * r)
+	B, err := pbkdf2("Scrypt", S, 1, p*128*r, newHMACPRF(crypto.SHA256, P))
+
+	// check if an error occurred
+	if err != nil {
+		return nil, err
+	}
+
+	// run ROMix on every block of B, the memory of V and XY is shared
+	V := make([]uint32, 32*r*N)
+	XY := make([]uint32, 64*r)
+	for i := int64(0); i < p; i++ {
+		scryptROMix(B[i*128*r:(i+1)*128*r], r, N, V, XY)
+	}
+
+	// DK = PBKDF2-HMAC-SHA256(P, B, 1, dkLen)
+	return pbkdf2("Scrypt", B, 1, dkLen, newHMACPRF(crypto.SHA256, P))
+}
+
+// checkScryptParameters checks the scrypt parameters against RFC7914 and the memory limit
+func checkScryptParameters(N, r, p int64, dkLen int64, maxMemory int64) error {
+	switch {
+	case N <= 1 || N&(N-1) != 0:
+		return errors.New("N must be a power of 2 greater than 1")
+	case r <= 0:
+		return errors.New("r must be positive")
+	case p <= 0:
+		return errors.New("p must be positive")
+	case dkLen < 0:
+		return errors.New("derived key length must not be negative")
+	case r >= 1<<30 || p >= 1<<30 || r*p >= 1<<30:
+		return errors.New("r * p must be less than 2^30")
+	case N >= 1<<62 || r < 4 && N >= 1<<(16*r):
+		return errors.New("N must be less than 2^(128 * r / 8)")
+	}
+
+	// check the memory, dividing first so the product can't overflow
+	if r > maxMemory/128/(N+p+2) {
+		return fmt.Errorf("parameters need more than %d bytes of memory", maxMemory)
+	}
+
+	return nil
+}
+
+// scryptROMix runs the ROMix function of RFC7914 section 5 on B in place
+// V must hold 32*r*N words and XY 64*r words
+func scryptROMix(B []byte, r, N int64, V, XY []uint32) {
+	X := XY[:32*r]
+	Y := XY[32*r:]
+
+	// X = B, as little endian words
+	for i := range X {
+		X[i] = binary.LittleEndian.Uint32(B[4*i:])
+	}
+
+	// V[i] = X, X = BlockMix(X)
+	for i := int64(0); i < N; i++ {
+		copy(V[i*32*r:], X)
+		scryptBlockMix(X, Y, r)
+	}
+
+	// j = Integerify(X) mod N, X = BlockMix(X xor V[j])
+	for i := int64(0); i < N; i++ {
+		j := int64((uint64(X[(2*r-1)*16]) | uint64(X[(2*r-1)*16+1])<<32) & uint64(N-1))
+		for k, v := range V[j*32*r : (j+1)*32*r] {
+			X[k] ^= v
+		}
+		scryptBlockMix(X, Y, r)
+	}
+
+	// B = X
+	for i, v := range X {
+		binary.LittleEndian.PutUint32(B[4*i:], v)
+	}
+}
+
+// scryptBlockMix runs the BlockMix function of RFC7914 section 4 on B in place
+// Y is scratch space of the same size as B
+func scryptBlockMix(B, Y []uint32, r int64) {
+	// X = B[2 * r - 1]
+	var X [16]uint32
+	copy(X[:], B[(2*r-1)*16:])
+
+	// Y[i] = Salsa(X xor B[i]), even blocks go first and odd blocks last
+	for i := int64(0); i < 2*r; i++ {
+		for k := range X {
+			X[k] ^= B[i*16+int64(k)]
+		}
+		salsa208(&X)
+
+		offset := (i / 2) * 16
+		if i%2 == 1 {
+			offset += r * 16
+		}
+		copy(Y[offset:], X[:])
+	}
+
+	copy(B, Y[:32*r])
+}
+
+// salsa208 applies the Salsa20/8 core to the block in place
+func salsa208(B *[16]uint32) {
+	x := *B
+
+	// 8 rounds, 2 per iteration
+	for i := 0; i < 8; i += 2 {
+		// columns
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+
+		// rows
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+
+	// add the input
+	for i := range B {
+		B[i] += x[i]
+	}
+}
+
+// EncodePasswordScrypt encodes a password using the scrypt algorithm
+// The encoded password is returned in the PHC string format: $scrypt$ln=log2(N),r=r,p=p$salt$hash(salt and hash are base64 encoded without padding)
+// The saltLength parameter is the length of the salt in bytes
+// The N, r and p parameters are the scrypt parameters
+// The keyLength parameter is the length of the derived key in bytes
+func EncodePasswordScrypt(password string, saltLength, N, r, p, keyLength int64) (string, error) {
+	// generate a salt
+	saltAsBytes, err := GenerateRandomSequence(int(saltLength))
+
+	// check if an error occurred
+	if err != nil {
+		return "", fmt.Errorf("error in EncodePasswordScrypt function while generating salt: %s", err.Error())
+	}
+
+	// encode the password
+	encodedPassword, err := Scrypt([]byte(password), saltAsBytes, N, r, p, keyLength)
+
+	// check if an error occurred
+	if err != nil {
+		return "", fmt.Errorf("error in EncodePasswordScrypt function while encoding password: %s", err.Error())
+	}
+
+	// return the encoded password
+	return GenerateScryptPasswordString(saltAsBytes, N, r, p, encodedPassword), nil
+}
+
+// VerifyPasswordScrypt checks if a password matches a password encoded by EncodePasswordScrypt
+// The password parameter is the password to be checked
+// The encodedPassword parameter is the encoded password
+// the parameters of the encoded password must fit in DefaultScryptMaxMemory
+func VerifyPasswordScrypt(password, encodedPassword string) (bool, error) {
+	// get the password parameters
+	saltAsBytes, N, r, p, passwordHash, err := GetScryptParametersFromString(encodedPassword)
+
+	// check if an error occurred
+	if err != nil {
+		return false, fmt.Errorf("error in VerifyPasswordScrypt function while getting password parameters: %s", err.Error())
+	}
+
+	// encode the password
+	passwordHash2, err := Scrypt([]byte(password), saltAsBytes, N, r, p, int64(len(passwordHash)))
+
+	// check if an error occurred
+	if err != nil {
+		return false, fmt.Errorf("error in VerifyPasswordScrypt function while encoding password: %s", err.Error())
+	}
+
+	// compare the hashes in constant time
+	return subtle.ConstantTimeCompare(passwordHash, passwordHash2) == 1, nil
+}
+
+// GenerateScryptPasswordString generates a scrypt PHC string from the given parameters
+// The salt parameter is the salt as a byte slice
+// The N, r and p parameters are the scrypt parameters, N must be a power of 2
+// The encodedPassword parameter is the derived key as a byte slice
+func GenerateScryptPasswordString(salt []byte, N, r, p int64, encodedPassword []byte) string {
+	phc := &phcString{
+		id: "scrypt",
+		params: []phcParam{
+			{"ln", fmt.Sprint(bits.Len64(uint64(N)) - 1)},
+			{"r", fmt.Sprint(r)},
+			{"p", fmt.Sprint(p)},
+		},
+		salt: append([]byte{}, salt...),
+		hash: append([]byte{}, encodedPassword...),
+	}
+
+	return phc.String()
+}
+
+// GetScryptParametersFromString gets the scrypt parameters from a PHC string
+// the string must be in the format: $scrypt$ln=log2(N),r=r,p=p$salt$hash
+// the salt, N, r, p and derived key are returned in this order
+func GetScryptParametersFromString(encodedPassword string) ([]byte, int64, int64, int64, []byte, error) {
+	// parse the PHC string
+	phc, err := parsePHCString(encodedPassword)
+
+	// check if an error occurred
+	if err != nil {
+		return nil, 0, 0, 0, nil, fmt.Errorf("error in GetScryptParametersFromString function: %s", err.Error())
+	}
+
+	// check the identifier, version and parameters, an empty hash would match every password
+	if phc.id != "scrypt" || phc.hasVersion || phc.salt == nil || len(phc.hash) == 0 {
+		return nil, 0, 0, 0, nil, errors.New("error in GetScryptParametersFromString function: encodedPassword must be in the format $scrypt$ln=..,r=..,p=..$salt$hash with a non-empty hash")
+	}
+
+	if err := phc.checkParams("ln", "r", "p"); err != nil {
+		return nil, 0, 0, 0, nil, fmt.Errorf("error in GetScryptParametersFromString function: %s", err.Error())
+	}
+
+	// decode the parameters
+	values := make([]int64, 3)
+	for i, name := range []string{"ln", "r", "p"} {
+		if values[i], err = phc.intParam(name); err != nil {
+			return nil, 0, 0, 0, nil, fmt.Errorf("error in GetScryptParametersFromString function: %s", err.Error())
+		}
+	}
+
+	// check log2(N)
+	if values[0] < 1 || values[0] > 62 {
+		return nil, 0, 0, 0, nil, errors.New("error in GetScryptParametersFromString function: ln must be between 1 and 62")
+	}
+
+	// return the password parameters
+	return phc.salt, int64(1) << values[0], values[1], values[2], phc.hash, nil
+}
@@ -0,0 +1,54 @@
+package pbkdf
+
+import (
+	"errors"
+	"testing"
+)
+
+// tests the EncodePasswordScrypt and VerifyPasswordScrypt functions and the parameter checks
+func TestEncodeVerifyPasswordScrypt(t *testing.T) {
+	encoded, err := EncodePasswordScrypt("password", 16, 1024, 8, 1, 32)
+	if err != nil {
+		t.Fatalf("error in TestEncodeVerifyPasswordScrypt function while encoding password: %s", err.Error())
+	}
+
+	// the parameters are stored in the string
+	salt, N, r, p, key, err := GetScryptParametersFromString(encoded)
+	if err != nil || len(salt) != 16 || N != 1024 || r != 8 || p != 1 || len(key) != 32 {
+		t.Fatalf("error in TestEncodeVerifyPasswordScrypt function: unexpected parameters in %q(%v)", encoded, err)
+	}
+
+	if valid, err := VerifyPasswordScrypt("password", encoded); err != nil || !valid {
+		t.Errorf("error in TestEncodeVerifyPasswordScrypt function: encoded password is not valid(%v)", err)
+	}
+
+	if valid, err := VerifyPasswordScrypt("Password", encoded); err != nil || valid {
+		t.Errorf("error in TestEncodeVerifyPasswordScrypt function: wrong password is valid(%v)", err)
+	}
+
+	// invalid and oversized parameters are refused before any memory is allocated
+	for _, params := range [][3]int64{{1000, 8, 1}, {1, 8, 1}, {1 << 20, 0, 1}, {1 << 16, 1, 1}, {1 << 30, 8, 1}, {1 << 40, 1 << 29, 1 << 29}} {
+		if _, err := Scrypt([]byte("password"), []byte("salt"), params[0], params[1], params[2], 32); err == nil {
+			t.Errorf("error in TestEncodeVerifyPasswordScrypt function: parameters %v were accepted", params)
+		}
+	}
+
+	if _, err := VerifyPasswordScrypt("password", "$scrypt$ln=40,r=8,p=1$c2FsdA$a2V5"); err == nil {
+		t.Errorf("error in TestEncodeVerifyPasswordScrypt function: encoded password over the memory limit was accepted")
+	}
+
+	// a string with an empty hash does not verify any password
+	if valid, err := VerifyPasswordScrypt("anything", "$scrypt$ln=4,r=1,p=1$c2FsdA$"); err == nil || valid {
+		t.Errorf("error in TestEncodeVerifyPasswordScrypt function: empty hash gave %v(%v)", valid, err)
+	}
+
+	// scrypt is refused in compliance mode
+	if err := EnableComplianceMode(DefaultCompliancePolicy()); err != nil {
+		t.Fatalf("error in TestEncodeVerifyPasswordScrypt function while enabling compliance mode: %s", err.Error())
+	}
+	defer DisableComplianceMode()
+
+	if _, err := Scrypt([]byte("password"), make([]byte, 16), 1024, 8, 1, 32); !errors.Is(err, ErrNotCompliant) {
+		t.Errorf("error in TestEncodeVerifyPasswordScrypt function: scrypt was accepted in compliance mode(%v)", err)
+	}
+}
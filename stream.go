@@ -0,0 +1,429 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// stream format constants
+const (
+	// DefaultStreamChunkSize is the default plaintext size of a chunk(64 KiB)
+	DefaultStreamChunkSize = 64 * 1024
+	// MaxStreamChunkSize is the largest accepted chunk size(16 MiB), it bounds the memory used by a reader
+	MaxStreamChunkSize = 16 * 1024 * 1024
+	// length of the chunk counter and the last chunk flag at the end of the nonce
+	streamNonceSuffixLength = 5
+	// maximum number of chunks, the counter is a 32 bit integer
+	streamMaxChunks = 1 << 32
+)
+
+// streamMagic starts every encrypted stream
+var streamMagic = []byte("PBKS")
+
+// StreamOptions holds the optional parameters of NewStreamWriter, zero values select the defaults
+type StreamOptions struct {
+	// SealOptions selects the hash, iteration count, salt length and cipher as for SealWithOptions
+	SealOptions
+	// ChunkSize is the plaintext size of a chunk, zero means DefaultStreamChunkSize
+	ChunkSize int
+}
+
+// StreamWriter encrypts the data written to it in authenticated chunks
+// It is based on the STREAM construction of Hoang, Reyhanitabar, Rogaway and Vizár(https://eprint.iacr.org/2015/189)
+// every chunk is sealed with the nonce prefix || chunk counter(32 bit big-endian) || last chunk flag,
+// so reordering, dropping or truncating chunks makes the reader fail
+// Close must be called to write the last chunk, it does not close the underlying writer
+type StreamWriter struct {
+	w         io.Writer
+	aead      cipher.AEAD
+	header    []byte
+	prefix    []byte
+	chunkSize int
+	buf       []byte
+	counter   uint64
+	err       error
+}
+
+// NewStreamWriter creates a StreamWriter and writes the stream header to w
+// w: the writer receiving the encrypted stream
+// password: the password
+// options: the optional parameters, the zero value gives the defaults of Seal with 64 KiB chunks
+// the header has the format of an envelope header starting with "PBKS" followed by the chunk size(32 bit big-endian),
+// it records the KDF, hash, iteration count, salt, cipher and nonce prefix and is authenticated with every chunk
+// a chunk is at most ChunkSize bytes of plaintext followed by the tag, only the last chunk can be shorter
+func NewStreamWriter(w io.Writer, password string, options StreamOptions) (*StreamWriter, error) {
+	// check the chunk size
+	if options.ChunkSize == 0 {
+		options.ChunkSize = DefaultStreamChunkSize
+	}
+
+	if options.ChunkSize < 0 || options.ChunkSize > MaxStreamChunkSize {
+		return nil, fmt.Errorf("error in NewStreamWriter function: chunk size must be between 1 and %d bytes", MaxStreamChunkSize)
+	}
+
+	// derive the key, the nonce of the header is the nonce prefix
+	header, aead, err := newEnvelopeHeader(password, options.SealOptions, "stream", streamNonceSuffixLength)
+	if err != nil {
+		return nil, fmt.Errorf("error in NewStreamWriter function: %w", err)
+	}
+
+	encoded := append(header.bytes(streamMagic), ConvertUnsignedIntegerToByteSlice(uint64(options.ChunkSize), 4, false)...)
+
+	// write the header
+	if _, err := w.Write(encoded); err != nil {
+		return nil, fmt.Errorf("error in NewStreamWriter function while writing the header: %w", err)
+	}
+
+	return &StreamWriter{
+		w:         w,
+		aead:      aead,
+		header:    encoded,
+		prefix:    header.Nonce,
+		chunkSize: options.ChunkSize,
+		buf:       make([]byte, 0, options.ChunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Write encrypts p, full chunks are written to the underlying writer as soon as more data follows them
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		// a full chunk is not the last one since more data follows
+		if len(w.buf) == w.chunkSize {
+			if err := w.flush(false); err != nil {
+				return n, err
+			}
+		}
+
+		copied := copy(w.buf[len(w.buf):w.chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+copied]
+		p = p[copied:]
+		n += copied
+	}
+
+	return n, nil
+}
+
+// Close writes the last chunk, it can be empty, the StreamWriter can not be used afterwards
+func (w *StreamWriter) Close() error {
+	if w.err != nil {
+		// closing twice is not an error
+		if errors.Is(w.err, errStreamClosed) {
+			return nil
+		}
+
+		return w.err
+	}
+
+	if err := w.flush(true); err != nil {
+		return err
+	}
+
+	w.err = errStreamClosed
+	return nil
+}
+
+// errStreamClosed is returned by Write after Close
+var errStreamClosed = errors.New("error in StreamWriter: write after Close")
+
+// flush seals the buffered chunk in place and writes it
+func (w *StreamWriter) flush(last bool) error {
+	if w.counter >= streamMaxChunks {
+		w.err = errors.New("error in StreamWriter: stream has too many chunks")
+		return w.err
+	}
+
+	chunk := w.aead.Seal(w.buf[:0], streamNonce(w.prefix, w.counter, last), w.buf, w.header)
+	if _, err := w.w.Write(chunk); err != nil {
+		w.err = fmt.Errorf("error in StreamWriter while writing chunk %d: %w", w.counter, err)
+		return w.err
+	}
+
+	w.counter++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// StreamReader decrypts a stream written by a StreamWriter
+// it reads one chunk at a time and only returns plaintext of chunks that were authenticated,
+// errors wrap ErrInvalidEnvelope when the stream was modified, reordered or truncated
+// when the underlying reader is an io.ReadSeeker it implements io.Seeker to decrypt byte ranges
+type StreamReader struct {
+	r         io.Reader
+	aead      cipher.AEAD
+	header    []byte
+	prefix    []byte
+	chunkSize int
+	start     int64
+	buf       []byte
+	pending   int
+	out       []byte
+	plain     []byte
+	counter   uint64
+	last      bool
+	offset    int64
+	size      int64
+	seek      bool
+	err       error
+}
+
+// NewStreamReader reads the stream header from r and creates a StreamReader
+// r: the reader of the encrypted stream
+// password: the password
+// the error wraps ErrWrongPassword when the password is wrong and ErrInvalidEnvelope when the header is malformed
+// streams with more than DefaultEnvelopeMaxIterations iterations are rejected, NewStreamReaderWithOptions sets another limit
+func NewStreamReader(r io.Reader, password string) (*StreamReader, error) {
+	return NewStreamReaderWithOptions(r, password, OpenOptions{})
+}
+
+// NewStreamReaderWithOptions is NewStreamReader with a choice of the highest accepted iteration count
+// the iteration count is checked before the key is derived, a count above the limit wraps ErrInvalidEnvelope
+func NewStreamReaderWithOptions(r io.Reader, password string, options OpenOptions) (*StreamReader, error) {
+	// read the header, the lengths of the salt and of the nonce are in the header
+	header := make([]byte, 12, 64)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("error in NewStreamReaderWithOptions function: %w: reading header: %s", ErrInvalidEnvelope, err.Error())
+	}
+
+	if !bytes.Equal(header[:4], streamMagic) {
+		return nil, fmt.Errorf("error in NewStreamReaderWithOptions function: %w: not an encrypted stream", ErrInvalidEnvelope)
+	}
+
+	// salt, cipher and nonce length, then nonce, check value and chunk size
+	for i := 0; i < 2; i++ {
+		length := int(header[11]) + 2
+		if i == 1 {
+			length = int(header[len(header)-1]) + envelopeCheckLength + 4
+		}
+
+		field := make([]byte, length)
+		if _, err := io.ReadFull(r, field); err != nil {
+			return nil, fmt.Errorf("error in NewStreamReaderWithOptions function: %w: reading header: %s", ErrInvalidEnvelope, err.Error())
+		}
+
+		header = append(header, field...)
+	}
+
+	parsed, length, err := parseEnvelopeHeader(streamMagic, header)
+	if err != nil {
+		return nil, fmt.Errorf("error in NewStreamReaderWithOptions function: %w: %s", ErrInvalidEnvelope, err.Error())
+	}
+
+	// check the chunk size before allocating the buffers
+	chunkSize := int(ConvertSliceToUnsignedInteger(header[length:], false))
+	if chunkSize <= 0 || chunkSize > MaxStreamChunkSize {
+		return nil, fmt.Errorf("error in NewStreamReaderWithOptions function: %w: chunk size %d is not accepted", ErrInvalidEnvelope, chunkSize)
+	}
+
+	// the iteration count is not authenticated yet
+	if err := options.checkIterations(parsed.Iterations); err != nil {
+		return nil, fmt.Errorf("error in NewStreamReaderWithOptions function: %w: %s", ErrInvalidEnvelope, err.Error())
+	}
+
+	// derive the key and the check value
+	aead, check, err := parsed.deriveAEAD(password, "stream")
+	if err != nil {
+		return nil, fmt.Errorf("error in NewStreamReaderWithOptions function: %w", err)
+	}
+
+	if len(parsed.Nonce) != aead.NonceSize()-streamNonceSuffixLength {
+		return nil, fmt.Errorf("error in NewStreamReaderWithOptions function: %w: nonce prefix must be %d bytes", ErrInvalidEnvelope, aead.NonceSize()-streamNonceSuffixLength)
+	}
+
+	if subtle.ConstantTimeCompare(check, parsed.Check) != 1 {
+		return nil, fmt.Errorf("error in NewStreamReaderWithOptions function: %w", ErrWrongPassword)
+	}
+
+	sr := &StreamReader{
+		r:         r,
+		aead:      aead,
+		header:    header,
+		prefix:    parsed.Nonce,
+		chunkSize: chunkSize,
+		buf:       make([]byte, chunkSize+aead.Overhead()+1),
+		out:       make([]byte, 0, chunkSize),
+		size:      -1,
+	}
+
+	// remember where the chunks start to seek later
+	if seeker, ok := r.(io.Seeker); ok {
+		if sr.start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return nil, fmt.Errorf("error in NewStreamReaderWithOptions function: %s", err.Error())
+		}
+	}
+
+	return sr, nil
+}
+
+// Read decrypts the next bytes of the stream
+// it returns io.EOF after the last chunk and an error wrapping ErrInvalidEnvelope if the stream ends before it
+func (r *StreamReader) Read(p []byte) (int, error) {
+	n := 0
+
+	for n < len(p) {
+		// decrypt the next chunk when the current one has been read
+		if len(r.plain) == 0 {
+			if r.err != nil {
+				break
+			}
+
+			if r.last {
+				r.err = io.EOF
+				break
+			}
+
+			if err := r.nextChunk(); err != nil {
+				r.err = err
+			}
+
+			continue
+		}
+
+		// copy the unread bytes of the chunk
+		copied := copy(p[n:], r.plain)
+		r.plain = r.plain[copied:]
+		r.offset += int64(copied)
+		n += copied
+	}
+
+	// bytes read before an error are returned first
+	if n > 0 || len(p) == 0 {
+		return n, nil
+	}
+
+	return 0, r.err
+}
+
+// nextChunk reads, authenticates and decrypts the next chunk
+func (r *StreamReader) nextChunk() error {
+	skip := 0
+
+	// move the underlying reader to the chunk holding the offset
+	if r.seek {
+		r.seek = false
+
+		if r.offset >= r.size {
+			r.last = true
+			return nil
+		}
+
+		index := r.offset / int64(r.chunkSize)
+		skip = int(r.offset % int64(r.chunkSize))
+
+		if _, err := r.r.(io.Seeker).Seek(r.start+index*int64(r.chunkSize+r.aead.Overhead()), io.SeekStart); err != nil {
+			return fmt.Errorf("error in StreamReader while seeking: %w", err)
+		}
+
+		r.counter, r.pending = uint64(index), 0
+	}
+
+	if r.counter >= streamMaxChunks {
+		return fmt.Errorf("error in StreamReader: %w: stream has too many chunks", ErrInvalidEnvelope)
+	}
+
+	// read one byte more than a chunk, the chunk is the last one when it is not there
+	read, err := io.ReadFull(r.r, r.buf[r.pending:])
+	read += r.pending
+
+	chunkLength := len(r.buf) - 1
+	switch {
+	case err == nil:
+		r.last = false
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		r.last, chunkLength = true, read
+	default:
+		return fmt.Errorf("error in StreamReader while reading chunk %d: %w", r.counter, err)
+	}
+
+	// authenticate and decrypt
+	plain, err := r.aead.Open(r.out[:0], streamNonce(r.prefix, r.counter, r.last), r.buf[:chunkLength], r.header)
+	if err != nil {
+		return fmt.Errorf("error in StreamReader: %w: chunk %d failed authentication(the stream was modified, reordered or truncated)", ErrInvalidEnvelope, r.counter)
+	}
+
+	// the read ahead byte starts the next chunk
+	if !r.last {
+		r.buf[0], r.pending = r.buf[chunkLength], 1
+	}
+
+	r.plain = plain[skip:]
+
+	r.counter++
+	return nil
+}
+
+// Seek sets the plaintext offset of the next Read, the underlying reader must be an io.ReadSeeker
+// the first call reads the size of the underlying stream to find the plaintext size, the chunks are authenticated when they are read
+func (r *StreamReader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := r.r.(io.Seeker)
+	if !ok {
+		return 0, errors.New("error in StreamReader.Seek: underlying reader is not an io.Seeker")
+	}
+
+	// find the plaintext size
+	if r.size < 0 {
+		size, err := r.plaintextSize(seeker)
+		if err != nil {
+			return 0, err
+		}
+
+		r.size = size
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("error in StreamReader.Seek: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("error in StreamReader.Seek: negative position")
+	}
+
+	r.offset, r.seek, r.plain, r.last, r.err = offset, true, nil, false, nil
+	return offset, nil
+}
+
+// plaintextSize returns the plaintext size from the size of the underlying stream
+// every chunk but the last one is full, so the size of the last chunk is the rest
+func (r *StreamReader) plaintextSize(seeker io.Seeker) (int64, error) {
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, fmt.Errorf("error in StreamReader.Seek: %w", err)
+	}
+
+	overhead, chunk := int64(r.aead.Overhead()), int64(r.chunkSize+r.aead.Overhead())
+	full, rest := (end-r.start)/chunk, (end-r.start)%chunk
+
+	switch {
+	case rest == 0 && full > 0:
+		return full * int64(r.chunkSize), nil
+	case rest >= overhead:
+		return full*int64(r.chunkSize) + rest - overhead, nil
+	}
+
+	return 0, fmt.Errorf("error in StreamReader.Seek: %w: stream is truncated", ErrInvalidEnvelope)
+}
+
+// streamNonce returns prefix || counter(32 bit big-endian) || last chunk flag
+func streamNonce(prefix []byte, counter uint64, last bool) []byte {
+	nonce := append(append(make([]byte, 0, len(prefix)+streamNonceSuffixLength), prefix...), ConvertUnsignedIntegerToByteSlice(counter, 4, false)...)
+
+	if last {
+		return append(nonce, 1)
+	}
+
+	return append(nonce, 0)
+}
@@ -0,0 +1,178 @@
+package pbkdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// encryptStream returns the encrypted stream of plaintext with 64 byte chunks
+func encryptStream(t *testing.T, plaintext []byte, cipher EnvelopeCipher) []byte {
+	var encrypted bytes.Buffer
+
+	w, err := NewStreamWriter(&encrypted, "password", StreamOptions{SealOptions: SealOptions{Iterations: 1000, Cipher: cipher}, ChunkSize: 64})
+	if err != nil {
+		t.Fatalf("error in encryptStream function while creating the writer: %s", err.Error())
+	}
+
+	// write in uneven pieces
+	for rest := plaintext; len(rest) > 0; {
+		n := min(len(rest), 37)
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatalf("error in encryptStream function while writing: %s", err.Error())
+		}
+		rest = rest[n:]
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("error in encryptStream function while closing: %s", err.Error())
+	}
+
+	return encrypted.Bytes()
+}
+
+// tests that streams of any length round trip with both ciphers
+func TestStream(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), 20)
+
+	for _, cipher := range []EnvelopeCipher{EnvelopeAES256GCM, EnvelopeChaCha20Poly1305} {
+		for _, length := range []int{0, 1, 63, 64, 65, 128, 200, len(plaintext)} {
+			encrypted := encryptStream(t, plaintext[:length], cipher)
+
+			r, err := NewStreamReader(iotest.OneByteReader(bytes.NewReader(encrypted)), "password")
+			if err != nil {
+				t.Fatalf("error in TestStream function while creating the reader: %s", err.Error())
+			}
+
+			decrypted, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("error in TestStream function while reading %d bytes with %s: %s", length, cipher, err.Error())
+			}
+
+			if !bytes.Equal(decrypted, plaintext[:length]) {
+				t.Errorf("error in TestStream function: %d bytes with %s did not round trip", length, cipher)
+			}
+		}
+	}
+
+	// wrong password
+	encrypted := encryptStream(t, plaintext, EnvelopeAES256GCM)
+	if _, err := NewStreamReader(bytes.NewReader(encrypted), "wrong password"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("error in TestStream function: wrong password gave %v", err)
+	}
+}
+
+// tests that truncated, reordered and modified streams are rejected
+func TestStreamTampering(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), 20)
+	encrypted := encryptStream(t, plaintext, EnvelopeAES256GCM)
+
+	// the header is followed by 4 full chunks and a last chunk of 64 plaintext bytes
+	chunk := 64 + 16
+	start := len(encrypted) - 5*chunk
+
+	reordered := append([]byte(nil), encrypted[:start]...)
+	reordered = append(reordered, encrypted[start+chunk:start+2*chunk]...)
+	reordered = append(reordered, encrypted[start:start+chunk]...)
+	reordered = append(reordered, encrypted[start+2*chunk:]...)
+
+	modified := append([]byte(nil), encrypted...)
+	modified[start+10] ^= 1
+
+	header := append([]byte(nil), encrypted...)
+	header[start-1] ^= 1
+
+	tampered := map[string][]byte{
+		"truncated at a chunk boundary": encrypted[:len(encrypted)-chunk],
+		"truncated in a chunk":          encrypted[:len(encrypted)-10],
+		"without chunks":                encrypted[:start],
+		"reordered":                     reordered,
+		"modified":                      modified,
+		"modified header":               header,
+		"extended":                      append(append([]byte(nil), encrypted...), encrypted[start:start+chunk]...),
+	}
+
+	// iteration counts of zero or above the limit are rejected before deriving the key
+	for _, iterations := range []uint64{0, 1<<32 - 1} {
+		stream := append([]byte(nil), encrypted...)
+		copy(stream[7:11], ConvertUnsignedIntegerToByteSlice(iterations, 4, false))
+		tampered["with "+fmt.Sprint(iterations)+" iterations"] = stream
+	}
+
+	if _, err := NewStreamReaderWithOptions(bytes.NewReader(encrypted), "password", OpenOptions{MaxIterations: 999}); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("error in TestStreamTampering function: iteration count above MaxIterations gave %v", err)
+	}
+
+	for name, stream := range tampered {
+		r, err := NewStreamReader(bytes.NewReader(stream), "password")
+		if err == nil {
+			_, err = io.ReadAll(r)
+		}
+
+		if !errors.Is(err, ErrInvalidEnvelope) {
+			t.Errorf("error in TestStreamTampering function: %s stream gave %v", name, err)
+		}
+	}
+}
+
+// tests that seeking decrypts any byte range
+func TestStreamSeek(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), 20)
+	encrypted := encryptStream(t, plaintext, EnvelopeChaCha20Poly1305)
+
+	// the stream does not have to start at the beginning of the file
+	file := bytes.NewReader(append([]byte("some other data"), encrypted...))
+	file.Seek(15, io.SeekStart)
+
+	r, err := NewStreamReader(file, "password")
+	if err != nil {
+		t.Fatalf("error in TestStreamSeek function while creating the reader: %s", err.Error())
+	}
+
+	// read a little first so the reader holds a chunk
+	first := make([]byte, 70)
+	if _, err := io.ReadFull(r, first); err != nil || !bytes.Equal(first, plaintext[:70]) {
+		t.Fatalf("error in TestStreamSeek function while reading the start: %v", err)
+	}
+
+	ranges := [][2]int64{{0, 10}, {60, 10}, {64, 64}, {250, 70}, {319, 1}, {100, 0}, {5, 315}}
+	for _, rng := range ranges {
+		if _, err := r.Seek(rng[0], io.SeekStart); err != nil {
+			t.Fatalf("error in TestStreamSeek function while seeking: %s", err.Error())
+		}
+
+		got := make([]byte, rng[1])
+		if _, err := io.ReadFull(r, got); err != nil || !bytes.Equal(got, plaintext[rng[0]:rng[0]+rng[1]]) {
+			t.Errorf("error in TestStreamSeek function: range %v gave %q, %v", rng, got, err)
+		}
+	}
+
+	// the end of the stream
+	if end, err := r.Seek(-20, io.SeekEnd); err != nil || end != 300 {
+		t.Errorf("error in TestStreamSeek function: seeking from the end gave %d, %v", end, err)
+	}
+
+	if rest, err := io.ReadAll(r); err != nil || !bytes.Equal(rest, plaintext[300:]) {
+		t.Errorf("error in TestStreamSeek function: reading the end gave %q, %v", rest, err)
+	}
+
+	if _, err := r.Seek(1000, io.SeekStart); err != nil {
+		t.Errorf("error in TestStreamSeek function while seeking past the end: %s", err.Error())
+	}
+
+	if n, err := r.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Errorf("error in TestStreamSeek function: reading past the end gave %d, %v", n, err)
+	}
+
+	// a modified chunk is still detected after seeking
+	encrypted[len(encrypted)-1] ^= 1
+	r, _ = NewStreamReader(bytes.NewReader(encrypted), "password")
+	r.Seek(300, io.SeekStart)
+
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("error in TestStreamSeek function: modified last chunk gave %v", err)
+	}
+}
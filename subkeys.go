@@ -0,0 +1,75 @@
+package pbkdf
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+)
+
+// SubkeySpec describes one subkey derived by DeriveSubkeys
+type SubkeySpec struct {
+	// Label names the subkey(for example "encryption", "mac" or "auth-token"), it is the HKDF info
+	Label string
+	// Length is the byte length of the subkey, at most 255 * hLen
+	Length int64
+}
+
+// DeriveSubkeys derives independent labeled subkeys from a password
+// PBKDF2 stretches the password once into an hLen byte pseudorandom key and every subkey is HKDF-Expand(PRK, Label, Length)
+// a subkey only depends on the password, the salt, the iteration count and its own label and length,
+// so adding, removing or reordering specs never changes the other subkeys
+// the subkey of a label is the key PBKDF2HKDF returns for the same info and length
+// hash: the hash function of both PBKDF2 and HKDF
+// P: the password(as a byte slice)
+// S: the salt(as a byte slice)
+// c: the iteration count
+// specs: the subkeys to derive, the labels must be unique and not empty
+// returns: the subkeys by label
+func DeriveSubkeys(hash crypto.Hash, P []byte, S []byte, c int64, specs ...SubkeySpec) (map[string][]byte, error) {
+	// check the specs
+	if len(specs) == 0 {
+		return nil, errors.New("error in DeriveSubkeys function: at least one subkey must be requested")
+	}
+
+	labels := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		switch {
+		case spec.Label == "":
+			return nil, errors.New("error in DeriveSubkeys function: labels must not be empty")
+		case labels[spec.Label]:
+			return nil, fmt.Errorf("error in DeriveSubkeys function: label %q is requested twice", spec.Label)
+		case spec.Length <= 0:
+			return nil, fmt.Errorf("error in DeriveSubkeys function: subkey %q must have a positive length", spec.Label)
+		}
+
+		labels[spec.Label] = true
+	}
+
+	// check the hash
+	if !hash.Available() {
+		return nil, fmt.Errorf("error in DeriveSubkeys function: hash function %d is not available", hash)
+	}
+
+	// PRK = PBKDF2(P, S, c, hLen)
+	PRK, err := PBKDF2(hash, P, S, c, int64(hash.Size()))
+
+	// check if an error occurred
+	if err != nil {
+		return nil, fmt.Errorf("error in DeriveSubkeys function while running PBKDF2: %w", err)
+	}
+
+	// expand one subkey per label
+	subkeys := make(map[string][]byte, len(specs))
+	for _, spec := range specs {
+		subkey, err := HKDFExpand(hash, PRK, []byte(spec.Label), spec.Length)
+
+		// check if an error occurred
+		if err != nil {
+			return nil, fmt.Errorf("error in DeriveSubkeys function while expanding %q: %w", spec.Label, err)
+		}
+
+		subkeys[spec.Label] = subkey
+	}
+
+	return subkeys, nil
+}
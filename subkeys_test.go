@@ -0,0 +1,54 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+// tests that subkeys are independent and that adding a label does not change the existing subkeys
+func TestDeriveSubkeys(t *testing.T) {
+	P, S := []byte("password"), []byte("salt")
+
+	subkeys, err := DeriveSubkeys(crypto.SHA256, P, S, 1000, SubkeySpec{"encryption", 32}, SubkeySpec{"mac", 64})
+	if err != nil {
+		t.Fatalf("error in TestDeriveSubkeys function while deriving subkeys: %s", err.Error())
+	}
+
+	// a new label in front of the others
+	more, err := DeriveSubkeys(crypto.SHA256, P, S, 1000, SubkeySpec{"auth-token", 16}, SubkeySpec{"mac", 64}, SubkeySpec{"encryption", 32})
+	if err != nil {
+		t.Fatalf("error in TestDeriveSubkeys function while deriving subkeys: %s", err.Error())
+	}
+
+	for label, subkey := range subkeys {
+		if !bytes.Equal(more[label], subkey) {
+			t.Errorf("error in TestDeriveSubkeys function: subkey %q changed when a label was added", label)
+		}
+	}
+
+	if len(more["auth-token"]) != 16 || len(subkeys["mac"]) != 64 || bytes.Equal(subkeys["encryption"], subkeys["mac"][:32]) {
+		t.Errorf("error in TestDeriveSubkeys function: subkeys have wrong lengths or are related")
+	}
+
+	// the subkeys are the keys of PBKDF2HKDF
+	keys, _ := PBKDF2HKDF(crypto.SHA256, P, S, 1000, 32, []byte("encryption"))
+	if !bytes.Equal(keys[0], subkeys["encryption"]) {
+		t.Errorf("error in TestDeriveSubkeys function: subkey differs from PBKDF2HKDF")
+	}
+
+	// invalid specs
+	invalid := [][]SubkeySpec{
+		nil,
+		{{"", 32}},
+		{{"mac", 32}, {"mac", 16}},
+		{{"mac", 0}},
+		{{"mac", 255*32 + 1}},
+	}
+
+	for _, specs := range invalid {
+		if _, err := DeriveSubkeys(crypto.SHA256, P, S, 1000, specs...); err == nil {
+			t.Errorf("error in TestDeriveSubkeys function: specs %v were accepted", specs)
+		}
+	}
+}
@@ -9,6 +9,11 @@ import (
 // The length parameter is the length of the random sequence in bytes
 // this function uses the crypto/rand package
 func GenerateRandomSequence(length int) ([]byte, error) {
+	// check the length
+	if length < 0 {
+		return nil, fmt.Errorf("error in GenerateRandomSequence function: length must not be negative")
+	}
+
 	// create a slice of bytes with the given length to hold the random sequence
 	seq := make([]byte, length)
 
@@ -138,7 +143,8 @@ func GetRandomRune(validRunes []rune) (rune, error) {
 // ConvertUnsignedIntegerToByteSlice converts an unsigned integer to a byte slice
 // The integer parameter is the integer to be converted
 // The byteLength parameter is the length of the byte slice
-// The bigEndian parameter indicates if the byte slice should be big endian
+// The bigEndian parameter selects the byte order, for historical reasons false writes the most significant byte first
+// and true writes the least significant byte first, ConvertSliceToUnsignedInteger uses the same convention
 // The byte slice is returned
 func ConvertUnsignedIntegerToByteSlice(integer uint64, byteLength int, bigEndian bool) []byte {
 	// create a byte slice to hold the integer
@@ -161,10 +167,10 @@ func ConvertUnsignedIntegerToByteSlice(integer uint64, byteLength int, bigEndian
 		shiftCount := 0
 
 		// loop through the bytes
-		for i := 0; i < byteLength; i-- {
+		for i := 0; i < byteLength; i++ {
 			// shift the integer and store it in the byte slice
 			b[i] = byte(integer >> shiftCount)
-			// reduce the shift count
+			// increase the shift count
 			shiftCount += 8
 		}
 	}
@@ -175,7 +181,7 @@ func ConvertUnsignedIntegerToByteSlice(integer uint64, byteLength int, bigEndian
 
 // ConvertByteSliceToUnsignedInteger converts a byte slice to an unsigned integer
 // The slice parameter is the byte slice to be converted
-// The bigEndian parameter indicates if the byte slice is big endian
+// The bigEndian parameter selects the byte order with the same convention as ConvertUnsignedIntegerToByteSlice
 // The integer is returned
 func ConvertSliceToUnsignedInteger(slice []byte, bigEndian bool) uint64 {
 	// get the byteLength of the integer
@@ -0,0 +1,432 @@
+package pbkdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// vectorFile is a file of known-answer test vectors, the schema is in testdata/vectors.schema.json
+type vectorFile struct {
+	Algorithm string       `json:"algorithm"`
+	Source    string       `json:"source"`
+	Vectors   []testVector `json:"vectors"`
+}
+
+// testVector holds the fields of one vector, the fields depend on the algorithm
+type testVector map[string]any
+
+// vectorRunners derive the key of a vector for each algorithm
+var vectorRunners = map[string]func(v testVector) ([]byte, error){
+	"pbkdf1":                  runPBKDFVector(PBKDF1),
+	"pbkdf2":                  runPBKDFVector(PBKDF2),
+	"pbkdf2-legacy":           runPBKDFVector(PBKDF2Legacy),
+	"scrypt":                  runScryptVector,
+	"argon2d":                 runArgon2Vector(Argon2d),
+	"argon2i":                 runArgon2Vector(Argon2i),
+	"argon2id":                runArgon2Vector(Argon2id),
+	"aes-cmac":                runPRFVector(aesCMAC),
+	"aes-cmac-prf-128":        runPRFVector(AESCMACPRF128),
+	"pbkdf2-aes-cmac-prf-128": runPBKDF2PRFVector(AESCMACPRF128),
+	"hkdf":                    runHKDFVector,
+	"kbkdf-counter":           runKBKDFVector(KBKDFCounter),
+	"kbkdf-feedback":          runKBKDFVector(KBKDFFeedback),
+	"kbkdf-double-pipeline":   runKBKDFVector(KBKDFDoublePipeline),
+	"concat-kdf":              runHashKDFVector(ConcatKDF, "otherInfo"),
+	"x963-kdf":                runHashKDFVector(X963KDF, "sharedInfo"),
+	"chacha20-poly1305":       runChaCha20Poly1305Vector,
+	"aes-key-wrap":            runKeyWrapVector(KeyWrap, KeyUnwrap),
+	"aes-key-wrap-pad":        runKeyWrapVector(KeyWrapPad, KeyUnwrapPad),
+}
+
+// aesCMAC is AES-CMAC as a PRF, the key must be a valid AES key
+func aesCMAC(key []byte) hash.Hash {
+	block, _ := aes.NewCipher(key)
+	mac, _ := NewCMAC(block)
+	return mac
+}
+
+// runPRFVector returns a runner computing PRF(key, message)
+func runPRFVector(prf PRF) func(v testVector) ([]byte, error) {
+	return func(v testVector) ([]byte, error) {
+		key, err := v.bytes("key")
+		if err != nil {
+			return nil, err
+		}
+
+		message, err := v.bytes("message")
+		if err != nil {
+			return nil, err
+		}
+
+		mac := prf(key)
+		mac.Write(message)
+		return mac.Sum(nil), nil
+	}
+}
+
+// runPBKDF2PRFVector returns a runner for PBKDF2WithPRF
+func runPBKDF2PRFVector(prf PRF) func(v testVector) ([]byte, error) {
+	return func(v testVector) ([]byte, error) {
+		P, err := v.bytes("password")
+		if err != nil {
+			return nil, err
+		}
+
+		S, err := v.bytes("salt")
+		if err != nil {
+			return nil, err
+		}
+
+		return PBKDF2WithPRF(prf, P, S, v.int("iterations"), v.int("dkLen"))
+	}
+}
+
+// runPBKDFVector returns a runner for a function with the PBKDF signature
+func runPBKDFVector(kdf PBKDF) func(v testVector) ([]byte, error) {
+	return func(v testVector) ([]byte, error) {
+		// get the inputs
+		hash, err := v.hash("hash")
+		if err != nil {
+			return nil, err
+		}
+
+		P, err := v.bytes("password")
+		if err != nil {
+			return nil, err
+		}
+
+		S, err := v.bytes("salt")
+		if err != nil {
+			return nil, err
+		}
+
+		// derive the key
+		return kdf(hash, P, S, v.int("iterations"), v.int("dkLen"))
+	}
+}
+
+// runScryptVector derives the key of a scrypt vector, the memory limit is raised for the large RFC vector
+func runScryptVector(v testVector) ([]byte, error) {
+	P, err := v.bytes("password")
+	if err != nil {
+		return nil, err
+	}
+
+	S, err := v.bytes("salt")
+	if err != nil {
+		return nil, err
+	}
+
+	N, r, p := v.param("N"), v.param("r"), v.param("p")
+	return ScryptWithMemoryLimit(P, S, N, r, p, v.int("dkLen"), 2*ScryptMemory(N, r, p))
+}
+
+// runArgon2Vector returns a runner for an Argon2 variant, the secret and associated data are optional
+func runArgon2Vector(variant Argon2Variant) func(v testVector) ([]byte, error) {
+	return func(v testVector) ([]byte, error) {
+		// get the inputs
+		var inputs [4][]byte
+		for i, name := range []string{"password", "salt", "secret", "associatedData"} {
+			b, err := v.bytes(name)
+			if err != nil {
+				return nil, err
+			}
+
+			inputs[i] = b
+		}
+
+		// derive the key
+		options := Argon2Options{Secret: inputs[2], AssociatedData: inputs[3]}
+		return Argon2WithOptions(variant, inputs[0], inputs[1], v.param("t"), v.param("m"), v.param("p"), v.int("dkLen"), options)
+	}
+}
+
+// runHKDFVector runs HKDFExtract and HKDFExpand, the pseudorandom key is checked when the vector has one
+func runHKDFVector(v testVector) ([]byte, error) {
+	// get the inputs
+	hash, err := v.hash("hash")
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs [3][]byte
+	for i, name := range []string{"ikm", "salt", "info"} {
+		if inputs[i], err = v.bytes(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// extract
+	PRK, err := HKDFExtract(hash, inputs[1], inputs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if expected, ok := v["prk"].(string); ok && hex.EncodeToString(PRK) != expected {
+		return nil, fmt.Errorf("got PRK %x, want %s", PRK, expected)
+	}
+
+	// expand
+	return HKDFExpand(hash, PRK, inputs[2], v.int("dkLen"))
+}
+
+// runHashKDFVector returns a runner for ConcatKDF or X963KDF, info is the name of the field holding OtherInfo or SharedInfo
+func runHashKDFVector(kdf func(hash crypto.Hash, Z, info []byte, dkLen int64) ([]byte, error), info string) func(v testVector) ([]byte, error) {
+	return func(v testVector) ([]byte, error) {
+		// get the inputs
+		hash, err := v.hash("hash")
+		if err != nil {
+			return nil, err
+		}
+
+		Z, err := v.bytes("z")
+		if err != nil {
+			return nil, err
+		}
+
+		I, err := v.bytes(info)
+		if err != nil {
+			return nil, err
+		}
+
+		// derive the key
+		return kdf(hash, Z, I, v.int("dkLen"))
+	}
+}
+
+// runChaCha20Poly1305Vector seals message with the key, the nonce and associatedData, the result is ciphertext || tag
+func runChaCha20Poly1305Vector(v testVector) ([]byte, error) {
+	// get the inputs
+	inputs := make([][]byte, 4)
+	for i, name := range []string{"key", "nonce", "message", "associatedData"} {
+		input, err := v.bytes(name)
+		if err != nil {
+			return nil, err
+		}
+
+		inputs[i] = input
+	}
+
+	aead, err := NewChaCha20Poly1305(inputs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// the nonce size is checked here because Seal panics
+	if len(inputs[1]) != aead.NonceSize() {
+		return nil, fmt.Errorf("nonce must be %d bytes", aead.NonceSize())
+	}
+
+	return aead.Seal(nil, inputs[1], inputs[2], inputs[3]), nil
+}
+
+// runKeyWrapVector returns a runner wrapping message with the AES key encryption key, unwrapping must give message back
+func runKeyWrapVector(wrap, unwrap func(block cipher.Block, key []byte) ([]byte, error)) func(v testVector) ([]byte, error) {
+	return func(v testVector) ([]byte, error) {
+		// get the inputs
+		KEK, err := v.bytes("key")
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := v.bytes("message")
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := aes.NewCipher(KEK)
+		if err != nil {
+			return nil, err
+		}
+
+		// wrap and unwrap
+		wrapped, err := wrap(block, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if unwrapped, err := unwrap(block, wrapped); err != nil || !bytes.Equal(unwrapped, key) {
+			return nil, fmt.Errorf("unwrapping gave %x, %v", unwrapped, err)
+		}
+
+		return wrapped, nil
+	}
+}
+
+// kbkdfPRFs maps the PRF names of the NIST CAVP vectors to the KBKDF parameters
+var kbkdfPRFs = map[string]KBKDFParameters{
+	"HMAC_SHA1":   {Hash: crypto.SHA1},
+	"HMAC_SHA224": {Hash: crypto.SHA224},
+	"HMAC_SHA256": {Hash: crypto.SHA256},
+	"HMAC_SHA384": {Hash: crypto.SHA384},
+	"HMAC_SHA512": {Hash: crypto.SHA512},
+	"CMAC_AES128": {PRF: CMACPRF(aes.NewCipher)},
+	"CMAC_AES192": {PRF: CMACPRF(aes.NewCipher)},
+	"CMAC_AES256": {PRF: CMACPRF(aes.NewCipher)},
+	"CMAC_TDES2":  {PRF: CMACPRF(newTwoKeyTripleDESCipher)},
+	"CMAC_TDES3":  {PRF: CMACPRF(des.NewTripleDESCipher)},
+}
+
+// newTwoKeyTripleDESCipher returns the TDES cipher of a 16 byte key k1 || k2, used as k1 || k2 || k1
+func newTwoKeyTripleDESCipher(key []byte) (cipher.Block, error) {
+	if len(key) != 16 {
+		return nil, des.KeySizeError(len(key))
+	}
+
+	return des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+}
+
+// kbkdfCounterPositions maps the counter locations of the NIST CAVP vectors to counter positions
+var kbkdfCounterPositions = map[string]KBKDFCounterPosition{
+	"BEFORE_FIXED": KBKDFCounterBeforeFixed,
+	"AFTER_ITER":   KBKDFCounterBeforeFixed,
+	"AFTER_FIXED":  KBKDFCounterAfterFixed,
+	"MIDDLE_FIXED": KBKDFCounterMiddleFixed,
+	"BEFORE_ITER":  KBKDFCounterBeforeIteration,
+}
+
+// runKBKDFVector returns a runner for a KBKDF mode, the vectors use the PRF and counter names of the NIST CAVP files
+// the counter width is the r parameter(zero when there is no counter) and the middle position is the offset parameter
+func runKBKDFVector(mode KBKDFMode) func(v testVector) ([]byte, error) {
+	return func(v testVector) ([]byte, error) {
+		// get the PRF and the counter encoding
+		parameters, ok := kbkdfPRFs[fmt.Sprint(v["prf"])]
+		if !ok {
+			return nil, fmt.Errorf("unknown PRF %q", v["prf"])
+		}
+
+		// the vectors without a counter have no location
+		position, ok := kbkdfCounterPositions[fmt.Sprint(v["ctrLocation"])]
+		if !ok && v.param("r") != 0 {
+			return nil, fmt.Errorf("unknown counter location %q", v["ctrLocation"])
+		}
+
+		parameters.Mode = mode
+		parameters.CounterPosition = position
+		parameters.CounterWidth = v.param("r")
+		parameters.OmitCounter = parameters.CounterWidth == 0
+		parameters.CounterOffset = v.param("offset")
+
+		// get the inputs
+		var inputs [3][]byte
+		for i, name := range []string{"key", "fixedInput", "iv"} {
+			b, err := v.bytes(name)
+			if err != nil {
+				return nil, err
+			}
+
+			inputs[i] = b
+		}
+		parameters.IV = inputs[2]
+
+		// derive the key
+		return KBKDF(inputs[0], inputs[1], v.int("dkLen"), parameters)
+	}
+}
+
+// bytes returns the byte string input name, given as UTF-8 text in name or as hex in nameHex
+func (v testVector) bytes(name string) ([]byte, error) {
+	if s, ok := v[name].(string); ok {
+		return []byte(s), nil
+	}
+
+	if s, ok := v[name+"Hex"].(string); ok {
+		return hex.DecodeString(s)
+	}
+
+	return []byte{}, nil
+}
+
+// int returns the integer field name
+func (v testVector) int(name string) int64 {
+	f, _ := v[name].(float64)
+	return int64(f)
+}
+
+// param returns the algorithm specific integer parameter name
+func (v testVector) param(name string) int64 {
+	params, _ := v["params"].(map[string]any)
+	f, _ := params[name].(float64)
+	return int64(f)
+}
+
+// hash returns the hash function named by the field name
+func (v testVector) hash(name string) (crypto.Hash, error) {
+	s, _ := v[name].(string)
+
+	// look for a hash with the same name
+	for h := crypto.MD4; h <= crypto.BLAKE2b_512; h++ {
+		if h.String() == s {
+			return h, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown hash function %q", s)
+}
+
+// TestVectors runs every vector file in testdata/vectors
+func TestVectors(t *testing.T) {
+	// find the vector files
+	paths, err := filepath.Glob(filepath.Join("testdata", "vectors", "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("error in TestVectors function: no vector files found(%v)", err)
+	}
+
+	for _, path := range paths {
+		// read the file
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("error in TestVectors function while reading %s: %s", path, err.Error())
+		}
+
+		// decode the file
+		var file vectorFile
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		if err := decoder.Decode(&file); err != nil {
+			t.Fatalf("error in TestVectors function while decoding %s: %s", path, err.Error())
+		}
+
+		// get the runner
+		run, ok := vectorRunners[file.Algorithm]
+		if !ok {
+			t.Fatalf("error in TestVectors function: %s uses unknown algorithm %q", path, file.Algorithm)
+		}
+
+		for _, v := range file.Vectors {
+			v := v
+			t.Run(fmt.Sprintf("%s/%s", filepath.Base(path), v["name"]), func(t *testing.T) {
+				// skip slow vectors in short mode
+				if slow, _ := v["slow"].(bool); slow && testing.Short() {
+					t.Skip("slow vector")
+				}
+
+				// decode the expected output
+				expected, err := hex.DecodeString(v["dk"].(string))
+				if err != nil {
+					t.Fatalf("error in TestVectors function while decoding dk: %s", err.Error())
+				}
+
+				// derive the key
+				dk, err := run(v)
+				if err != nil {
+					t.Fatalf("error in TestVectors function while deriving key: %s", err.Error())
+				}
+
+				// compare with the known answer
+				if !bytes.Equal(dk, expected) {
+					t.Errorf("error in TestVectors function: got %x, want %x", dk, expected)
+				}
+			})
+		}
+	}
+}
@@ -2,6 +2,7 @@ package pbkdf
 
 import (
 	"crypto"
+	"errors"
 	"fmt"
 )
 
@@ -23,6 +24,16 @@ func VerifyPasswordPBKDF2(hash crypto.Hash, password, encodedPassword string) (b
 	return VerifyPassword(hash, password, encodedPassword, PBKDF2)
 }
 
+// VerifyPasswordPBKDF2Legacy checks if a password matches an encoded password created with PBKDF2Legacy
+// passwords encoded with EncodePasswordPBKDF2 before PBKDF2 used HMAC must be verified with this function
+// The encoded password must be in the format: salt:iterationCount:hashedPassword(salt and hashedPassword are base64 encoded)
+// The hash parameter is the hash function to be used(can be any crypto.Hash)
+// The password parameter is the password to be checked
+// The encodedPassword parameter is the encoded password
+func VerifyPasswordPBKDF2Legacy(hash crypto.Hash, password, encodedPassword string) (bool, error) {
+	return VerifyPassword(hash, password, encodedPassword, PBKDF2Legacy)
+}
+
 // VerifyPassword checks if a password matches an encoded password
 // The encoded password must be in the format: salt:iterationCount:hashedPassword(salt and hashedPassword are base64 encoded)
 // The hash parameter is the hash function to be used(can be any crypto.Hash)
@@ -39,6 +50,11 @@ func VerifyPassword(hash crypto.Hash, password, encodedPassword string, kdf PBKD
 		return false, fmt.Errorf("error in VerifyPassword kdf while getting password parameters: %s", err.Error())
 	}
 
+	// an empty hash would match every password
+	if len(passwordHash) == 0 {
+		return false, errors.New("error in VerifyPassword kdf: encoded password has an empty hash")
+	}
+
 	// transform the password into a byte slice
 	passwordAsBytes := []byte(password)
 